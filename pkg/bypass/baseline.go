@@ -0,0 +1,337 @@
+package bypass
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/ibrahimsql/bypass403/pkg/http"
+)
+
+// Classifications assigned to an attempt by comparing it with the baseline
+const (
+	ClassBypass      = "bypass"
+	ClassRedirect    = "redirect"
+	ClassDifferent   = "different"
+	ClassBlocked     = "blocked"
+	ClassAuth        = "auth-required"
+	ClassNotFound    = "not-found"
+	ClassClientError = "client-error"
+	ClassServerError = "server-error"
+	ClassThrottled   = "throttled"
+	ClassRefused     = "upgrade-refused"
+	ClassError       = "error"
+)
+
+// keyHeaders are the response headers compared between the baseline and each attempt
+var keyHeaders = []string{"Location", "Content-Type", "Server", "WWW-Authenticate"}
+
+var (
+	titleRegex = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	// loginRegex matches login, SSO and OAuth pages by a whole path segment
+	// or host label, so "/author" or "/lessons" are not taken for one
+	loginRegex = regexp.MustCompile(`(?i)(^|[/._-])(log[-_]?in|sign[-_]?in|sso|auth|authorize|oauth2?|saml|cas)([/._?#-]|$)`)
+	blockRegex = regexp.MustCompile(`(?i)(access denied|forbidden|not authori[sz]ed|request blocked|permission denied)`)
+)
+
+// Fingerprint summarises a response so attempts can be compared with the baseline
+type Fingerprint struct {
+	ContentLength int
+	Words         int
+	Lines         int
+	Title         string
+	BodyHash      string
+	KeyHeaders    map[string]string
+}
+
+// Baseline is the fingerprint of the blocked response every attempt is compared to
+type Baseline struct {
+	StatusCode int
+	Fingerprint
+	// Dynamic is set when two identical baseline requests returned different
+	// bodies, in which case lengths are compared with a tolerance instead of hashes
+	Dynamic bool
+	// Signature is what marks a response as blocked, learned from both samples
+	Signature BlockSignature
+
+	// Request and Response are the evidence of the first baseline request
+	Request  *http.Request
+	Response ResponseEvidence
+}
+
+// BlockSignature is what the blocked response looks like: its status and the
+// Location or body wording that the block repeats on every request. Any
+// response that doesn't match it is different from the block.
+type BlockSignature struct {
+	StatusCode int `json:"status_code"`
+	// Location is where a blocking redirect leads, without its query string,
+	// and Login is set if that is a login page
+	Location string `json:"location,omitempty"`
+	Login    bool   `json:"login,omitempty"`
+	// Phrase is the block wording found in both samples, if any
+	Phrase string `json:"phrase,omitempty"`
+}
+
+// String describes the signature on one line
+func (s BlockSignature) String() string {
+	desc := fmt.Sprint(s.StatusCode)
+	if s.Location != "" {
+		desc += " to " + s.Location
+		if s.Login {
+			desc += " (login)"
+		}
+	}
+	if s.Phrase != "" {
+		desc += fmt.Sprintf(" saying %q", s.Phrase)
+	}
+	return desc
+}
+
+// Redirect reports whether the block is a redirect
+func (s BlockSignature) Redirect() bool {
+	return s.StatusCode >= 300 && s.StatusCode < 400
+}
+
+// learnSignature derives the block signature from the two baseline samples,
+// keeping only what both have in common
+func learnSignature(first, second *http.Response) BlockSignature {
+	sig := BlockSignature{StatusCode: first.StatusCode}
+
+	if sig.Redirect() {
+		location := locationKey(first.Header.Get("Location"))
+		if location == locationKey(second.Header.Get("Location")) {
+			sig.Location = location
+			sig.Login = loginRegex.MatchString(location)
+		}
+	}
+
+	phrase := blockPhrase(first.Body)
+	if phrase == blockPhrase(second.Body) {
+		sig.Phrase = phrase
+	}
+
+	return sig
+}
+
+// locationKey normalises a Location header to its scheme, host and path, so
+// a login redirect still matches when only its return-to parameter changes
+func locationKey(location string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+	u.RawQuery, u.Fragment = "", ""
+	return u.String()
+}
+
+// blockPhrase returns the block wording in a body, lower-cased, if any
+func blockPhrase(body []byte) string {
+	return strings.ToLower(string(blockRegex.Find(body)))
+}
+
+// NewFingerprint computes the fingerprint of a raw response
+func NewFingerprint(resp *http.Response) Fingerprint {
+	body := string(resp.Body)
+
+	fp := Fingerprint{
+		ContentLength: len(resp.Body),
+		Words:         len(strings.Fields(body)),
+		Lines:         strings.Count(body, "\n") + 1,
+		KeyHeaders:    make(map[string]string),
+	}
+	if len(resp.Body) == 0 {
+		fp.Lines = 0
+	}
+
+	if match := titleRegex.FindStringSubmatch(body); match != nil {
+		fp.Title = strings.TrimSpace(html.UnescapeString(match[1]))
+	}
+
+	sum := sha256.Sum256(resp.Body)
+	fp.BodyHash = hex.EncodeToString(sum[:])
+
+	for _, name := range keyHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			fp.KeyHeaders[name] = value
+		}
+	}
+
+	return fp
+}
+
+// CaptureBaseline requests the target twice, unmodified, and records the blocked response
+func CaptureBaseline(baseURL string, client *http.Client, config Config) (*Baseline, error) {
+	origin, path, query, err := splitTarget(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	target := withQuery(path, query)
+
+	req, err := newRequest(config.method(), origin, target, config)
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []*http.Response
+	for i := 0; i < 2; i++ {
+		resp, err := client.Send(req)
+		if err != nil {
+			var throttled *http.ThrottledError
+			if errors.As(err, &throttled) {
+				return nil, fmt.Errorf("error capturing baseline, target is throttling requests: %s", err)
+			}
+			return nil, fmt.Errorf("error capturing baseline: %s", err)
+		}
+		samples = append(samples, resp)
+	}
+
+	baseline := &Baseline{
+		StatusCode:  samples[0].StatusCode,
+		Fingerprint: NewFingerprint(samples[0]),
+		Signature:   learnSignature(samples[0], samples[1]),
+		Request:     req,
+		Response:    newResponseEvidence(samples[0]),
+	}
+
+	second := NewFingerprint(samples[1])
+	if second.BodyHash != baseline.BodyHash {
+		baseline.Dynamic = true
+	}
+
+	return baseline, nil
+}
+
+// Classify compares the result with the baseline and records its classification
+// and a confidence score between 0 and 100 that the attempt is a real bypass
+func (b *Baseline) Classify(result *Result) {
+	result.Classification, result.Confidence = b.classify(*result)
+}
+
+func (b *Baseline) classify(result Result) (string, int) {
+	status := result.StatusCode
+
+	switch {
+	case result.Throttled:
+		// Rate limited or dropped: says nothing about the bypass either way
+		return ClassThrottled, 0
+	case result.UpgradeRefused:
+		return ClassRefused, 0
+	case status == 0:
+		return ClassError, 0
+	case b.Blocked(result):
+		return ClassBlocked, 0
+	case status >= 200 && status < 300:
+		// Also a 200 block page that this response no longer matches
+		return ClassBypass, b.bypassConfidence(result)
+	case status == b.StatusCode && !b.Signature.Redirect():
+		// Same status, different page: something else answered, worth a look
+		return ClassDifferent, 30
+	case status >= 300 && status < 400:
+		if loginRegex.MatchString(result.KeyHeaders["Location"]) {
+			return ClassAuth, 5
+		}
+		return ClassRedirect, 40
+	case status == 401 || status == 407:
+		return ClassAuth, 5
+	case status == 404 || status == 410:
+		return ClassNotFound, 0
+	case status >= 400 && status < 500:
+		return ClassClientError, 5
+	default:
+		return ClassServerError, 10
+	}
+}
+
+// Blocked reports whether the result matches the block signature: the same
+// redirect as the block, or the same status with the block page or wording
+func (b *Baseline) Blocked(result Result) bool {
+	sig := b.Signature
+	if sig.Redirect() {
+		if result.StatusCode < 300 || result.StatusCode >= 400 {
+			return false
+		}
+		location := locationKey(result.Response.Location)
+		if sig.Location == "" {
+			// The block redirected somewhere else every time
+			return result.StatusCode == sig.StatusCode && b.sameContent(result)
+		}
+		return location == sig.Location || (sig.Login && loginRegex.MatchString(location))
+	}
+
+	if result.StatusCode != sig.StatusCode {
+		return false
+	}
+	if b.sameContent(result) {
+		return true
+	}
+	return sig.Phrase != "" && blockPhrase(resultBody(result)) == sig.Phrase
+}
+
+// resultBody is the full body of a result while it is kept, else its sample
+func resultBody(result Result) []byte {
+	if result.Response.Body != nil {
+		return result.Response.Body
+	}
+	return result.Response.BodySample
+}
+
+// bypassConfidence scores a 2xx response by how little it resembles a block page
+func (b *Baseline) bypassConfidence(result Result) int {
+	if result.BodyHash == b.BodyHash {
+		return 10
+	}
+
+	confidence := 90
+	switch {
+	case blockRegex.MatchString(result.Title):
+		confidence = 30
+	case b.Signature.Phrase != "" && blockPhrase(resultBody(result)) == b.Signature.Phrase:
+		// Still carries the wording of the block page
+		confidence = 30
+	case result.Method == "OPTIONS" || result.Method == "TRACE":
+		// These methods answer 200 from the server itself, without reaching the resource
+		confidence = 40
+	case result.ContentLength == 0 && result.Method != "HEAD":
+		confidence = 60
+	case b.similarLength(result):
+		confidence = 70
+	}
+
+	return confidence
+}
+
+// sameContent reports whether the result is indistinguishable from the baseline page
+func (b *Baseline) sameContent(result Result) bool {
+	if result.BodyHash == b.BodyHash {
+		return true
+	}
+	if !b.Dynamic {
+		return false
+	}
+	return result.Title == b.Title && b.similarLength(result)
+}
+
+// similarLength reports whether the result's length is within 5% of the baseline
+func (b *Baseline) similarLength(result Result) bool {
+	diff := result.ContentLength - b.ContentLength
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff*20 <= b.ContentLength
+}
+
+// promising reports whether a result looks different enough from the block to
+// be worth follow-up requests. Without a baseline it falls back to the status code.
+func (c Config) promising(result Result) bool {
+	if c.Baseline == nil {
+		return result.StatusCode != 403 && result.StatusCode != 404
+	}
+
+	class, _ := c.Baseline.classify(result)
+	return class == ClassBypass || class == ClassRedirect || class == ClassDifferent
+}
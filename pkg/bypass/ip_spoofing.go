@@ -1,12 +1,13 @@
 package bypass
 
-import (
-	"net/http"
-)
-
 // TestIPSpoofingHeaders tests IP spoofing headers to bypass 403 responses
-func TestIPSpoofingHeaders(baseURL string, client *http.Client, config Config) ([]Result, error) {
-	var results []Result
+func TestIPSpoofingHeaders(baseURL string, config Config) ([]Attempt, error) {
+	var attempts []Attempt
+	origin, path, query, err := splitTarget(baseURL)
+	if err != nil {
+		return attempts, err
+	}
+	target := withQuery(path, query)
 
 	ipHeaders := []struct {
 		Header string
@@ -95,27 +96,15 @@ func TestIPSpoofingHeaders(baseURL string, client *http.Client, config Config) (
 	}
 
 	for _, ipHeader := range ipHeaders {
-		req, err := http.NewRequest("GET", baseURL, nil)
+		req, err := newRequest(config.method(), origin, target, config)
 		if err != nil {
 			continue
 		}
 
-		req.Header.Set("User-Agent", config.UserAgent)
-		req.Header.Set(ipHeader.Header, ipHeader.Value)
-
-		resp, err := client.Do(req)
-		if err != nil {
-			continue
-		}
-		resp.Body.Close()
+		note := setHeader(req, ipHeader.Header, ipHeader.Value, config)
 
-		results = append(results, Result{
-			URL:        baseURL,
-			StatusCode: resp.StatusCode,
-			Method:     "GET",
-			Technique:  "IP Spoofing: " + ipHeader.Header,
-		})
+		attempts = append(attempts, Attempt{Request: req, Technique: "IP Spoofing: " + ipHeader.Header + note})
 	}
 
-	return results, nil
+	return attempts, nil
 }
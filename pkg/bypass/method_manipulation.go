@@ -1,12 +1,14 @@
 package bypass
 
-import (
-	"net/http"
-)
-
 // TestMethodManipulation tests different HTTP methods to bypass 403 responses
-func TestMethodManipulation(baseURL string, client *http.Client, config Config) ([]Result, error) {
-	var results []Result
+func TestMethodManipulation(baseURL string, config Config) ([]Attempt, error) {
+	var attempts []Attempt
+	origin, path, query, err := splitTarget(baseURL)
+	if err != nil {
+		return attempts, err
+	}
+	target := withQuery(path, query)
+
 	methods := []string{
 		"GET", "POST", "HEAD", "OPTIONS", "PUT", "DELETE", "TRACE", "CONNECT", "PATCH",
 		"PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK", "FAKE-METHOD",
@@ -16,26 +18,13 @@ func TestMethodManipulation(baseURL string, client *http.Client, config Config)
 	}
 
 	for _, method := range methods {
-		req, err := http.NewRequest(method, baseURL, nil)
-		if err != nil {
-			continue
-		}
-
-		req.Header.Set("User-Agent", config.UserAgent)
-
-		resp, err := client.Do(req)
+		req, err := newRequest(method, origin, target, config)
 		if err != nil {
 			continue
 		}
-		resp.Body.Close()
 
-		results = append(results, Result{
-			URL:        baseURL,
-			StatusCode: resp.StatusCode,
-			Method:     method,
-			Technique:  "Method Manipulation",
-		})
+		attempts = append(attempts, Attempt{Request: req, Technique: "Method Manipulation"})
 	}
 
-	return results, nil
+	return attempts, nil
 }
@@ -0,0 +1,82 @@
+package bypass
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/ibrahimsql/bypass403/pkg/wordlist"
+)
+
+// TestOriginIP sends the original request straight to the candidate origin
+// servers in the user's list, keeping the Host header and TLS server name of
+// the target. A 403 served by a CDN or WAF edge is often not enforced by the
+// origin behind it.
+func TestOriginIP(baseURL string, config Config) ([]Attempt, error) {
+	var attempts []Attempt
+	if config.OriginIPsPath == "" {
+		return attempts, nil
+	}
+
+	origin, path, query, err := splitTarget(baseURL)
+	if err != nil {
+		return attempts, err
+	}
+	target := withQuery(path, query)
+	u, err := url.Parse(origin)
+	if err != nil {
+		return attempts, err
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	entries, err := wordlist.Load(config.OriginIPsPath)
+	if err != nil {
+		return attempts, err
+	}
+
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		// Entries are IPs or host names, optionally with a port
+		address := entry
+		explicitPort := true
+		if _, _, err := net.SplitHostPort(entry); err != nil {
+			address = net.JoinHostPort(strings.Trim(entry, "[]"), port)
+			explicitPort = false
+		}
+
+		req, err := newRequest(config.method(), origin, target, config)
+		if err != nil {
+			continue
+		}
+		req.Address = address
+		attempts = append(attempts, Attempt{
+			Request:   req,
+			Technique: "Origin IP: " + address,
+		})
+
+		// Origins often only check TLS at the edge, so try plain HTTP as well
+		if u.Scheme == "https" && !explicitPort {
+			plainReq, err := newRequest(config.method(), "http://"+u.Hostname(), target, config)
+			if err != nil {
+				continue
+			}
+			plainReq.Address = net.JoinHostPort(strings.Trim(entry, "[]"), "80")
+			attempts = append(attempts, Attempt{
+				Request:   plainReq,
+				Technique: "Origin IP over HTTP: " + plainReq.Address,
+			})
+		}
+	}
+
+	return attempts, nil
+}
@@ -1,20 +1,18 @@
 package bypass
 
 import (
-	"net/http"
-	"net/url"
 	"strings"
 )
 
 // TestURLPathManipulation tests different URL path manipulations to bypass 403 responses
-func TestURLPathManipulation(baseURL string, client *http.Client, config Config) ([]Result, error) {
-	var results []Result
-	parsedURL, err := url.Parse(baseURL)
+func TestURLPathManipulation(baseURL string, config Config) ([]Attempt, error) {
+	var attempts []Attempt
+	origin, originalPath, query, err := splitTarget(baseURL)
 	if err != nil {
-		return results, err
+		return attempts, err
 	}
+	host := parseDomain(baseURL)
 
-	originalPath := parsedURL.Path
 	pathManipulations := []string{
 		originalPath + "/",
 		originalPath + "//",
@@ -23,7 +21,7 @@ func TestURLPathManipulation(baseURL string, client *http.Client, config Config)
 		originalPath + "%2f",
 		originalPath + "%2e",
 		originalPath + "%252f",
-		"//" + parsedURL.Host + originalPath,
+		"//" + host + originalPath,
 		"/" + originalPath,
 		originalPath + "/;",
 		originalPath + "..;/",
@@ -55,29 +53,13 @@ func TestURLPathManipulation(baseURL string, client *http.Client, config Config)
 	}
 
 	for _, path := range pathManipulations {
-		manipulatedURL := *parsedURL
-		manipulatedURL.Path = path
-
-		req, err := http.NewRequest("GET", manipulatedURL.String(), nil)
-		if err != nil {
-			continue
-		}
-
-		req.Header.Set("User-Agent", config.UserAgent)
-
-		resp, err := client.Do(req)
+		req, err := newRequest(config.method(), origin, withQuery(path, query), config)
 		if err != nil {
 			continue
 		}
-		resp.Body.Close()
 
-		results = append(results, Result{
-			URL:        manipulatedURL.String(),
-			StatusCode: resp.StatusCode,
-			Method:     "GET",
-			Technique:  "URL Path Manipulation",
-		})
+		attempts = append(attempts, Attempt{Request: req, Technique: "URL Path Manipulation"})
 	}
 
-	return results, nil
+	return attempts, nil
 }
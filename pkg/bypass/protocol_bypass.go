@@ -1,18 +1,17 @@
 package bypass
 
 import (
-	"net/http"
-	"net/url"
 	"strings"
 )
 
 // TestProtocolBypass tests different protocol manipulations to bypass 403 responses
-func TestProtocolBypass(baseURL string, client *http.Client, config Config) ([]Result, error) {
-	var results []Result
-	parsedURL, err := url.Parse(baseURL)
+func TestProtocolBypass(baseURL string, config Config) ([]Attempt, error) {
+	var attempts []Attempt
+	origin, path, query, err := splitTarget(baseURL)
 	if err != nil {
-		return results, err
+		return attempts, err
 	}
+	host := parseDomain(baseURL)
 
 	// Try different protocols and protocol-related manipulations
 	protocols := []string{
@@ -35,63 +34,29 @@ func TestProtocolBypass(baseURL string, client *http.Client, config Config) ([]R
 			continue
 		}
 
-		var manipulatedURL string
+		// The manipulated URL is sent as the request-target on a connection to
+		// the original host, so proxies see the absolute or protocol-relative form
+		manipulatedTarget := withQuery(protocol+host+path, query)
 
-		if protocol == "//" {
-			// Protocol-relative URL
-			manipulatedURL = "//" + parsedURL.Host + parsedURL.Path
-			if parsedURL.RawQuery != "" {
-				manipulatedURL += "?" + parsedURL.RawQuery
-			}
-		} else {
-			manipulatedURL = protocol + parsedURL.Host + parsedURL.Path
-			if parsedURL.RawQuery != "" {
-				manipulatedURL += "?" + parsedURL.RawQuery
-			}
-		}
-
-		req, err := http.NewRequest("GET", manipulatedURL, nil)
-		if err != nil {
-			continue
-		}
-
-		req.Header.Set("User-Agent", config.UserAgent)
-
-		resp, err := client.Do(req)
+		req, err := newRequest(config.method(), origin, manipulatedTarget, config)
 		if err != nil {
 			continue
 		}
-		resp.Body.Close()
 
-		results = append(results, Result{
-			URL:        manipulatedURL,
-			StatusCode: resp.StatusCode,
-			Method:     "GET",
-			Technique:  "Protocol Change: " + protocol,
-		})
+		attempts = append(attempts, Attempt{Request: req, Technique: "Protocol Change: " + protocol})
 
 		// Also try with POST method
-		postReq, err := http.NewRequest("POST", manipulatedURL, nil)
+		postReq, err := newRequest("POST", origin, manipulatedTarget, config)
 		if err != nil {
 			continue
 		}
 
-		postReq.Header.Set("User-Agent", config.UserAgent)
-		postReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-		postResp, err := client.Do(postReq)
-		if err != nil {
-			continue
+		if postReq.Header("Content-Type") == "" {
+			postReq.SetHeader("Content-Type", "application/x-www-form-urlencoded")
 		}
-		postResp.Body.Close()
 
-		results = append(results, Result{
-			URL:        manipulatedURL,
-			StatusCode: postResp.StatusCode,
-			Method:     "POST",
-			Technique:  "Protocol Change: " + protocol,
-		})
+		attempts = append(attempts, Attempt{Request: postReq, Technique: "Protocol Change: " + protocol})
 	}
 
-	return results, nil
+	return attempts, nil
 }
@@ -1,14 +1,21 @@
 package bypass
 
 import (
-	"net/http"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/ibrahimsql/bypass403/pkg/http"
 )
 
 // TestCachingProxyBypass tests caching and proxy-related headers to bypass 403 responses
-func TestCachingProxyBypass(baseURL string, client *http.Client, config Config) ([]Result, error) {
-	var results []Result
+func TestCachingProxyBypass(baseURL string, config Config) ([]Attempt, error) {
+	var attempts []Attempt
+	origin, path, query, err := splitTarget(baseURL)
+	if err != nil {
+		return attempts, err
+	}
+	target := withQuery(path, query)
 
 	// Get domain from base URL
 	domain := parseDomain(baseURL)
@@ -67,62 +74,39 @@ func TestCachingProxyBypass(baseURL string, client *http.Client, config Config)
 
 	// Test individual headers
 	for _, header := range proxyHeaders {
-		req, err := http.NewRequest("GET", baseURL, nil)
+		req, err := newRequest(config.method(), origin, target, config)
 		if err != nil {
 			continue
 		}
 
-		req.Header.Set("User-Agent", config.UserAgent)
-		req.Header.Set(header.Header, header.Value)
+		note := setHeader(req, header.Header, header.Value, config)
 
-		resp, err := client.Do(req)
-		if err != nil {
-			continue
-		}
-		resp.Body.Close()
-
-		results = append(results, Result{
-			URL:        baseURL,
-			StatusCode: resp.StatusCode,
-			Method:     "GET",
-			Technique:  "Proxy Cache: " + header.Header,
-		})
+		attempts = append(attempts, Attempt{Request: req, Technique: "Proxy Cache: " + header.Header + note})
 	}
 
 	// Test combined headers
 	for i, headerSet := range cacheHeaders {
-		req, err := http.NewRequest("GET", baseURL, nil)
+		req, err := newRequest(config.method(), origin, target, config)
 		if err != nil {
 			continue
 		}
 
-		req.Header.Set("User-Agent", config.UserAgent)
+		technique := "Combined Proxy Headers Set " + strconv.Itoa(i+1)
 		for header, value := range headerSet {
-			req.Header.Set(header, value)
-		}
-
-		resp, err := client.Do(req)
-		if err != nil {
-			continue
+			technique += setHeader(req, header, value, config)
 		}
-		resp.Body.Close()
 
-		results = append(results, Result{
-			URL:        baseURL,
-			StatusCode: resp.StatusCode,
-			Method:     "GET",
-			Technique:  "Combined Proxy Headers Set " + strconv.Itoa(i+1),
-		})
+		attempts = append(attempts, Attempt{Request: req, Technique: technique})
 	}
 
-	return results, nil
+	return attempts, nil
 }
 
 // parseDomain extracts the domain from a URL
 func parseDomain(rawURL string) string {
-	parsedURL, err := http.NewRequest("GET", rawURL, nil)
+	origin, _, err := http.SplitURL(rawURL)
 	if err != nil {
 		return ""
 	}
-	return parsedURL.Host
+	return strings.SplitN(origin, "://", 2)[1]
 }
@@ -0,0 +1,266 @@
+package bypass
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/ibrahimsql/bypass403/pkg/http"
+)
+
+// bodyless are the methods a technique switches to without a body
+var bodyless = map[string]bool{"GET": true, "HEAD": true, "OPTIONS": true, "TRACE": true, "CONNECT": true}
+
+// newRequest builds a raw request for target on the origin, carrying the configured User-Agent
+// and the user's global headers, cookies and credentials. With a request
+// template, its headers are carried too, and its body unless the method was
+// changed to one that takes none.
+func newRequest(method, origin, target string, config Config) (*http.Request, error) {
+	req, err := http.NewRequest(method, origin, target)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep Host first and Connection last, with everything else between
+	req.DelHeader("Connection")
+
+	t := config.Template
+	if t != nil {
+		for _, h := range t.Headers {
+			req.AddHeader(h.Name, h.Value)
+		}
+		if t.Body != nil && (method == t.Method || !bodyless[method]) {
+			req.SetBody(append([]byte(nil), t.Body...))
+		}
+	}
+	if t == nil || config.RandomUA || req.Header("User-Agent") == "" {
+		req.SetHeader("User-Agent", config.UserAgent)
+	}
+
+	// -H replaces a header of the same name, repeating it adds more
+	set := make(map[string]bool)
+	for _, h := range config.Headers {
+		name := strings.ToLower(h.Name)
+		if set[name] {
+			req.AddHeader(h.Name, h.Value)
+		} else {
+			req.SetHeader(h.Name, h.Value)
+			set[name] = true
+		}
+	}
+	if value := config.Auth.Header(); value != "" {
+		req.SetHeader("Authorization", value)
+	}
+
+	req.AddHeader("Connection", "close")
+
+	return req, nil
+}
+
+// setHeader sets a header a technique is testing. If the user set that header
+// for every request, with -H, -b, -auth or the request file, it is replaced
+// and the returned note says so, to be added to the technique's name: an
+// attempt that dropped the user's session is then never mistaken for one
+// made with it. Cookies are added to the user's instead of replacing them.
+func setHeader(req *http.Request, name, value string, config Config) string {
+	if strings.EqualFold(name, "Cookie") {
+		if cookie := req.Header("Cookie"); cookie != "" {
+			req.SetHeader(name, cookie+"; "+value)
+			return ""
+		}
+	}
+
+	req.SetHeader(name, value)
+	if config.userHeader(name) {
+		return " (overrides " + name + ")"
+	}
+	return ""
+}
+
+// userHeader reports whether the user set the header for every request
+func (c Config) userHeader(name string) bool {
+	if strings.EqualFold(name, "Authorization") && c.Auth.Scheme != "" {
+		return true
+	}
+	for _, h := range c.Headers {
+		if strings.EqualFold(h.Name, name) {
+			return true
+		}
+	}
+	if c.Template != nil {
+		return c.Template.Header(name) != ""
+	}
+	return false
+}
+
+// method is the method of the request template, or GET without one. Techniques
+// use it for every request that doesn't test a method of its own.
+func (c Config) method() string {
+	if c.Template != nil {
+		return c.Template.Method
+	}
+	return "GET"
+}
+
+// Redacted replaces the user's header values in reports
+const Redacted = "[REDACTED]"
+
+// Redact returns a copy of a request for the reports, with the values the
+// user supplied for Authorization, Cookie and the -H headers, from the
+// command line or the request file, replaced by Redacted. What a technique
+// set itself, such as a cookie added to the user's, is kept.
+func (c Config) Redact(req *http.Request) *http.Request {
+	if req == nil {
+		return nil
+	}
+
+	secrets := make(map[string][]string)
+	add := func(name, value string) {
+		if value != "" {
+			name = strings.ToLower(name)
+			secrets[name] = append(secrets[name], value)
+		}
+	}
+	for _, h := range c.Headers {
+		add(h.Name, h.Value)
+	}
+	add("Authorization", c.Auth.Header())
+	if c.Template != nil {
+		for _, h := range c.Template.Headers {
+			if strings.EqualFold(h.Name, "Authorization") || strings.EqualFold(h.Name, "Cookie") {
+				add(h.Name, h.Value)
+			}
+		}
+	}
+	if len(secrets) == 0 {
+		return req
+	}
+
+	redacted := req.Clone()
+	redactHeaders(redacted, secrets)
+	if redacted.Upgrade != nil {
+		redactHeaders(redacted.Upgrade, secrets)
+	}
+	return redacted
+}
+
+// redactHeaders replaces the secret values of each header name, longest
+// first so a value containing another is replaced whole
+func redactHeaders(req *http.Request, secrets map[string][]string) {
+	for i, h := range req.Headers {
+		values := secrets[strings.ToLower(h.Name)]
+		sort.Slice(values, func(a, b int) bool { return len(values[a]) > len(values[b]) })
+		for _, value := range values {
+			req.Headers[i].Value = strings.ReplaceAll(req.Headers[i].Value, value, Redacted)
+		}
+	}
+}
+
+// Execute sends the attempt's request and records the outcome as a Result
+func Execute(client *http.Client, attempt Attempt) (Result, error) {
+	req := attempt.Request
+
+	resp, err := client.Send(req)
+	var upgrade *http.UpgradeError
+	if errors.As(err, &upgrade) {
+		// The server answered the upgrade request itself: record that answer,
+		// so the attempt shows up as tried rather than failed
+		return Result{
+			Target:         attempt.Target,
+			URL:            req.URL(),
+			StatusCode:     upgrade.Response.StatusCode,
+			Method:         req.Method,
+			Technique:      attempt.Technique + " (upgrade refused)",
+			Category:       attempt.Category,
+			Fingerprint:    NewFingerprint(upgrade.Response),
+			Request:        req,
+			Response:       newResponseEvidence(upgrade.Response),
+			UpgradeRefused: true,
+		}, nil
+	}
+	if err != nil {
+		var throttled *http.ThrottledError
+		if !errors.As(err, &throttled) {
+			return Result{}, err
+		}
+
+		// Report throttled attempts instead of dropping them, with the
+		// throttling response as evidence when there was one
+		result := Result{
+			Target:    attempt.Target,
+			URL:       req.URL(),
+			Method:    req.Method,
+			Technique: attempt.Technique,
+			Category:  attempt.Category,
+			Request:   req,
+			Throttled: true,
+		}
+		if throttled.Response != nil {
+			result.StatusCode = throttled.Response.StatusCode
+			result.Fingerprint = NewFingerprint(throttled.Response)
+			result.Response = newResponseEvidence(throttled.Response)
+		}
+		return result, nil
+	}
+
+	// A response to an upgrade attempt means the proxy let the upgrade through
+	technique := attempt.Technique
+	if req.Upgrade != nil {
+		technique += " (upgrade forwarded)"
+	}
+
+	return Result{
+		Target:      attempt.Target,
+		URL:         req.URL(),
+		StatusCode:  resp.StatusCode,
+		Method:      req.Method,
+		Technique:   technique,
+		Category:    attempt.Category,
+		Fingerprint: NewFingerprint(resp),
+		Request:     req,
+		Response:    newResponseEvidence(resp),
+	}, nil
+}
+
+// splitTarget splits a URL into its origin and the raw path and query of its request-target
+func splitTarget(baseURL string) (origin, path, query string, err error) {
+	origin, target, err := http.SplitURL(baseURL)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	// Fragments are never sent, so drop any the user pasted in
+	if idx := strings.Index(target, "#"); idx != -1 {
+		target = target[:idx]
+	}
+
+	path = target
+	if idx := strings.Index(target, "?"); idx != -1 {
+		path, query = target[:idx], target[idx+1:]
+	}
+
+	return origin, path, query, nil
+}
+
+// withQuery appends the original query string to a raw path, if there is one
+func withQuery(path, query string) string {
+	if query == "" {
+		return path
+	}
+	return path + "?" + query
+}
+
+// parentDir returns everything before the last slash of a raw path, without
+// cleaning dot segments or repeated slashes the way path.Dir would
+func parentDir(path string) string {
+	idx := strings.LastIndex(path, "/")
+	if idx <= 0 {
+		return ""
+	}
+	return path[:idx]
+}
+
+// lastSegment returns everything after the last slash of a raw path
+func lastSegment(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
@@ -0,0 +1,241 @@
+package bypass
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ibrahimsql/bypass403/pkg/http"
+)
+
+func TestSplitTarget(t *testing.T) {
+	tests := []struct {
+		url     string
+		origin  string
+		path    string
+		query   string
+		wantErr bool
+	}{
+		{"https://example.com/admin", "https://example.com", "/admin", "", false},
+		{"https://example.com/admin?x=1&y=2", "https://example.com", "/admin", "x=1&y=2", false},
+		{"https://example.com/admin?x=1#section", "https://example.com", "/admin", "x=1", false},
+		{"https://example.com/admin#?x=1", "https://example.com", "/admin", "", false},
+		{"http://example.com:8080", "http://example.com:8080", "/", "", false},
+		{"example.com/admin", "", "", "", true},
+	}
+
+	for _, tt := range tests {
+		origin, path, query, err := splitTarget(tt.url)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("splitTarget(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+			continue
+		}
+		if origin != tt.origin || path != tt.path || query != tt.query {
+			t.Errorf("splitTarget(%q) = %q, %q, %q, want %q, %q, %q", tt.url, origin, path, query, tt.origin, tt.path, tt.query)
+		}
+	}
+}
+
+func TestTechniquesDropFragment(t *testing.T) {
+	tests := []struct {
+		name string
+		test func(string, Config) ([]Attempt, error)
+	}{
+		{"method", TestMethodManipulation},
+		{"header", TestHeaderManipulation},
+		{"IP spoofing", TestIPSpoofingHeaders},
+		{"caching proxy", TestCachingProxyBypass},
+	}
+
+	for _, tt := range tests {
+		attempts, err := tt.test("https://example.com/admin?x=1#pasted", Config{})
+		if err != nil || len(attempts) == 0 {
+			t.Fatalf("%s: %d attempts, error %v", tt.name, len(attempts), err)
+		}
+		for _, attempt := range attempts {
+			if attempt.Request.Target != "/admin?x=1" {
+				t.Errorf("%s: %s sends %q, want %q", tt.name, attempt.Technique, attempt.Request.Target, "/admin?x=1")
+			}
+		}
+	}
+}
+
+// userConfig is the configuration of a scan with -H, -b and -auth basic
+func userConfig() Config {
+	return Config{
+		UserAgent: "bypass403",
+		Headers: []http.Header{
+			{Name: "X-Api-Key", Value: "key-1"},
+			{Name: "X-Api-Key", Value: "key-2"},
+			{Name: "Cookie", Value: "session=s3cret"},
+		},
+		Auth: http.Auth{Scheme: http.AuthBasic, Username: "admin", Password: "hunter2"},
+	}
+}
+
+func TestNewRequest(t *testing.T) {
+	req, err := newRequest("GET", "https://example.com", "/admin", userConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []http.Header{
+		{Name: "Host", Value: "example.com"},
+		{Name: "User-Agent", Value: "bypass403"},
+		{Name: "X-Api-Key", Value: "key-1"},
+		{Name: "X-Api-Key", Value: "key-2"},
+		{Name: "Cookie", Value: "session=s3cret"},
+		{Name: "Authorization", Value: "Basic YWRtaW46aHVudGVyMg=="},
+		{Name: "Connection", Value: "close"},
+	}
+	if !reflect.DeepEqual(req.Headers, want) {
+		t.Errorf("headers = %v, want %v", req.Headers, want)
+	}
+}
+
+func TestNewRequestTemplate(t *testing.T) {
+	config := userConfig()
+	config.Template = &http.Request{
+		Method: "POST",
+		Headers: []http.Header{
+			{Name: "User-Agent", Value: "app/1.0"},
+			{Name: "X-Api-Key", Value: "from-file"},
+			{Name: "Content-Type", Value: "application/json"},
+		},
+		Body: []byte(`{"id":1}`),
+	}
+
+	post, err := newRequest("POST", "https://example.com", "/admin", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The template's User-Agent is kept, -H replaces its header, and the body is carried
+	if post.Header("User-Agent") != "app/1.0" || post.Header("X-Api-Key") != "key-1" || string(post.Body) != `{"id":1}` {
+		t.Errorf("POST = %q, want the template's User-Agent and body with the -H header", post.Bytes())
+	}
+
+	get, err := newRequest("GET", "https://example.com", "/admin", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(get.Body) != 0 || get.Header("Content-Type") != "application/json" {
+		t.Errorf("GET = %q, want the template's headers without its body", get.Bytes())
+	}
+
+	config.RandomUA = true
+	random, err := newRequest("GET", "https://example.com", "/admin", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if random.Header("User-Agent") != "bypass403" {
+		t.Errorf("User-Agent = %q, want the random one over the template's", random.Header("User-Agent"))
+	}
+}
+
+func TestSetHeader(t *testing.T) {
+	tests := []struct {
+		name, value string
+		want        string
+		note        string
+	}{
+		{"Cookie", "admin=true", "session=s3cret; admin=true", ""},
+		{"X-Api-Key", "guest", "guest", " (overrides X-Api-Key)"},
+		{"Authorization", "Bearer x", "Bearer x", " (overrides Authorization)"},
+		{"X-Original-URL", "/admin", "/admin", ""},
+	}
+	for _, tt := range tests {
+		config := userConfig()
+		req, err := newRequest("GET", "https://example.com", "/", config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		note := setHeader(req, tt.name, tt.value, config)
+		if got := req.Header(tt.name); got != tt.want || note != tt.note {
+			t.Errorf("setHeader(%s: %s) = %q with note %q, want %q with note %q", tt.name, tt.value, got, note, tt.want, tt.note)
+		}
+	}
+
+	// Without the user's cookies, a technique's cookie is sent on its own
+	req, err := newRequest("GET", "https://example.com", "/", Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if note := setHeader(req, "Cookie", "admin=true", Config{}); req.Header("Cookie") != "admin=true" || note != "" {
+		t.Errorf("Cookie = %q with note %q, want admin=true", req.Header("Cookie"), note)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	config := userConfig()
+	req, err := newRequest("GET", "https://example.com", "/admin", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setHeader(req, "Cookie", "admin=true", config)
+	req.AddHeader("X-Forwarded-For", "127.0.0.1")
+	req.Upgrade = req.Clone()
+	sent := string(req.Bytes())
+
+	redacted := config.Redact(req)
+	for _, secret := range []string{"key-1", "key-2", "s3cret", "YWRtaW46aHVudGVyMg=="} {
+		if strings.Contains(string(redacted.Bytes()), secret) || strings.Contains(string(redacted.Upgrade.Bytes()), secret) {
+			t.Errorf("redacted request still carries %q:\n%s", secret, redacted.Bytes())
+		}
+	}
+	checks := map[string]string{
+		"Cookie":          Redacted + "; admin=true",
+		"Authorization":   Redacted,
+		"X-Api-Key":       Redacted,
+		"X-Forwarded-For": "127.0.0.1",
+	}
+	for name, want := range checks {
+		if got := redacted.Header(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if string(req.Bytes()) != sent {
+		t.Errorf("Redact changed the request that was sent")
+	}
+
+	if config.Redact(nil) != nil {
+		t.Errorf("Redact(nil) != nil")
+	}
+	if plain := (Config{}).Redact(req); plain != req {
+		t.Errorf("Redact without user values copied the request")
+	}
+}
+
+func TestRedactLongestFirst(t *testing.T) {
+	config := Config{Headers: []http.Header{{Name: "X-Token", Value: "abc"}, {Name: "X-Token", Value: "abcdef"}}}
+	req, err := newRequest("GET", "https://example.com", "/", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, h := range config.Redact(req).Headers {
+		if h.Name == "X-Token" && h.Value != Redacted {
+			t.Errorf("X-Token = %q, want %q", h.Value, Redacted)
+		}
+	}
+}
+
+func TestRedactTemplate(t *testing.T) {
+	config := Config{Template: &http.Request{Headers: []http.Header{
+		{Name: "Cookie", Value: "sid=from-file"},
+		{Name: "Authorization", Value: "Bearer from-file"},
+		{Name: "Accept", Value: "text/html"},
+	}}}
+	req, err := newRequest("GET", "https://example.com", "/", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redacted := config.Redact(req)
+	if redacted.Header("Cookie") != Redacted || redacted.Header("Authorization") != Redacted {
+		t.Errorf("request file credentials kept:\n%s", redacted.Bytes())
+	}
+	if redacted.Header("Accept") != "text/html" {
+		t.Errorf("Accept = %q, want the request file's other headers kept", redacted.Header("Accept"))
+	}
+}
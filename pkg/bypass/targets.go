@@ -0,0 +1,33 @@
+package bypass
+
+// TargetResults are the results recorded for one scanned target
+type TargetResults struct {
+	Target  string
+	Results []Result
+}
+
+// GroupByTarget groups results by the target they were recorded for, keeping
+// their order within each target. Groups follow the order of targets, with
+// one for every target even if it has no results; results for targets not in
+// the list follow in the order they were first seen.
+func GroupByTarget(targets []string, results []Result) []TargetResults {
+	index := make(map[string]int)
+	var groups []TargetResults
+	add := func(target string) int {
+		if i, ok := index[target]; ok {
+			return i
+		}
+		index[target] = len(groups)
+		groups = append(groups, TargetResults{Target: target})
+		return len(groups) - 1
+	}
+
+	for _, target := range targets {
+		add(target)
+	}
+	for _, result := range results {
+		i := add(result.Target)
+		groups[i].Results = append(groups[i].Results, result)
+	}
+	return groups
+}
@@ -1,15 +1,93 @@
 package bypass
 
 import (
-	"net/http"
+	"time"
+
+	"github.com/ibrahimsql/bypass403/pkg/http"
 )
 
+// BodySampleSize is the number of response body bytes kept as evidence for each attempt
+const BodySampleSize = 2048
+
 // Result represents the result of a bypass attempt
 type Result struct {
+	// Target is the scanned URL the attempt was made for, and URL the URL requested
+	Target     string
 	URL        string
 	StatusCode int
 	Method     string
 	Technique  string
+	Category   string
+	Fingerprint
+
+	// Request is the exact request sent, and Response the evidence of what came back
+	Request  *http.Request
+	Response ResponseEvidence
+
+	// Classification and Confidence are set by comparing the response with the baseline
+	Classification string
+	Confidence     int
+
+	// Throttled is set when the target kept rate limiting or dropping the
+	// request after every retry, so the attempt is inconclusive
+	Throttled bool
+
+	// UpgradeRefused is set when the server answered an h2c upgrade itself
+	// instead of switching protocols. The response is the answer to the
+	// upgrade request, on the allowed path, so it is never a bypass.
+	UpgradeRefused bool
+
+	// Matched is set once the runner's match and filter rules accept the result as a bypass
+	Matched bool
+}
+
+// ResponseEvidence holds the response metadata recorded for an attempt
+type ResponseEvidence struct {
+	Proto      string
+	Status     string
+	Headers    map[string][]string
+	BodySample []byte
+	Truncated  bool
+	// Body is the full response body, kept only until the result has been
+	// matched so that body rules see all of it
+	Body []byte
+	// Raw is the response as read from the wire, kept only for matched results
+	// like Body
+	Raw        []byte
+	Time       time.Duration
+	Location   string
+	RemoteAddr string
+	Started    time.Time
+	Timing     http.Timing
+}
+
+// newResponseEvidence records the evidence of a raw response, keeping only a sample of the body
+func newResponseEvidence(resp *http.Response) ResponseEvidence {
+	evidence := ResponseEvidence{
+		Proto:      resp.Proto,
+		Status:     resp.Status,
+		Headers:    resp.Header,
+		Time:       resp.Duration,
+		Location:   resp.Header.Get("Location"),
+		Body:       resp.Body,
+		Raw:        resp.Raw,
+		RemoteAddr: resp.RemoteAddr,
+		Started:    resp.Started,
+		Timing:     resp.Timing,
+	}
+
+	evidence.BodySample = resp.Body
+	if len(resp.Body) > BodySampleSize {
+		evidence.BodySample = resp.Body[:BodySampleSize]
+		evidence.Truncated = true
+	}
+
+	return evidence
+}
+
+// IsBypass reports whether the result was accepted as a bypass by the match rules
+func (r Result) IsBypass() bool {
+	return r.Matched
 }
 
 // Config represents configuration options for bypass techniques
@@ -19,12 +97,37 @@ type Config struct {
 	WordlistPath string
 	Verbose      bool
 	RandomUA     bool
+	Baseline     *Baseline
+	// OriginIPsPath is a list of candidate origin server addresses, one per line
+	OriginIPsPath string
+	// Headers are the user's -H headers and -b cookies, and Auth the -auth
+	// credentials, sent with every request. Techniques that replace one of
+	// them say so, see setHeader.
+	Headers []http.Header
+	Auth    http.Auth
+	// Template is the request loaded with -r. Its method, headers and body
+	// are the base of every request, in place of a bare GET.
+	Template *http.Request
+}
+
+// Attempt is a single request a technique wants sent. Techniques only build
+// attempts; the runner decides when and how concurrently they are sent.
+type Attempt struct {
+	Request   *http.Request
+	Technique string
+	// Category is the category of the technique that produced the attempt,
+	// and Target the scanned URL it was made for, both filled in by the runner
+	Category string
+	Target   string
+	// FollowUp, if set, is called with the result of this attempt and returns
+	// further attempts to send, e.g. retrying a promising path with POST
+	FollowUp func(Result) []Attempt
 }
 
 // Technique represents a bypass technique
 type Technique struct {
 	Name     string
-	Test     func(string, *http.Client, Config) ([]Result, error)
+	Test     func(string, Config) ([]Attempt, error)
 	Category string
 }
 
@@ -42,5 +145,8 @@ func GetTechniques() []Technique {
 		{"Specialized Payloads", TestPayloads, "Specialized"},
 		{"Wordlist Path Bypass", TestWordlistPathBypass, "Wordlist"},
 		{"Combined Technique Bypass", TestCombinedBypass, "Combined"},
+		{"Origin IP", TestOriginIP, "Origin IP"},
+		{"HTTP/2", TestHTTP2, "HTTP/2"},
+		{"h2c Upgrade Smuggling", TestH2CSmuggling, "h2c Smuggling"},
 	}
 }
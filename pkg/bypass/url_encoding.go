@@ -1,20 +1,18 @@
 package bypass
 
 import (
-	"net/http"
 	"net/url"
 	"strings"
 )
 
 // TestURLEncodingBypass tests URL encoding techniques to bypass 403 responses
-func TestURLEncodingBypass(baseURL string, client *http.Client, config Config) ([]Result, error) {
-	var results []Result
-	parsedURL, err := url.Parse(baseURL)
+func TestURLEncodingBypass(baseURL string, config Config) ([]Attempt, error) {
+	var attempts []Attempt
+	origin, originalPath, query, err := splitTarget(baseURL)
 	if err != nil {
-		return results, err
+		return attempts, err
 	}
 
-	originalPath := parsedURL.Path
 	encodedPaths := []string{
 		strings.ReplaceAll(originalPath, "/", "%2f"),
 		strings.ReplaceAll(originalPath, "/", "%252f"),
@@ -37,31 +35,15 @@ func TestURLEncodingBypass(baseURL string, client *http.Client, config Config) (
 	}
 
 	for _, path := range encodedPaths {
-		manipulatedURL := *parsedURL
-		manipulatedURL.Path = path
-
-		req, err := http.NewRequest("GET", manipulatedURL.String(), nil)
-		if err != nil {
-			continue
-		}
-
-		req.Header.Set("User-Agent", config.UserAgent)
-
-		resp, err := client.Do(req)
+		req, err := newRequest(config.method(), origin, withQuery(path, query), config)
 		if err != nil {
 			continue
 		}
-		resp.Body.Close()
 
-		results = append(results, Result{
-			URL:        manipulatedURL.String(),
-			StatusCode: resp.StatusCode,
-			Method:     "GET",
-			Technique:  "URL Encoding",
-		})
+		attempts = append(attempts, Attempt{Request: req, Technique: "URL Encoding"})
 	}
 
-	return results, nil
+	return attempts, nil
 }
 
 // encodePath replaces a specific character with its encoded version
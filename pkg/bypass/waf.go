@@ -0,0 +1,76 @@
+package bypass
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/ibrahimsql/bypass403/pkg/http"
+)
+
+// wafSignature recognises a WAF, CDN or load balancer by the headers, cookies
+// or block page it adds to responses
+type wafSignature struct {
+	Name string
+	// Headers are header names whose presence is enough
+	Headers []string
+	// Server matches the Server header
+	Server *regexp.Regexp
+	// Cookies are prefixes of the names of cookies it sets
+	Cookies []string
+	// Body matches its block page
+	Body *regexp.Regexp
+}
+
+var wafSignatures = []wafSignature{
+	{Name: "Cloudflare", Headers: []string{"CF-Ray", "CF-Cache-Status"}, Server: regexp.MustCompile(`(?i)^cloudflare`), Cookies: []string{"__cf_bm", "__cfruid", "cf_clearance"}},
+	{Name: "Akamai", Headers: []string{"X-Akamai-Transformed", "Akamai-GRN"}, Server: regexp.MustCompile(`(?i)akamaighost`), Cookies: []string{"ak_bmsc", "bm_sz", "_abck"}, Body: regexp.MustCompile(`(?i)reference #\d+\.[0-9a-f]+\.\d+`)},
+	{Name: "Amazon CloudFront", Headers: []string{"X-Amz-Cf-Id", "X-Amz-Cf-Pop"}, Server: regexp.MustCompile(`(?i)^cloudfront`)},
+	{Name: "AWS WAF / ELB", Server: regexp.MustCompile(`(?i)^awselb`), Cookies: []string{"AWSALB", "aws-waf-token"}},
+	{Name: "Imperva Incapsula", Headers: []string{"X-Iinfo"}, Cookies: []string{"incap_ses", "visid_incap", "nlbi_"}, Body: regexp.MustCompile(`(?i)incapsula incident id`)},
+	{Name: "Sucuri", Headers: []string{"X-Sucuri-ID", "X-Sucuri-Cache"}, Server: regexp.MustCompile(`(?i)sucuri`)},
+	{Name: "F5 BIG-IP", Server: regexp.MustCompile(`(?i)big-?ip`), Cookies: []string{"BIGipServer", "TS01", "F5_"}, Body: regexp.MustCompile(`(?i)the requested url was rejected\. please consult with your administrator`)},
+	{Name: "Fastly", Headers: []string{"X-Fastly-Request-ID", "Fastly-Debug-Digest"}},
+	{Name: "Azure Front Door", Headers: []string{"X-Azure-Ref"}, Body: regexp.MustCompile(`(?i)azure front door`)},
+	{Name: "Google Cloud", Server: regexp.MustCompile(`(?i)^(gfe|google frontend)`)},
+	{Name: "Barracuda", Cookies: []string{"barra_counter_session", "BNI__BARRACUDA_LB_COOKIE"}},
+	{Name: "FortiWeb", Cookies: []string{"FORTIWAFSID"}, Body: regexp.MustCompile(`(?i)fortiweb|fortigate`)},
+	{Name: "Citrix NetScaler", Headers: []string{"Cneonction", "nnCoection"}, Cookies: []string{"ns_af", "citrix_ns_id", "NSC_"}},
+	{Name: "ModSecurity", Server: regexp.MustCompile(`(?i)mod_security`), Body: regexp.MustCompile(`(?i)mod_?security|this error was generated by mod_security`)},
+	{Name: "Wordfence", Body: regexp.MustCompile(`(?i)generated by wordfence|wordfence-blocked`)},
+	{Name: "StackPath", Headers: []string{"X-SP-URL", "X-SP-WL"}},
+}
+
+// DetectWAF names the WAFs, CDNs and load balancers that a response carries
+// the marks of
+func DetectWAF(resp *http.Response) []string {
+	var names []string
+	server := resp.Header.Get("Server")
+	cookies := resp.Header.Values("Set-Cookie")
+
+	for _, sig := range wafSignatures {
+		if sig.matches(resp, server, cookies) {
+			names = append(names, sig.Name)
+		}
+	}
+	return names
+}
+
+// matches reports whether the response carries any mark of the signature
+func (s wafSignature) matches(resp *http.Response, server string, cookies []string) bool {
+	for _, name := range s.Headers {
+		if resp.Header.Get(name) != "" {
+			return true
+		}
+	}
+	if s.Server != nil && s.Server.MatchString(server) {
+		return true
+	}
+	for _, cookie := range cookies {
+		for _, prefix := range s.Cookies {
+			if strings.HasPrefix(strings.TrimSpace(cookie), prefix) {
+				return true
+			}
+		}
+	}
+	return s.Body != nil && s.Body.Match(resp.Body)
+}
@@ -1,19 +1,15 @@
 package bypass
 
 import (
-	"net/http"
-	"net/url"
-	"path/filepath"
-
 	"github.com/ibrahimsql/bypass403/pkg/wordlist"
 )
 
 // TestWordlistPathBypass tests bypass paths from a wordlist
-func TestWordlistPathBypass(baseURL string, client *http.Client, config Config) ([]Result, error) {
-	var results []Result
-	parsedURL, err := url.Parse(baseURL)
+func TestWordlistPathBypass(baseURL string, config Config) ([]Attempt, error) {
+	var attempts []Attempt
+	origin, originalPath, _, err := splitTarget(baseURL)
 	if err != nil {
-		return results, err
+		return attempts, err
 	}
 
 	// Load wordlist
@@ -24,64 +20,29 @@ func TestWordlistPathBypass(baseURL string, client *http.Client, config Config)
 			payloads = wordlist.GetDefaultPayloads()
 		} else {
 			// Return empty results but don't break execution
-			return results, nil
+			return attempts, nil
 		}
 	}
 
-	baseDir := filepath.Dir(parsedURL.Path)
-	if baseDir == "." || baseDir == "/" {
-		baseDir = ""
-	}
+	// Payloads are appended to the directory as written, so "/..;/" or "//"
+	// are sent instead of being cleaned away by a path join
+	baseDir := parentDir(originalPath)
 
 	for _, payload := range payloads {
 		// Try with base path + payload
-		manipulatedURL := *parsedURL
-		manipulatedURL.Path = filepath.Join(baseDir, payload)
-
-		req, err := http.NewRequest("GET", manipulatedURL.String(), nil)
-		if err != nil {
-			continue
-		}
+		manipulatedPath := baseDir + payload
 
-		req.Header.Set("User-Agent", config.UserAgent)
-
-		resp, err := client.Do(req)
+		req, err := newRequest(config.method(), origin, manipulatedPath, config)
 		if err != nil {
 			continue
 		}
-		resp.Body.Close()
 
-		results = append(results, Result{
-			URL:        manipulatedURL.String(),
-			StatusCode: resp.StatusCode,
-			Method:     "GET",
-			Technique:  "Wordlist Path: " + payload,
+		attempts = append(attempts, Attempt{
+			Request:   req,
+			Technique: "Wordlist Path: " + payload,
+			FollowUp:  wordlistPostFollowUp(origin, manipulatedPath, payload, config),
 		})
 
-		// If we found a successful bypass and it's not a 403 or 404 response, try with POST too
-		if resp.StatusCode != 403 && resp.StatusCode != 404 {
-			postReq, err := http.NewRequest("POST", manipulatedURL.String(), nil)
-			if err != nil {
-				continue
-			}
-
-			postReq.Header.Set("User-Agent", config.UserAgent)
-			postReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-			postResp, err := client.Do(postReq)
-			if err != nil {
-				continue
-			}
-			postResp.Body.Close()
-
-			results = append(results, Result{
-				URL:        manipulatedURL.String(),
-				StatusCode: postResp.StatusCode,
-				Method:     "POST",
-				Technique:  "Wordlist Path: " + payload,
-			})
-		}
-
 		// Try also adding query parameters and fragments
 		queryManipulations := []string{
 			"?id=1",
@@ -93,30 +54,35 @@ func TestWordlistPathBypass(baseURL string, client *http.Client, config Config)
 		}
 
 		for _, queryParam := range queryManipulations {
-			queryURL := manipulatedURL
-			queryURL.RawQuery = queryParam[1:]
-
-			queryReq, err := http.NewRequest("GET", queryURL.String(), nil)
+			queryReq, err := newRequest(config.method(), origin, manipulatedPath+queryParam, config)
 			if err != nil {
 				continue
 			}
 
-			queryReq.Header.Set("User-Agent", config.UserAgent)
+			attempts = append(attempts, Attempt{Request: queryReq, Technique: "Wordlist Path + Query: " + payload + queryParam})
+		}
+	}
 
-			queryResp, err := client.Do(queryReq)
-			if err != nil {
-				continue
-			}
-			queryResp.Body.Close()
+	return attempts, nil
+}
 
-			results = append(results, Result{
-				URL:        queryURL.String(),
-				StatusCode: queryResp.StatusCode,
-				Method:     "GET",
-				Technique:  "Wordlist Path + Query: " + payload + queryParam,
-			})
+// wordlistPostFollowUp retries a wordlist path with POST when the GET response
+// differs from the blocked baseline
+func wordlistPostFollowUp(origin, manipulatedPath, payload string, config Config) func(Result) []Attempt {
+	return func(result Result) []Attempt {
+		if !config.promising(result) {
+			return nil
+		}
+
+		postReq, err := newRequest("POST", origin, manipulatedPath, config)
+		if err != nil {
+			return nil
+		}
+
+		if postReq.Header("Content-Type") == "" {
+			postReq.SetHeader("Content-Type", "application/x-www-form-urlencoded")
 		}
-	}
 
-	return results, nil
+		return []Attempt{{Request: postReq, Technique: "Wordlist Path: " + payload}}
+	}
 }
@@ -2,16 +2,43 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ibrahimsql/bypass403/pkg/bypass"
+	"github.com/ibrahimsql/bypass403/pkg/http"
+	"github.com/ibrahimsql/bypass403/pkg/matcher"
+	"github.com/ibrahimsql/bypass403/pkg/rawrequest"
+	"github.com/ibrahimsql/bypass403/pkg/snippet"
+	"github.com/ibrahimsql/bypass403/pkg/targets"
 )
 
 // Config holds all configuration options for bypass403
 type Config struct {
-	// Required parameters
-	URL string
+	// Required parameters: a URL, a list of targets, or both. TargetList
+	// is a file or "-" for standard input, in the TargetFormat format.
+	URL          string
+	TargetList   string
+	TargetFormat string
+
+	// Headers are the -H headers ("Name: value"), Cookie the -b cookies, and
+	// Auth the -auth scheme with its AuthCredentials, all sent with every request
+	Headers         []string
+	Cookie          string
+	Auth            string
+	AuthCredentials string
+
+	// RequestFile is a raw HTTP request or Burp Suite export used as the base
+	// request. Without a URL or target list, its own URL is the target.
+	RequestFile string
 
 	// Optional parameters
 	Threads         int
+	HostThreads     int
 	OutputFile      string
 	Timeout         int
 	Verbose         bool
@@ -22,27 +49,94 @@ type Config struct {
 	RandomUserAgent bool
 	UserAgentType   string
 	BurpOutput      string
+	JSONOutput      string
+	JSONLOutput     string
+	SARIFOutput     string
+	HTMLOutput      string
+	HAROutput       string
+	ReportOutput    string
+	NucleiOutput    string
+	HARBypassesOnly bool
 	Version         bool
+
+	// NoRedact keeps the user's credentials and -H values in the reports
+	NoRedact bool
+
+	// Snippets is the comma-separated list of reproduction snippet formats
+	Snippets string
+
+	// Preflight is the policy for targets that don't answer 401 or 403: skip,
+	// blocked or continue. Ask prompts for them instead, on a terminal only.
+	Preflight string
+	Ask       bool
+
+	// Proxy is the upstream proxy for every request, falling back to the
+	// HTTP_PROXY environment. ReplayProxy, if set, receives only the confirmed
+	// bypasses once the scan is done.
+	Proxy       string
+	ReplayProxy string
+
+	// Resolve is a comma-separated list of host:port:address overrides, SNI
+	// the TLS server name sent instead of the target's, and OriginIPsPath a
+	// list of candidate origin servers for the Origin IP technique
+	Resolve       string
+	SNI           string
+	OriginIPsPath string
+
+	// HTTP2 sends requests over HTTP/2: "alpn" or "prior-knowledge"
+	HTTP2 string
+
+	// TLS verification and client certificates
+	TLSVerify     bool
+	CACert        string
+	ClientCert    string
+	ClientKey     string
+	CertPassword  string
+	TLSMinVersion string
+
+	// Match and filter rules deciding what counts as a bypass
+	Match matcher.Options
+
+	// Request timing. Profile supplies the defaults; any non-zero value overrides it.
+	Profile  string
+	Rate     float64
+	HostRate float64
+	Burst    int
+	Jitter   int
+
+	// Back-off when the target throttles. Backoff is in milliseconds,
+	// MaxBackoff and BreakerCooldown in seconds.
+	Retries          int
+	Backoff          int
+	MaxBackoff       int
+	BreakerThreshold int
+	BreakerCooldown  int
 }
 
 // NewDefaultConfig returns a Config with default values
 func NewDefaultConfig() *Config {
 	return &Config{
-		Threads:         10,
-		Timeout:         10,
-		UserAgent:       "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36",
-		WordlistPath:    "payloads/bypasses.txt",
-		RandomUserAgent: false,
-		AllTechniques:   false,
-		Verbose:         false,
+		Threads:          10,
+		Timeout:          10,
+		Retries:          3,
+		Backoff:          1000,
+		MaxBackoff:       60,
+		BreakerThreshold: 10,
+		BreakerCooldown:  30,
+		Preflight:        bypass.PolicySkip,
+		UserAgent:        "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36",
+		WordlistPath:     "payloads/bypasses.txt",
+		RandomUserAgent:  false,
+		AllTechniques:    false,
+		Verbose:          false,
 	}
 }
 
 // Validate checks if the configuration is valid
 func (c *Config) Validate() error {
 	// Check if URL is provided
-	if c.URL == "" && !c.Version {
-		return errors.New("URL is required")
+	if c.URL == "" && c.TargetList == "" && c.RequestFile == "" && !c.Version {
+		return errors.New("URL, target list or request file is required")
 	}
 
 	// If URL is provided, validate it
@@ -53,15 +147,233 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	// Validate the target list, which is only read when the scan starts
+	if c.TargetList != "" && c.TargetList != "-" {
+		if _, err := os.Stat(c.TargetList); err != nil {
+			return fmt.Errorf("target list: %s", err)
+		}
+	}
+	if c.TargetFormat != "" && !slices.Contains(targets.Formats, c.TargetFormat) {
+		return fmt.Errorf("unknown target list format %q (valid: %s)", c.TargetFormat, strings.Join(targets.Formats, ", "))
+	}
+
+	// Validate the request file, parsing it
+	if _, err := c.Template(); err != nil {
+		return err
+	}
+
+	// Validate the global headers and credentials
+	if _, err := c.RequestHeaders(); err != nil {
+		return err
+	}
+	if _, err := c.AuthConfig(); err != nil {
+		return err
+	}
+
 	// Validate threads
 	if c.Threads < 1 {
 		return errors.New("threads must be at least 1")
 	}
+	if c.HostThreads < 0 {
+		return errors.New("per-host threads cannot be negative")
+	}
 
 	// Validate timeout
 	if c.Timeout < 1 {
 		return errors.New("timeout must be at least 1 second")
 	}
 
+	// Validate request timing
+	if c.Rate < 0 || c.HostRate < 0 || c.Burst < 0 || c.Jitter < 0 {
+		return errors.New("rate, host rate, burst and jitter cannot be negative")
+	}
+	if _, err := c.RateLimit(); err != nil {
+		return err
+	}
+
+	// Validate back-off
+	if c.Retries < 0 || c.Backoff < 0 || c.MaxBackoff < 0 || c.BreakerThreshold < 0 || c.BreakerCooldown < 0 {
+		return errors.New("retries, back-off and circuit breaker settings cannot be negative")
+	}
+
+	// Validate match and filter rules
+	if _, err := c.Rules(); err != nil {
+		return err
+	}
+
+	// Validate proxies
+	if _, err := c.ProxyConfig(); err != nil {
+		return err
+	}
+	if c.ReplayProxy != "" {
+		if _, err := http.ParseProxy(c.ReplayProxy); err != nil {
+			return err
+		}
+	}
+
+	// Validate connect address overrides
+	if _, err := c.ResolveMap(); err != nil {
+		return err
+	}
+	if c.OriginIPsPath != "" {
+		if _, err := os.Stat(c.OriginIPsPath); err != nil {
+			return fmt.Errorf("origin IP list: %s", err)
+		}
+	}
+
+	if _, err := http.ParseHTTP2Mode(c.HTTP2); err != nil {
+		return err
+	}
+
+	// Validate TLS settings, loading the certificates
+	if _, err := c.TLSOptions().Config(); err != nil {
+		return err
+	}
+
+	// Validate the preflight policy
+	if !slices.Contains(bypass.Policies, c.Preflight) {
+		return fmt.Errorf("unknown preflight policy %q (valid: %s)", c.Preflight, strings.Join(bypass.Policies, ", "))
+	}
+
+	// Validate snippet formats
+	if _, err := c.SnippetFormats(); err != nil {
+		return err
+	}
+
 	return nil
 }
+
+// Targets returns the URLs to scan: -u followed by the entries of the target
+// list, without duplicates, or else the URL of the request file. Reading the
+// list consumes standard input if it is "-". Invalid entries of the list are
+// left out and returned in skipped.
+func (c *Config) Targets() (list []string, skipped []error, err error) {
+	if c.URL != "" {
+		list = append(list, c.URL)
+	} else if c.TargetList == "" && c.RequestFile != "" {
+		template, err := c.Template()
+		if err != nil {
+			return nil, nil, err
+		}
+		list = append(list, template.URL())
+	}
+	if c.TargetList != "" {
+		var loaded []string
+		loaded, skipped, err = targets.Load(c.TargetList, c.TargetFormat)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, target := range loaded {
+			if target != c.URL {
+				list = append(list, target)
+			}
+		}
+	}
+	if len(list) == 0 {
+		return nil, skipped, errors.New("no targets to scan")
+	}
+	return list, skipped, nil
+}
+
+// Template loads the request file, nil if there is none
+func (c *Config) Template() (*http.Request, error) {
+	if c.RequestFile == "" {
+		return nil, nil
+	}
+	return rawrequest.Load(c.RequestFile)
+}
+
+// RequestHeaders parses the -H headers, followed by the -b cookies as a Cookie header
+func (c *Config) RequestHeaders() ([]http.Header, error) {
+	var headers []http.Header
+	for _, h := range c.Headers {
+		name, value, ok := strings.Cut(h, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid header %q, expected \"Name: value\"", h)
+		}
+		headers = append(headers, http.Header{Name: name, Value: strings.TrimSpace(value)})
+	}
+	if c.Cookie != "" {
+		headers = append(headers, http.Header{Name: "Cookie", Value: c.Cookie})
+	}
+	return headers, nil
+}
+
+// AuthConfig parses the -auth scheme and credentials
+func (c *Config) AuthConfig() (http.Auth, error) {
+	if c.Auth == "" && c.AuthCredentials != "" {
+		return http.Auth{}, errors.New("-auth-cred needs an -auth scheme")
+	}
+	return http.ParseAuth(c.Auth, c.AuthCredentials)
+}
+
+// ProxyConfig resolves the upstream proxy from -proxy or the environment
+func (c *Config) ProxyConfig() (http.ProxyConfig, error) {
+	return http.NewProxyConfig(c.Proxy)
+}
+
+// ResolveMap parses the -resolve overrides
+func (c *Config) ResolveMap() (map[string]string, error) {
+	return http.ParseResolve(strings.Split(c.Resolve, ","))
+}
+
+// TLSOptions returns the certificate verification and client certificate settings
+func (c *Config) TLSOptions() http.TLSOptions {
+	return http.TLSOptions{
+		Verify:       c.TLSVerify,
+		CAFile:       c.CACert,
+		CertFile:     c.ClientCert,
+		KeyFile:      c.ClientKey,
+		CertPassword: c.CertPassword,
+		MinVersion:   c.TLSMinVersion,
+	}
+}
+
+// SnippetFormats returns the reproduction snippet formats to emit
+func (c *Config) SnippetFormats() ([]string, error) {
+	return snippet.ParseFormats(c.Snippets)
+}
+
+// Rules builds the match and filter rules deciding what counts as a bypass
+func (c *Config) Rules() (*matcher.Rules, error) {
+	return matcher.New(c.Match)
+}
+
+// RateLimit resolves the timing profile and explicit overrides into a rate limit
+func (c *Config) RateLimit() (http.RateLimit, error) {
+	var rl http.RateLimit
+	if c.Profile != "" {
+		profile, err := http.GetProfile(c.Profile)
+		if err != nil {
+			return rl, err
+		}
+		rl = profile
+	}
+
+	if c.Rate > 0 {
+		rl.RPS = c.Rate
+	}
+	if c.HostRate > 0 {
+		rl.HostRPS = c.HostRate
+	}
+	if c.Burst > 0 {
+		rl.Burst = c.Burst
+	}
+	if c.Jitter > 0 {
+		rl.Jitter = time.Duration(c.Jitter) * time.Millisecond
+	}
+
+	return rl, nil
+}
+
+// RetryPolicy builds the back-off and circuit breaker settings for throttled requests
+func (c *Config) RetryPolicy() http.RetryPolicy {
+	policy := http.DefaultRetryPolicy()
+	policy.MaxRetries = c.Retries
+	policy.BaseDelay = time.Duration(c.Backoff) * time.Millisecond
+	policy.MaxDelay = time.Duration(c.MaxBackoff) * time.Second
+	policy.BreakerThreshold = c.BreakerThreshold
+	policy.BreakerCooldown = time.Duration(c.BreakerCooldown) * time.Second
+	return policy
+}
@@ -0,0 +1,409 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/md4"
+)
+
+// Authentication schemes
+const (
+	AuthBasic  = "basic"
+	AuthBearer = "bearer"
+	AuthDigest = "digest"
+	AuthNTLM   = "ntlm"
+)
+
+// AuthSchemes are the supported authentication schemes
+var AuthSchemes = []string{AuthBasic, AuthBearer, AuthDigest, AuthNTLM}
+
+// Auth holds the credentials every request is authenticated with. Basic and
+// Bearer are a fixed Authorization header; Digest and NTLM answer the
+// server's challenge, see SetAuth.
+type Auth struct {
+	Scheme   string
+	Username string
+	Password string
+	// Domain is the NTLM domain, given as DOMAIN\user
+	Domain string
+	// Token is the Bearer token
+	Token string
+}
+
+// ParseAuth parses the credentials of a scheme: user:pass for basic and
+// digest, [DOMAIN\]user:pass for ntlm, and the token for bearer
+func ParseAuth(scheme, credentials string) (Auth, error) {
+	auth := Auth{Scheme: strings.ToLower(scheme)}
+
+	switch auth.Scheme {
+	case "":
+		return auth, nil
+	case AuthBearer:
+		if credentials == "" {
+			return auth, fmt.Errorf("bearer authentication needs a token")
+		}
+		auth.Token = credentials
+		return auth, nil
+	case AuthBasic, AuthDigest, AuthNTLM:
+	default:
+		return auth, fmt.Errorf("unknown authentication scheme %q (valid: %s)", scheme, strings.Join(AuthSchemes, ", "))
+	}
+
+	user, pass, ok := strings.Cut(credentials, ":")
+	if !ok || user == "" {
+		return auth, fmt.Errorf("%s authentication needs user:pass credentials", auth.Scheme)
+	}
+	auth.Username, auth.Password = user, pass
+	if auth.Scheme == AuthNTLM {
+		if domain, name, ok := strings.Cut(user, `\`); ok {
+			auth.Domain, auth.Username = domain, name
+		}
+	}
+	return auth, nil
+}
+
+// Header returns the Authorization header of the fixed schemes, Basic and
+// Bearer, and "" for the schemes answering a challenge
+func (a Auth) Header() string {
+	switch a.Scheme {
+	case AuthBasic:
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(a.Username+":"+a.Password))
+	case AuthBearer:
+		return "Bearer " + a.Token
+	}
+	return ""
+}
+
+// SetAuth makes the client answer Digest and NTLM challenges with the
+// credentials. Requests that already carry an Authorization header, and
+// anonymous requests, are sent as they are. The fixed schemes are left to
+// whoever builds the requests.
+func (c *Client) SetAuth(auth Auth) {
+	c.auth = auth
+	c.digest = make(map[string]*digestChallenge)
+}
+
+// digestChallenge is the last Digest challenge of an origin, reused for
+// every request to it with an increasing nonce count
+type digestChallenge struct {
+	mu     sync.Mutex
+	params map[string]string
+	count  int
+}
+
+// roundTripAuth sends the request, answering a Digest challenge if the
+// client has Digest credentials. The last challenge of the origin is answered
+// up front, so a fresh one costs an extra request only when it changes.
+func (c *Client) roundTripAuth(req *Request) (*Response, error) {
+	if c.auth.Scheme != AuthDigest || req.Anonymous || req.Header("Authorization") != "" || req.Upgrade != nil {
+		return c.roundTrip(req)
+	}
+
+	first := req
+	if authed := c.digestRequest(req); authed != nil {
+		first = authed
+	}
+	resp, err := c.roundTrip(first)
+	if err != nil || !c.storeDigestChallenge(req.Origin, resp) {
+		return resp, err
+	}
+	return c.roundTrip(c.digestRequest(req))
+}
+
+// storeDigestChallenge records the Digest challenge of a 401 response and
+// reports whether there was one
+func (c *Client) storeDigestChallenge(origin string, resp *Response) bool {
+	if resp.StatusCode != 401 {
+		return false
+	}
+	params := authChallenge(resp, "Digest")
+	if params == nil || params["nonce"] == "" {
+		return false
+	}
+
+	c.authMu.Lock()
+	c.digest[origin] = &digestChallenge{params: params}
+	c.authMu.Unlock()
+	return true
+}
+
+// digestRequest returns a copy of the request answering the origin's last
+// Digest challenge, or nil if it hasn't sent one yet
+func (c *Client) digestRequest(req *Request) *Request {
+	c.authMu.Lock()
+	challenge := c.digest[req.Origin]
+	c.authMu.Unlock()
+	if challenge == nil {
+		return nil
+	}
+
+	challenge.mu.Lock()
+	challenge.count++
+	count := challenge.count
+	challenge.mu.Unlock()
+
+	p := challenge.params
+	algorithm := p["algorithm"]
+	if algorithm == "" {
+		algorithm = "MD5"
+	}
+	newHash := md5.New
+	if strings.HasPrefix(strings.ToUpper(algorithm), "SHA-256") {
+		newHash = sha256.New
+	}
+	h := func(s string) string {
+		sum := newHash()
+		sum.Write([]byte(s))
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+
+	nc := fmt.Sprintf("%08x", count)
+	cnonce := randomHex(8)
+	ha1 := h(c.auth.Username + ":" + p["realm"] + ":" + c.auth.Password)
+	if strings.HasSuffix(strings.ToLower(algorithm), "-sess") {
+		ha1 = h(ha1 + ":" + p["nonce"] + ":" + cnonce)
+	}
+	ha2 := h(req.Method + ":" + req.Target)
+
+	qop := ""
+	for _, q := range strings.Split(p["qop"], ",") {
+		if strings.TrimSpace(q) == "auth" {
+			qop = "auth"
+		}
+	}
+	var response string
+	if qop != "" {
+		response = h(ha1 + ":" + p["nonce"] + ":" + nc + ":" + cnonce + ":" + qop + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + p["nonce"] + ":" + ha2)
+	}
+
+	value := fmt.Sprintf(`Digest username=%q, realm=%q, nonce=%q, uri=%q, algorithm=%s, response=%q`,
+		c.auth.Username, p["realm"], p["nonce"], req.Target, algorithm, response)
+	if qop != "" {
+		value += fmt.Sprintf(`, qop=%s, nc=%s, cnonce=%q`, qop, nc, cnonce)
+	}
+	if p["opaque"] != "" {
+		value += fmt.Sprintf(`, opaque=%q`, p["opaque"])
+	}
+
+	authed := req.Clone()
+	authed.SetHeader("Authorization", value)
+	return authed
+}
+
+// authChallenge returns the parameters of the response's challenge for the
+// scheme, nil if it sent none
+func authChallenge(resp *Response, scheme string) map[string]string {
+	for _, value := range resp.Header.Values("WWW-Authenticate") {
+		if len(value) < len(scheme) || !strings.EqualFold(value[:len(scheme)], scheme) {
+			continue
+		}
+		return parseAuthParams(value[len(scheme):])
+	}
+	return nil
+}
+
+// parseAuthParams parses comma-separated name=value pairs, values optionally quoted
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " ,\t")
+		eq := strings.Index(s, "=")
+		if eq <= 0 {
+			return params
+		}
+		name := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " \t")
+
+		var value strings.Builder
+		if strings.HasPrefix(s, `"`) {
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				value.WriteByte(s[i])
+			}
+			s = s[min(i+1, len(s)):]
+		} else {
+			end := strings.Index(s, ",")
+			if end == -1 {
+				end = len(s)
+			}
+			value.WriteString(strings.TrimSpace(s[:end]))
+			s = s[end:]
+		}
+		params[name] = value.String()
+	}
+}
+
+// ntlmTransaction reports whether the request is sent with an NTLM handshake
+func (c *Client) ntlmTransaction(req *Request) bool {
+	return c.auth.Scheme == AuthNTLM && !req.Anonymous && req.Header("Authorization") == ""
+}
+
+// roundTripNTLM authenticates the request with an NTLM handshake on the
+// connection: the negotiate message, the server's challenge, then the request
+// itself with the authenticate message. A server that doesn't challenge gets
+// no second request and its answer is returned.
+func (c *Client) roundTripNTLM(req *Request, conn net.Conn, timing *Timing) (*Response, error) {
+	var raw bytes.Buffer
+	r := bufio.NewReader(io.TeeReader(conn, &raw))
+
+	negotiate := req.Clone()
+	negotiate.SetHeader("Authorization", "NTLM "+base64.StdEncoding.EncodeToString(ntlmNegotiate()))
+	negotiate.SetHeader("Connection", "keep-alive")
+	mark := time.Now()
+	if _, err := conn.Write(negotiate.Bytes()); err != nil {
+		return nil, fmt.Errorf("error writing request: %w", err)
+	}
+	timing.Send = time.Since(mark)
+
+	resp, err := readHTTP1(r, &raw, req.Method, timing)
+	if err != nil || resp.StatusCode != 401 {
+		return resp, err
+	}
+	challenge := ntlmChallenge(resp)
+	if challenge == nil {
+		return resp, nil
+	}
+	authenticate, err := ntlmAuthenticate(challenge, c.auth)
+	if err != nil {
+		return nil, err
+	}
+
+	authed := req.Clone()
+	authed.SetHeader("Authorization", "NTLM "+base64.StdEncoding.EncodeToString(authenticate))
+	raw.Reset()
+	if _, err := conn.Write(authed.Bytes()); err != nil {
+		return nil, fmt.Errorf("error writing request: %w", err)
+	}
+	return readHTTP1(r, &raw, req.Method, timing)
+}
+
+// NTLM negotiate flags: Unicode, OEM, request target, NTLM, always sign,
+// extended session security, 128-bit and 56-bit
+const ntlmFlags = 0x00000001 | 0x00000002 | 0x00000004 | 0x00000200 | 0x00008000 | 0x00080000 | 0x20000000 | 0x80000000
+
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+// ntlmNegotiate builds the NTLM negotiate (type 1) message
+func ntlmNegotiate() []byte {
+	msg := make([]byte, 32)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 1)
+	binary.LittleEndian.PutUint32(msg[12:], ntlmFlags)
+	return msg
+}
+
+// ntlmChallenge returns the NTLM challenge (type 2) message of a response, if any
+func ntlmChallenge(resp *Response) []byte {
+	for _, value := range resp.Header.Values("WWW-Authenticate") {
+		if len(value) < 5 || !strings.EqualFold(value[:5], "NTLM ") {
+			continue
+		}
+		msg, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value[5:]))
+		if err == nil && len(msg) >= 32 && bytes.HasPrefix(msg, ntlmSignature) && binary.LittleEndian.Uint32(msg[8:]) == 2 {
+			return msg
+		}
+	}
+	return nil
+}
+
+// ntlmAuthenticate builds the NTLMv2 authenticate (type 3) message answering a challenge
+func ntlmAuthenticate(challenge []byte, auth Auth) ([]byte, error) {
+	flags := binary.LittleEndian.Uint32(challenge[20:])
+	serverChallenge := challenge[24:32]
+
+	var targetInfo []byte
+	if len(challenge) >= 48 {
+		length := int(binary.LittleEndian.Uint16(challenge[40:]))
+		offset := int(binary.LittleEndian.Uint32(challenge[44:]))
+		if offset+length > len(challenge) {
+			return nil, fmt.Errorf("invalid NTLM challenge")
+		}
+		targetInfo = challenge[offset : offset+length]
+	}
+
+	// NTOWFv2 and the NTLMv2 response over the client blob
+	ntHash := md4.New()
+	ntHash.Write(utf16le(auth.Password))
+	ntlmv2Hash := hmacMD5(ntHash.Sum(nil), utf16le(strings.ToUpper(auth.Username)+auth.Domain))
+
+	clientChallenge := make([]byte, 8)
+	rand.Read(clientChallenge)
+	timestamp := make([]byte, 8)
+	binary.LittleEndian.PutUint64(timestamp, uint64(time.Now().UnixNano()/100+116444736000000000))
+
+	var blob bytes.Buffer
+	blob.Write([]byte{1, 1, 0, 0, 0, 0, 0, 0})
+	blob.Write(timestamp)
+	blob.Write(clientChallenge)
+	blob.Write([]byte{0, 0, 0, 0})
+	blob.Write(targetInfo)
+	blob.Write([]byte{0, 0, 0, 0})
+
+	proof := hmacMD5(ntlmv2Hash, append(append([]byte(nil), serverChallenge...), blob.Bytes()...))
+	ntResponse := append(proof, blob.Bytes()...)
+	lmResponse := append(hmacMD5(ntlmv2Hash, append(append([]byte(nil), serverChallenge...), clientChallenge...)), clientChallenge...)
+
+	// The payload follows the 64-byte header, each field pointed to by a
+	// length, maximum length and offset
+	fields := [][]byte{lmResponse, ntResponse, utf16le(auth.Domain), utf16le(auth.Username), utf16le(""), nil}
+	msg := make([]byte, 64)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 3)
+	offset := len(msg)
+	for i, field := range fields {
+		pos := 12 + i*8
+		binary.LittleEndian.PutUint16(msg[pos:], uint16(len(field)))
+		binary.LittleEndian.PutUint16(msg[pos+2:], uint16(len(field)))
+		binary.LittleEndian.PutUint32(msg[pos+4:], uint32(offset))
+		offset += len(field)
+	}
+	binary.LittleEndian.PutUint32(msg[60:], flags&ntlmFlags)
+	for _, field := range fields {
+		msg = append(msg, field...)
+	}
+	return msg, nil
+}
+
+// hmacMD5 returns the HMAC-MD5 of data
+func hmacMD5(key, data []byte) []byte {
+	mac := hmac.New(md5.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// utf16le encodes s as UTF-16, little endian
+func utf16le(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(b[2*i:], u)
+	}
+	return b
+}
+
+// randomHex returns n random bytes, hex encoded
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,341 @@
+package http
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestParseAuth(t *testing.T) {
+	tests := []struct {
+		scheme      string
+		credentials string
+		want        Auth
+		header      string
+		wantErr     bool
+	}{
+		{"", "", Auth{}, "", false},
+		{"basic", "admin:secret", Auth{Scheme: AuthBasic, Username: "admin", Password: "secret"}, "Basic YWRtaW46c2VjcmV0", false},
+		{"Basic", "admin:", Auth{Scheme: AuthBasic, Username: "admin"}, "Basic YWRtaW46", false},
+		{"basic", "admin:pa:ss", Auth{Scheme: AuthBasic, Username: "admin", Password: "pa:ss"}, "Basic YWRtaW46cGE6c3M=", false},
+		{"bearer", "eyJ0eXAi", Auth{Scheme: AuthBearer, Token: "eyJ0eXAi"}, "Bearer eyJ0eXAi", false},
+		{"digest", "admin:secret", Auth{Scheme: AuthDigest, Username: "admin", Password: "secret"}, "", false},
+		{"ntlm", `CORP\admin:secret`, Auth{Scheme: AuthNTLM, Username: "admin", Password: "secret", Domain: "CORP"}, "", false},
+		{"ntlm", "admin:secret", Auth{Scheme: AuthNTLM, Username: "admin", Password: "secret"}, "", false},
+		{"digest", `CORP\admin:secret`, Auth{Scheme: AuthDigest, Username: `CORP\admin`, Password: "secret"}, "", false},
+		{"bearer", "", Auth{}, "", true},
+		{"basic", "admin", Auth{}, "", true},
+		{"basic", ":secret", Auth{}, "", true},
+		{"kerberos", "admin:secret", Auth{}, "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseAuth(tt.scheme, tt.credentials)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseAuth(%q, %q) error = %v, wantErr %v", tt.scheme, tt.credentials, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseAuth(%q, %q) = %+v, want %+v", tt.scheme, tt.credentials, got, tt.want)
+		}
+		if header := got.Header(); header != tt.header {
+			t.Errorf("ParseAuth(%q, %q).Header() = %q, want %q", tt.scheme, tt.credentials, header, tt.header)
+		}
+	}
+}
+
+func TestParseAuthParams(t *testing.T) {
+	tests := []struct {
+		in   string
+		want map[string]string
+	}{
+		{"", map[string]string{}},
+		{` realm="test", nonce="abc", qop="auth,auth-int"`, map[string]string{"realm": "test", "nonce": "abc", "qop": "auth,auth-int"}},
+		{`Realm=test, ALGORITHM=MD5-sess ,stale=true`, map[string]string{"realm": "test", "algorithm": "MD5-sess", "stale": "true"}},
+		{`realm="a \"quoted\" \\ realm", opaque=""`, map[string]string{"realm": `a "quoted" \ realm`, "opaque": ""}},
+		{`realm="unterminated`, map[string]string{"realm": "unterminated"}},
+		{`, ,nonce=1,garbage`, map[string]string{"nonce": "1"}},
+	}
+
+	for _, tt := range tests {
+		if got := parseAuthParams(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseAuthParams(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAuthChallenge(t *testing.T) {
+	resp := &Response{StatusCode: 401, Header: http.Header{"Www-Authenticate": {
+		`Basic realm="basic"`,
+		`digest realm="digest", nonce="n"`,
+	}}}
+
+	if got := authChallenge(resp, "Digest"); !reflect.DeepEqual(got, map[string]string{"realm": "digest", "nonce": "n"}) {
+		t.Errorf("Digest challenge = %v", got)
+	}
+	if got := authChallenge(resp, "Basic"); !reflect.DeepEqual(got, map[string]string{"realm": "basic"}) {
+		t.Errorf("Basic challenge = %v", got)
+	}
+	if got := authChallenge(resp, "Negotiate"); got != nil {
+		t.Errorf("Negotiate challenge = %v, want none", got)
+	}
+}
+
+func TestDigestRequest(t *testing.T) {
+	md5Hex := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	sha256Hex := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+
+	// The example of RFC 2617 section 3.5, whose HA1 and HA2 are known
+	const (
+		nonce = "dcd98b7102dd2f0e8b11d0f600bfb0c093"
+		ha1   = "939e7578ed9e3c518a452acee763bce9"
+		ha2   = "39aff3a2bab6126f332b942af96d3366"
+	)
+
+	tests := []struct {
+		name      string
+		challenge string
+		algorithm string
+		// response computes the expected response from the nonce count and
+		// client nonce of the request
+		response func(nc, cnonce string) string
+	}{
+		{
+			name:      "qop auth",
+			challenge: `Digest realm="testrealm@host.com", qop="auth,auth-int", nonce="` + nonce + `", opaque="5ccc069c403ebaf9f0171e9517f40e41"`,
+			algorithm: "MD5",
+			response: func(nc, cnonce string) string {
+				return md5Hex(ha1 + ":" + nonce + ":" + nc + ":" + cnonce + ":auth:" + ha2)
+			},
+		},
+		{
+			name:      "no qop",
+			challenge: `Digest realm="testrealm@host.com", nonce="` + nonce + `"`,
+			algorithm: "MD5",
+			response: func(nc, cnonce string) string {
+				return md5Hex(ha1 + ":" + nonce + ":" + ha2)
+			},
+		},
+		{
+			name:      "MD5-sess",
+			challenge: `Digest realm="testrealm@host.com", qop=auth, algorithm=MD5-sess, nonce="` + nonce + `"`,
+			algorithm: "MD5-sess",
+			response: func(nc, cnonce string) string {
+				return md5Hex(md5Hex(ha1+":"+nonce+":"+cnonce) + ":" + nonce + ":" + nc + ":" + cnonce + ":auth:" + ha2)
+			},
+		},
+		{
+			name:      "SHA-256",
+			challenge: `Digest realm="testrealm@host.com", qop="auth", algorithm=SHA-256, nonce="` + nonce + `"`,
+			algorithm: "SHA-256",
+			response: func(nc, cnonce string) string {
+				return sha256Hex(sha256Hex("Mufasa:testrealm@host.com:Circle Of Life") + ":" + nonce + ":" + nc + ":" + cnonce + ":auth:" + sha256Hex("GET:/dir/index.html"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		c := NewClient(5, "")
+		c.SetAuth(Auth{Scheme: AuthDigest, Username: "Mufasa", Password: "Circle Of Life"})
+		req, err := NewRequest("GET", "http://www.nowhere.org", "/dir/index.html")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if c.digestRequest(req) != nil {
+			t.Errorf("%s: answered a challenge before the origin sent one", tt.name)
+		}
+		resp := &Response{StatusCode: 401, Header: http.Header{"Www-Authenticate": {tt.challenge}}}
+		if !c.storeDigestChallenge(req.Origin, resp) {
+			t.Fatalf("%s: challenge %q not stored", tt.name, tt.challenge)
+		}
+
+		for _, wantNC := range []string{"00000001", "00000002"} {
+			authed := c.digestRequest(req)
+			value := authed.Header("Authorization")
+			if len(value) < 7 || value[:7] != "Digest " {
+				t.Fatalf("%s: Authorization = %q", tt.name, value)
+			}
+			params := parseAuthParams(value[7:])
+
+			nc, cnonce := params["nc"], params["cnonce"]
+			if params["qop"] != "" && nc != wantNC {
+				t.Errorf("%s: nc = %s, want %s", tt.name, nc, wantNC)
+			}
+			if params["username"] != "Mufasa" || params["uri"] != "/dir/index.html" || params["algorithm"] != tt.algorithm {
+				t.Errorf("%s: Authorization = %q", tt.name, value)
+			}
+			if want := tt.response(nc, cnonce); params["response"] != want {
+				t.Errorf("%s: response = %s, want %s", tt.name, params["response"], want)
+			}
+		}
+		if req.Header("Authorization") != "" {
+			t.Errorf("%s: the original request was modified", tt.name)
+		}
+	}
+}
+
+func TestStoreDigestChallenge(t *testing.T) {
+	tests := []struct {
+		status int
+		header string
+		want   bool
+	}{
+		{401, `Digest realm="r", nonce="n"`, true},
+		{401, `Digest realm="r"`, false},
+		{401, `Basic realm="r"`, false},
+		{403, `Digest realm="r", nonce="n"`, false},
+	}
+
+	for _, tt := range tests {
+		c := NewClient(5, "")
+		c.SetAuth(Auth{Scheme: AuthDigest, Username: "u", Password: "p"})
+		resp := &Response{StatusCode: tt.status, Header: http.Header{"Www-Authenticate": {tt.header}}}
+		if got := c.storeDigestChallenge("http://example.com", resp); got != tt.want {
+			t.Errorf("storeDigestChallenge(%d %q) = %v, want %v", tt.status, tt.header, got, tt.want)
+		}
+	}
+}
+
+// ntlmTestChallenge builds an NTLM challenge (type 2) message with the given
+// server challenge and target info
+func ntlmTestChallenge(serverChallenge, targetInfo []byte) []byte {
+	msg := make([]byte, 48)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 2)
+	binary.LittleEndian.PutUint32(msg[20:], ntlmFlags|0x00800000)
+	copy(msg[24:], serverChallenge)
+	binary.LittleEndian.PutUint16(msg[40:], uint16(len(targetInfo)))
+	binary.LittleEndian.PutUint16(msg[42:], uint16(len(targetInfo)))
+	binary.LittleEndian.PutUint32(msg[44:], 48)
+	return append(msg, targetInfo...)
+}
+
+func TestNTLMChallenge(t *testing.T) {
+	challenge := ntlmTestChallenge([]byte("12345678"), nil)
+	negotiate := ntlmNegotiate()
+
+	tests := []struct {
+		name   string
+		header []string
+		want   []byte
+	}{
+		{"challenge", []string{"NTLM " + base64.StdEncoding.EncodeToString(challenge)}, challenge},
+		{"among other schemes", []string{"Negotiate", "ntlm " + base64.StdEncoding.EncodeToString(challenge)}, challenge},
+		{"bare NTLM offer", []string{"NTLM"}, nil},
+		{"not a challenge", []string{"NTLM " + base64.StdEncoding.EncodeToString(negotiate)}, nil},
+		{"bad base64", []string{"NTLM !!!"}, nil},
+		{"short", []string{"NTLM " + base64.StdEncoding.EncodeToString(challenge[:20])}, nil},
+	}
+
+	for _, tt := range tests {
+		resp := &Response{StatusCode: 401, Header: http.Header{"Www-Authenticate": tt.header}}
+		if got := ntlmChallenge(resp); !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: ntlmChallenge = %x, want %x", tt.name, got, tt.want)
+		}
+	}
+
+	if len(negotiate) != 32 || !bytes.HasPrefix(negotiate, ntlmSignature) || binary.LittleEndian.Uint32(negotiate[8:]) != 1 {
+		t.Errorf("ntlmNegotiate = %x", negotiate)
+	}
+}
+
+func TestNTLMAuthenticate(t *testing.T) {
+	// The credentials and server challenge of MS-NLMP section 4.2.1, whose
+	// NTOWFv2 is given in section 4.2.4.1.1
+	serverChallenge := []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+	ntowfv2, _ := hex.DecodeString("0c868a403bfd7a93a3001ef22ef02e3f")
+	// MsvAvNbDomainName "Domain", then MsvAvEOL
+	targetInfo := append([]byte{0x02, 0x00, 0x0c, 0x00}, utf16le("Domain")...)
+	targetInfo = append(targetInfo, 0, 0, 0, 0)
+
+	tests := []struct {
+		name       string
+		challenge  []byte
+		targetInfo []byte
+		wantErr    bool
+	}{
+		{"with target info", ntlmTestChallenge(serverChallenge, targetInfo), targetInfo, false},
+		{"without target info", ntlmTestChallenge(serverChallenge, nil)[:32], nil, false},
+		{"target info out of bounds", ntlmTestChallenge(serverChallenge, targetInfo)[:50], nil, true},
+	}
+
+	for _, tt := range tests {
+		msg, err := ntlmAuthenticate(tt.challenge, Auth{Scheme: AuthNTLM, Username: "User", Password: "Password", Domain: "Domain"})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		if !bytes.HasPrefix(msg, ntlmSignature) || binary.LittleEndian.Uint32(msg[8:]) != 3 {
+			t.Fatalf("%s: not an authenticate message: %x", tt.name, msg)
+		}
+		field := func(i int) []byte {
+			pos := 12 + i*8
+			length := int(binary.LittleEndian.Uint16(msg[pos:]))
+			offset := int(binary.LittleEndian.Uint32(msg[pos+4:]))
+			if offset+length > len(msg) {
+				t.Fatalf("%s: field %d out of bounds", tt.name, i)
+			}
+			return msg[offset : offset+length]
+		}
+		lm, nt, domain, user := field(0), field(1), field(2), field(3)
+
+		if !bytes.Equal(domain, utf16le("Domain")) || !bytes.Equal(user, utf16le("User")) {
+			t.Errorf("%s: domain %x, user %x", tt.name, domain, user)
+		}
+
+		// NTProofStr is the HMAC of the server challenge and the blob that follows it
+		proof, blob := nt[:16], nt[16:]
+		if want := hmacMD5(ntowfv2, append(append([]byte(nil), serverChallenge...), blob...)); !bytes.Equal(proof, want) {
+			t.Errorf("%s: NTProofStr = %x, want %x", tt.name, proof, want)
+		}
+		if !bytes.Equal(blob[:8], []byte{1, 1, 0, 0, 0, 0, 0, 0}) || !bytes.Equal(blob[28:len(blob)-4], tt.targetInfo) {
+			t.Errorf("%s: blob = %x", tt.name, blob)
+		}
+
+		clientChallenge := blob[16:24]
+		if want := append(hmacMD5(ntowfv2, append(append([]byte(nil), serverChallenge...), clientChallenge...)), clientChallenge...); !bytes.Equal(lm, want) {
+			t.Errorf("%s: LMv2 response = %x, want %x", tt.name, lm, want)
+		}
+		if flags := binary.LittleEndian.Uint32(msg[60:]); flags != ntlmFlags {
+			t.Errorf("%s: flags = %08x, want %08x", tt.name, flags, uint32(ntlmFlags))
+		}
+	}
+}
+
+func TestUTF16LE(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []byte
+	}{
+		{"", []byte{}},
+		{"Ab", []byte{'A', 0, 'b', 0}},
+		{"é", []byte{0xe9, 0}},
+		{"😀", []byte{0x3d, 0xd8, 0x00, 0xde}},
+	}
+
+	for _, tt := range tests {
+		if got := utf16le(tt.in); !bytes.Equal(got, tt.want) {
+			t.Errorf("utf16le(%q) = %x, want %x", tt.in, got, tt.want)
+		}
+	}
+}
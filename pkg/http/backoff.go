@@ -0,0 +1,237 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how the client reacts when the target starts throttling
+type RetryPolicy struct {
+	// MaxRetries is the number of times a throttled request is retried
+	MaxRetries int
+	// BaseDelay is the first back-off delay, doubled on every retry
+	BaseDelay time.Duration
+	// MaxDelay caps both the back-off delay and any Retry-After the server asks for
+	MaxDelay time.Duration
+	// Statuses are the response codes treated as throttling
+	Statuses []int
+	// BreakerThreshold is the number of consecutive throttled requests that
+	// opens the circuit breaker and pauses every request
+	BreakerThreshold int
+	// BreakerCooldown is how long the breaker stays open the first time; it
+	// doubles each time the breaker trips again without a success in between
+	BreakerCooldown time.Duration
+}
+
+// DefaultRetryPolicy returns the retry policy used unless configured otherwise
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries:       3,
+		BaseDelay:        time.Second,
+		MaxDelay:         time.Minute,
+		Statuses:         []int{429, 503},
+		BreakerThreshold: 10,
+		BreakerCooldown:  30 * time.Second,
+	}
+}
+
+// ThrottledError is returned when a request was still throttled after every retry.
+// Response is the last throttled response, if the server sent one.
+type ThrottledError struct {
+	Response *Response
+	Err      error
+}
+
+func (e *ThrottledError) Error() string {
+	if e.Response != nil {
+		return fmt.Sprintf("request throttled: %s", e.Response.Status)
+	}
+	return fmt.Sprintf("request throttled: %s", e.Err)
+}
+
+func (e *ThrottledError) Unwrap() error {
+	return e.Err
+}
+
+// breaker tracks throttling across all requests of a client
+type breaker struct {
+	mu          sync.Mutex
+	consecutive int
+	successes   int
+	trips       int
+	openUntil   time.Time
+	lastNotice  time.Time
+}
+
+// SetRetryPolicy sets how throttled requests are retried
+func (c *Client) SetRetryPolicy(policy RetryPolicy) {
+	c.retry = policy
+}
+
+// SetBlockStatus records that the origin blocks requests with status, so its
+// responses with that status are answers rather than throttling, even if the
+// retry policy lists it. Other origins keep the whole policy.
+func (c *Client) SetBlockStatus(origin string, status int) {
+	c.limitMu.Lock()
+	defer c.limitMu.Unlock()
+	c.blockStatus[origin] = status
+}
+
+// notify reports a throttling event through OnEvent, if set
+func (c *Client) notify(format string, args ...interface{}) {
+	if c.OnEvent != nil {
+		c.OnEvent(fmt.Sprintf(format, args...))
+	}
+}
+
+// throttledBy reports whether a response from the origin or an error is a
+// sign of throttling, along with any delay the server asked for
+func (c *Client) throttledBy(origin string, resp *Response, err error) (bool, time.Duration) {
+	var upgrade *UpgradeError
+	if err != nil && !errors.As(err, &upgrade) {
+		return isConnectionReset(err), 0
+	}
+
+	c.limitMu.Lock()
+	blockStatus := c.blockStatus[origin]
+	c.limitMu.Unlock()
+	if resp.StatusCode == blockStatus {
+		return false, 0
+	}
+
+	for _, status := range c.retry.Statuses {
+		if resp.StatusCode == status {
+			return true, parseRetryAfter(resp.Header.Get("Retry-After"))
+		}
+	}
+
+	return false, 0
+}
+
+// maxBackoff caps the back-off delay when the retry policy sets no maximum
+const maxBackoff = time.Hour
+
+// backoff returns how long to wait before retry number attempt
+func (c *Client) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	limit := c.retry.MaxDelay
+	if limit <= 0 {
+		limit = maxBackoff
+	}
+	delay := doubled(c.retry.BaseDelay, attempt, limit)
+	if delay > 0 {
+		// Up to 25% jitter so that workers do not retry in lockstep
+		delay += time.Duration(rand.Int63n(int64(delay)/4 + 1))
+	}
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+	if c.retry.MaxDelay > 0 && delay > c.retry.MaxDelay {
+		delay = c.retry.MaxDelay
+	}
+	return delay
+}
+
+// doubled returns d doubled n times, but never more than max, stopping
+// before the shift can overflow
+func doubled(d time.Duration, n int, max time.Duration) time.Duration {
+	for i := 0; i < n && d > 0 && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// waitForBreaker blocks while the circuit breaker is open
+func (c *Client) waitForBreaker() {
+	c.breaker.mu.Lock()
+	wait := time.Until(c.breaker.openUntil)
+	c.breaker.mu.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// recordThrottle slows the global rate down and opens the circuit breaker
+// once too many requests in a row have been throttled
+func (c *Client) recordThrottle() {
+	b := &c.breaker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.consecutive++
+	b.successes = 0
+
+	// Halve the rate, at most once a second so a burst of throttled
+	// responses from in-flight requests counts as one signal
+	if time.Since(b.lastNotice) > time.Second {
+		b.lastNotice = time.Now()
+		global, _ := c.globalLimiter()
+		if rate, changed := global.slowDown(); changed {
+			c.notify("Throttling detected, reducing rate to %.2f requests/second", rate)
+		}
+	}
+
+	if c.retry.BreakerThreshold > 0 && b.consecutive >= c.retry.BreakerThreshold && time.Now().After(b.openUntil) {
+		cooldown := doubled(c.retry.BreakerCooldown, b.trips, 5*time.Minute)
+		if cooldown <= 0 {
+			cooldown = 5 * time.Minute
+		}
+
+		b.trips++
+		b.consecutive = 0
+		b.openUntil = time.Now().Add(cooldown)
+		c.notify("Circuit breaker open after %d consecutive throttled requests, pausing scan for %s",
+			c.retry.BreakerThreshold, cooldown)
+	}
+}
+
+// recordSuccess closes the circuit breaker and gradually restores the rate
+func (c *Client) recordSuccess() {
+	b := &c.breaker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.trips > 0 {
+		c.notify("Circuit breaker closed, target responding again")
+	}
+	b.consecutive = 0
+	b.trips = 0
+
+	b.successes++
+	if b.successes >= 20 {
+		b.successes = 0
+		global, rate := c.globalLimiter()
+		global.speedUp(rate)
+	}
+}
+
+// isConnectionReset reports whether an error means the target reset the
+// connection. Refused connections and connections closed without an answer
+// are not throttling: a dead address or a server rejecting a malformed
+// payload answers that way every time.
+func isConnectionReset(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET)
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && time.Until(date) > 0 {
+		return time.Until(date)
+	}
+	return 0
+}
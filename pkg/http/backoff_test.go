@@ -0,0 +1,139 @@
+package http
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name       string
+		base       time.Duration
+		max        time.Duration
+		attempt    int
+		retryAfter time.Duration
+		min        time.Duration
+		upTo       time.Duration
+	}{
+		{"first retry", time.Second, time.Minute, 0, 0, time.Second, 1250 * time.Millisecond},
+		{"doubled", time.Second, time.Minute, 3, 0, 8 * time.Second, 10 * time.Second},
+		{"capped", time.Second, time.Minute, 10, 0, time.Minute, time.Minute},
+		{"many retries do not overflow", time.Second, time.Minute, 100, 0, time.Minute, time.Minute},
+		{"many retries without a maximum", time.Second, 0, 100, 0, maxBackoff, maxBackoff * 5 / 4},
+		{"Retry-After is honoured", time.Second, time.Minute, 0, 30 * time.Second, 30 * time.Second, 30 * time.Second},
+		{"Retry-After is capped", time.Second, time.Minute, 0, time.Hour, time.Minute, time.Minute},
+		{"no base delay", 0, time.Minute, 5, 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		c := NewClient(5, "")
+		c.SetRetryPolicy(RetryPolicy{BaseDelay: tt.base, MaxDelay: tt.max})
+		if got := c.backoff(tt.attempt, tt.retryAfter); got < tt.min || got > tt.upTo {
+			t.Errorf("%s: backoff(%d, %s) = %s, want between %s and %s", tt.name, tt.attempt, tt.retryAfter, got, tt.min, tt.upTo)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	future := time.Now().Add(90 * time.Second).UTC()
+	past := time.Now().Add(-time.Hour).UTC()
+
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{"0", 0},
+		{"-5", 0},
+		{"soon", 0},
+		{future.Format(http.TimeFormat), 90 * time.Second},
+		{future.Format(time.RFC850), 90 * time.Second},
+		{future.Format(time.ANSIC), 90 * time.Second},
+		{past.Format(http.TimeFormat), 0},
+	}
+
+	for _, tt := range tests {
+		// Dates only carry whole seconds
+		if got := parseRetryAfter(tt.value); got < tt.want-2*time.Second || got > tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestThrottledBy(t *testing.T) {
+	response := func(status int, retryAfter string) *Response {
+		resp := &Response{StatusCode: status, Header: make(http.Header)}
+		if retryAfter != "" {
+			resp.Header.Set("Retry-After", retryAfter)
+		}
+		return resp
+	}
+
+	// b.example blocks with 503, so there it is the block, not throttling
+	const a, b = "https://a.example", "https://b.example"
+
+	tests := []struct {
+		name       string
+		origin     string
+		resp       *Response
+		err        error
+		throttled  bool
+		retryAfter time.Duration
+	}{
+		{"429", a, response(429, "7"), nil, true, 7 * time.Second},
+		{"503", a, response(503, ""), nil, true, 0},
+		{"403", a, response(403, "7"), nil, false, 0},
+		{"503 block", b, response(503, ""), nil, false, 0},
+		{"429 where 503 is the block", b, response(429, ""), nil, true, 0},
+		{"connection reset", a, nil, fmt.Errorf("error reading response: %w", syscall.ECONNRESET), true, 0},
+		{"connection refused", a, nil, fmt.Errorf("dial: %w", syscall.ECONNREFUSED), false, 0},
+		{"closed without an answer", a, nil, fmt.Errorf("error reading response: %w", io.EOF), false, 0},
+	}
+
+	for _, tt := range tests {
+		c := NewClient(5, "")
+		c.SetBlockStatus(b, 503)
+		throttled, retryAfter := c.throttledBy(tt.origin, tt.resp, tt.err)
+		if throttled != tt.throttled || retryAfter != tt.retryAfter {
+			t.Errorf("%s: throttledBy = %v, %s, want %v, %s", tt.name, throttled, retryAfter, tt.throttled, tt.retryAfter)
+		}
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	var events []string
+	c := NewClient(5, "")
+	c.OnEvent = func(message string) { events = append(events, message) }
+	c.SetRetryPolicy(RetryPolicy{BreakerThreshold: 3, BreakerCooldown: time.Hour})
+
+	for i := 0; i < 3; i++ {
+		c.recordThrottle()
+	}
+	if time.Until(c.breaker.openUntil) < 4*time.Minute {
+		t.Errorf("breaker open until %s, want a pause of 5 minutes", c.breaker.openUntil)
+	}
+	if global, _ := c.globalLimiter(); global.rate != 10 {
+		t.Errorf("global rate = %.2f after throttling, want 10", global.rate)
+	}
+
+	c.recordSuccess()
+	if c.breaker.trips != 0 || c.breaker.consecutive != 0 {
+		t.Errorf("breaker not reset by a success: %d trips, %d throttled", c.breaker.trips, c.breaker.consecutive)
+	}
+
+	want := []string{"reducing rate to 10.00", "Circuit breaker open after 3", "Circuit breaker closed"}
+	if len(events) != len(want) {
+		t.Fatalf("events = %q, want %d", events, len(want))
+	}
+	for i, event := range events {
+		if !strings.Contains(event, want[i]) {
+			t.Errorf("event %d = %q, want it to mention %q", i, event, want[i])
+		}
+	}
+}
@@ -2,60 +2,60 @@ package http
 
 import (
 	"crypto/tls"
-	"errors"
-	"fmt"
-	"net/http"
+	"sync"
 	"time"
 )
 
 // Client is a custom HTTP client for bypass403
 type Client struct {
-	*http.Client
 	UserAgent string
-}
+	Timeout   time.Duration
 
-// NewClient creates a new HTTP client with custom settings
-func NewClient(timeout int, userAgent string) *Client {
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
+	// OnEvent, if set, is called with throttling and circuit breaker notices
+	OnEvent func(message string)
 
-	client := &http.Client{
-		Transport: tr,
-		Timeout:   time.Duration(timeout) * time.Second,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			// Allow up to 10 redirects
-			if len(via) >= 10 {
-				return http.ErrUseLastResponse
-			}
-			return nil
-		},
-	}
+	// Rate limiting state, see SetRateLimit, and the block status of each
+	// origin, see SetBlockStatus
+	limitMu     sync.Mutex
+	rateLimit   RateLimit
+	global      *limiter
+	hostLimits  map[string]*limiter
+	blockStatus map[string]int
 
-	return &Client{
-		Client:    client,
-		UserAgent: userAgent,
-	}
-}
+	// Throttling state, see SetRetryPolicy
+	retry   RetryPolicy
+	breaker breaker
 
-// VerifyURL checks if the URL returns a 403 Forbidden response
-func VerifyURL(urlStr string, client *Client) error {
-	req, err := http.NewRequest("GET", urlStr, nil)
-	if err != nil {
-		return err
-	}
+	// Upstream proxies, see SetProxy
+	proxy ProxyConfig
 
-	req.Header.Set("User-Agent", client.UserAgent)
+	// TLS settings shared by every connection, see SetTLS
+	tlsConfig *tls.Config
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
+	// Connect address overrides and TLS server name, see SetResolve and SetServerName
+	resolveMap map[string]string
+	serverName string
 
-	if resp.StatusCode != 403 {
-		return errors.New(fmt.Sprintf("The provided URL returns %d, not 403 Forbidden", resp.StatusCode))
+	// HTTP/2 mode, see SetHTTP2
+	http2Mode string
+
+	// Digest and NTLM credentials and the last Digest challenge of each
+	// origin, see SetAuth
+	auth   Auth
+	authMu sync.Mutex
+	digest map[string]*digestChallenge
+}
+
+// NewClient creates a new HTTP client with custom settings
+func NewClient(timeout int, userAgent string) *Client {
+	c := &Client{
+		UserAgent:   userAgent,
+		Timeout:     time.Duration(timeout) * time.Second,
+		tlsConfig:   &tls.Config{InsecureSkipVerify: true},
+		blockStatus: make(map[string]int),
 	}
+	c.SetRateLimit(RateLimit{})
+	c.SetRetryPolicy(DefaultRetryPolicy())
 
-	return nil
+	return c
 }
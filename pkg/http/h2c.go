@@ -0,0 +1,74 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// UpgradeError is returned for a request sent through an h2c upgrade that the
+// server did not switch protocols for: the proxy in front didn't forward the
+// upgrade, or the backend doesn't speak h2c. Response is the answer to the
+// upgrade request.
+type UpgradeError struct {
+	Response *Response
+}
+
+func (e *UpgradeError) Error() string {
+	return fmt.Sprintf("h2c upgrade not forwarded or refused, the server answered %s", e.Response.Status)
+}
+
+// H2CSettings returns the HTTP2-Settings header value for h2c upgrades: the
+// base64url encoded payload of the SETTINGS frame the connection starts with
+func H2CSettings() string {
+	var payload []byte
+	for _, s := range h2Settings {
+		payload = binary.BigEndian.AppendUint16(payload, uint16(s.ID))
+		payload = binary.BigEndian.AppendUint32(payload, s.Val)
+	}
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// roundTripH2C sends the upgrade request and, once the server has switched
+// protocols, the request itself as HTTP/2 on stream 3 of the upgraded
+// connection. Stream 1 carries the response to the upgrade request, which is
+// read and dropped. A proxy that forwards the upgrade hands the connection to
+// the backend, so the second request never passes the proxy's rules.
+func (c *Client) roundTripH2C(req *Request, conn net.Conn, timing *Timing) (*Response, error) {
+	mark := time.Now()
+	if _, err := conn.Write(req.Upgrade.Bytes()); err != nil {
+		return nil, fmt.Errorf("error writing upgrade request: %w", err)
+	}
+	timing.Send = time.Since(mark)
+
+	var raw bytes.Buffer
+	reader := bufio.NewReader(io.TeeReader(conn, &raw))
+	upgrade, err := readHTTP1(reader, &raw, req.Upgrade.Method, timing)
+	if err != nil {
+		return nil, err
+	}
+	if upgrade.StatusCode != 101 {
+		return nil, &UpgradeError{Response: upgrade}
+	}
+
+	// The HTTP/2 frames that follow may already be buffered in reader
+	mark = time.Now()
+	h, err := newH2Conn(conn, reader)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := h.readResponse(1, nil); err != nil {
+		return nil, fmt.Errorf("upgraded, but the upgrade request failed: %w", err)
+	}
+	if err := h.writeRequest(3, req); err != nil {
+		return nil, err
+	}
+	timing.Send += time.Since(mark)
+
+	return h.readTimed(3, timing)
+}
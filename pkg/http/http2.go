@@ -0,0 +1,373 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// ProtoHTTP2 is the Proto of requests that are always sent as HTTP/2 frames,
+// whatever HTTP/2 mode the client is in
+const ProtoHTTP2 = "HTTP/2"
+
+// HTTP/2 modes, see SetHTTP2
+const (
+	// HTTP2Off sends every request as HTTP/1.x, except ProtoHTTP2 requests
+	HTTP2Off = ""
+	// HTTP2ALPN offers h2 in the TLS handshake and speaks it if the server
+	// picks it. Plain http:// targets stay on HTTP/1.1.
+	HTTP2ALPN = "alpn"
+	// HTTP2PriorKnowledge speaks HTTP/2 straight away, as cleartext h2c on
+	// http:// targets
+	HTTP2PriorKnowledge = "prior-knowledge"
+)
+
+// h2FrameSize is the largest frame we send, the minimum every peer accepts
+const h2FrameSize = 16384
+
+// h2Settings are sent when a connection starts: no server push, and a stream
+// window that fits a whole response body
+var h2Settings = []http2.Setting{
+	{ID: http2.SettingEnablePush, Val: 0},
+	{ID: http2.SettingInitialWindowSize, Val: MaxBodySize},
+}
+
+// ParseHTTP2Mode checks an HTTP/2 mode given on the command line
+func ParseHTTP2Mode(mode string) (string, error) {
+	switch strings.ToLower(mode) {
+	case "", "off", "false":
+		return HTTP2Off, nil
+	case "alpn", "true":
+		return HTTP2ALPN, nil
+	case "prior-knowledge", "prior":
+		return HTTP2PriorKnowledge, nil
+	}
+	return "", fmt.Errorf("unknown HTTP/2 mode %q (use alpn or prior-knowledge)", mode)
+}
+
+// SetHTTP2 makes the client send HTTP/1.1 requests over HTTP/2, negotiated
+// with ALPN or with prior knowledge. Requests using another HTTP/1.x version
+// are sent as they are.
+func (c *Client) SetHTTP2(mode string) {
+	c.http2Mode = mode
+}
+
+// http2Protocols returns the ALPN protocols to offer for a request, and
+// whether it is sent as HTTP/2 even if the server doesn't pick h2
+func (c *Client) http2Protocols(req *Request) (protos []string, prior bool) {
+	switch {
+	case req.Upgrade != nil:
+		// h2c upgrades start out as HTTP/1.1, over TLS too
+		return []string{"http/1.1"}, false
+	case req.Proto == ProtoHTTP2:
+		return []string{"h2"}, true
+	case req.Proto != "HTTP/1.1":
+		return []string{"http/1.1"}, false
+	case c.http2Mode == HTTP2PriorKnowledge:
+		return []string{"h2"}, true
+	case c.http2Mode == HTTP2ALPN:
+		return []string{"h2", "http/1.1"}, false
+	}
+	return []string{"http/1.1"}, false
+}
+
+// sendsHTTP2 reports whether the request is sent as HTTP/2 on conn
+func (c *Client) sendsHTTP2(req *Request, conn net.Conn) bool {
+	_, prior := c.http2Protocols(req)
+	if prior {
+		return true
+	}
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		return tlsConn.ConnectionState().NegotiatedProtocol == "h2"
+	}
+	return false
+}
+
+// HeaderFields returns the HTTP/2 header fields of the request. If its headers
+// already carry pseudo-headers they are sent exactly as listed, so techniques
+// can send duplicated, conflicting, misplaced or uppercase fields. Otherwise
+// the fields are derived the way a browser would: pseudo-headers from the
+// method, origin, target and Host header, lowercase names, and none of the
+// connection-specific headers HTTP/2 forbids.
+func (r *Request) HeaderFields() []Header {
+	for _, h := range r.Headers {
+		if strings.HasPrefix(h.Name, ":") {
+			return r.Headers
+		}
+	}
+
+	scheme, host, _, err := splitOrigin(r.Origin)
+	if err != nil {
+		scheme = "https"
+	}
+	if value := r.Header("Host"); value != "" {
+		host = value
+	}
+
+	fields := []Header{
+		{":method", r.Method},
+		{":scheme", scheme},
+		{":authority", host},
+		{":path", r.Target},
+	}
+	for _, h := range r.Headers {
+		switch strings.ToLower(h.Name) {
+		case "host", "connection", "keep-alive", "proxy-connection", "transfer-encoding", "upgrade":
+			continue
+		}
+		fields = append(fields, Header{strings.ToLower(h.Name), h.Value})
+	}
+	return fields
+}
+
+// h2Conn is a client HTTP/2 connection that writes header fields exactly as
+// given, however malformed, and reads responses frame by frame
+type h2Conn struct {
+	conn    net.Conn
+	framer  *http2.Framer
+	encoder *hpack.Encoder
+	decoder *hpack.Decoder
+	block   bytes.Buffer
+}
+
+// newH2Conn starts an HTTP/2 connection, reading from r, which may hold data
+// already buffered from conn. The connection and stream windows are opened
+// wide enough that a whole response body fits without window updates.
+func newH2Conn(conn net.Conn, r io.Reader) (*h2Conn, error) {
+	h := &h2Conn{conn: conn, framer: http2.NewFramer(conn, r)}
+	h.encoder = hpack.NewEncoder(&h.block)
+	h.decoder = hpack.NewDecoder(4096, nil)
+
+	if _, err := io.WriteString(conn, http2.ClientPreface); err != nil {
+		return nil, fmt.Errorf("error writing HTTP/2 preface: %w", err)
+	}
+	err := h.framer.WriteSettings(h2Settings...)
+	if err == nil {
+		err = h.framer.WriteWindowUpdate(0, MaxBodySize)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error writing HTTP/2 settings: %w", err)
+	}
+	return h, nil
+}
+
+// writeRequest sends the request on a stream as a HEADERS block, split into
+// CONTINUATION frames if needed, followed by the body in DATA frames. Bodies
+// are expected to fit the peer's initial 64 KiB window.
+func (h *h2Conn) writeRequest(streamID uint32, req *Request) error {
+	h.block.Reset()
+	for _, f := range req.HeaderFields() {
+		h.encoder.WriteField(hpack.HeaderField{Name: f.Name, Value: f.Value})
+	}
+
+	block := h.block.Bytes()
+	first := block
+	if len(first) > h2FrameSize {
+		first = block[:h2FrameSize]
+	}
+	block = block[len(first):]
+
+	err := h.framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      streamID,
+		BlockFragment: first,
+		EndStream:     len(req.Body) == 0,
+		EndHeaders:    len(block) == 0,
+	})
+	for err == nil && len(block) > 0 {
+		fragment := block
+		if len(fragment) > h2FrameSize {
+			fragment = block[:h2FrameSize]
+		}
+		block = block[len(fragment):]
+		err = h.framer.WriteContinuation(streamID, len(block) == 0, fragment)
+	}
+
+	body := req.Body
+	for err == nil && len(body) > 0 {
+		chunk := body
+		if len(chunk) > h2FrameSize {
+			chunk = body[:h2FrameSize]
+		}
+		body = body[len(chunk):]
+		err = h.framer.WriteData(streamID, len(body) == 0, chunk)
+	}
+
+	if err != nil {
+		return fmt.Errorf("error writing request: %w", err)
+	}
+	return nil
+}
+
+// readResponse reads frames until the response on the stream is complete.
+// Header blocks of other streams are decoded too, to keep the HPACK state in
+// step, but otherwise ignored. onHeaders, if set, is called once the final
+// response headers have arrived.
+func (h *h2Conn) readResponse(streamID uint32, onHeaders func()) (*Response, error) {
+	resp := &Response{Proto: "HTTP/2.0", Header: make(http.Header)}
+	var raw bytes.Buffer
+	// The header block being read, the stream it belongs to and whether its
+	// HEADERS frame ended the stream
+	var fragments []byte
+	var fragmentsStream uint32
+	var fragmentsEnd bool
+	headersDone := false
+
+	for {
+		frame, err := h.framer.ReadFrame()
+		if err != nil {
+			return nil, fmt.Errorf("error reading response: %w", err)
+		}
+
+		switch f := frame.(type) {
+		case *http2.SettingsFrame:
+			if !f.IsAck() {
+				h.framer.WriteSettingsAck()
+			}
+			continue
+		case *http2.PingFrame:
+			if !f.IsAck() {
+				h.framer.WritePing(true, f.Data)
+			}
+			continue
+		case *http2.GoAwayFrame:
+			if f.LastStreamID < streamID {
+				return nil, fmt.Errorf("connection closed by server: GOAWAY %s %s", f.ErrCode, f.DebugData())
+			}
+			continue
+		case *http2.RSTStreamFrame:
+			if f.StreamID == streamID {
+				return nil, fmt.Errorf("stream reset by server: %s", f.ErrCode)
+			}
+			continue
+		case *http2.HeadersFrame:
+			fragments = append(fragments[:0], f.HeaderBlockFragment()...)
+			fragmentsStream, fragmentsEnd = f.StreamID, f.StreamEnded()
+			if !f.HeadersEnded() {
+				continue
+			}
+		case *http2.ContinuationFrame:
+			fragments = append(fragments, f.HeaderBlockFragment()...)
+			if !f.HeadersEnded() {
+				continue
+			}
+		case *http2.DataFrame:
+			if f.StreamID != streamID {
+				continue
+			}
+			if room := MaxBodySize - len(resp.Body); room > 0 {
+				data := f.Data()
+				if len(data) > room {
+					data = data[:room]
+				}
+				resp.Body = append(resp.Body, data...)
+			}
+			if f.StreamEnded() || len(resp.Body) >= MaxBodySize {
+				return finishH2Response(resp, &raw), nil
+			}
+			continue
+		default:
+			continue
+		}
+
+		// A complete header block
+		fields, err := h.decoder.DecodeFull(fragments)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding response headers: %w", err)
+		}
+		if fragmentsStream != streamID {
+			continue
+		}
+
+		if !headersDone {
+			status := ""
+			for _, field := range fields {
+				if field.Name == ":status" {
+					status = field.Value
+				}
+			}
+			code, err := strconv.Atoi(status)
+			if err != nil {
+				return nil, fmt.Errorf("invalid HTTP/2 :status %q", status)
+			}
+			if code >= 100 && code < 200 {
+				// Informational responses come before the real one
+				continue
+			}
+
+			resp.StatusCode = code
+			resp.Status = strconv.Itoa(code) + " " + http.StatusText(code)
+			raw.WriteString("HTTP/2 " + status + "\r\n")
+			for _, field := range fields {
+				if field.Name == ":status" {
+					continue
+				}
+				resp.Header.Add(field.Name, field.Value)
+				raw.WriteString(field.Name + ": " + field.Value + "\r\n")
+			}
+			raw.WriteString("\r\n")
+			headersDone = true
+			if onHeaders != nil {
+				onHeaders()
+			}
+		}
+		// Anything after the response headers is trailers, which end the stream
+		if headersDone && fragmentsEnd {
+			return finishH2Response(resp, &raw), nil
+		}
+	}
+}
+
+// finishH2Response appends the body to the reconstructed raw response
+func finishH2Response(resp *Response, raw *bytes.Buffer) *Response {
+	raw.Write(resp.Body)
+	resp.Raw = raw.Bytes()
+	return resp
+}
+
+// roundTripHTTP2 sends the request as HTTP/2 on stream 1 of a fresh
+// connection. Raw holds the response as text, status line first, since
+// HTTP/2 frames have no wire form worth exporting.
+func (c *Client) roundTripHTTP2(req *Request, conn net.Conn, timing *Timing) (*Response, error) {
+	mark := time.Now()
+	reader := bufio.NewReader(conn)
+	h, err := newH2Conn(conn, reader)
+	if err == nil {
+		err = h.writeRequest(1, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	timing.Send = time.Since(mark)
+
+	// A server that doesn't speak HTTP/2 answers the preface with an error page
+	if peek, err := reader.Peek(5); err == nil && string(peek) == "HTTP/" {
+		line, _ := reader.ReadString('\n')
+		return nil, fmt.Errorf("server does not speak HTTP/2, it answered %q", strings.TrimSpace(line))
+	}
+
+	return h.readTimed(1, timing)
+}
+
+// readTimed reads the response on a stream, recording the wait and receive times
+func (h *h2Conn) readTimed(streamID uint32, timing *Timing) (*Response, error) {
+	mark := time.Now()
+	resp, err := h.readResponse(streamID, func() {
+		timing.Wait = time.Since(mark)
+		mark = time.Now()
+	})
+	if err != nil {
+		return nil, err
+	}
+	timing.Receive = time.Since(mark)
+	return resp, nil
+}
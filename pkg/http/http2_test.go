@@ -0,0 +1,257 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+func TestParseHTTP2Mode(t *testing.T) {
+	tests := []struct {
+		mode    string
+		want    string
+		wantErr bool
+	}{
+		{"", HTTP2Off, false},
+		{"off", HTTP2Off, false},
+		{"true", HTTP2ALPN, false},
+		{"ALPN", HTTP2ALPN, false},
+		{"prior", HTTP2PriorKnowledge, false},
+		{"prior-knowledge", HTTP2PriorKnowledge, false},
+		{"h3", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseHTTP2Mode(tt.mode)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseHTTP2Mode(%q) = %q, %v, want %q, wantErr %v", tt.mode, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestHeaderFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		origin  string
+		target  string
+		headers []Header
+		want    []Header
+	}{
+		{
+			name:    "derived from the request",
+			origin:  "https://example.com",
+			target:  "/admin?x=1",
+			headers: []Header{{"Host", "example.com"}, {"User-Agent", "test"}, {"Connection", "close"}, {"X-Original-URL", "/admin"}},
+			want: []Header{
+				{":method", "GET"}, {":scheme", "https"}, {":authority", "example.com"}, {":path", "/admin?x=1"},
+				{"user-agent", "test"}, {"x-original-url", "/admin"},
+			},
+		},
+		{
+			name:    "Host header becomes the authority",
+			origin:  "http://10.0.0.5:8080",
+			target:  "/",
+			headers: []Header{{"Host", "internal.example"}, {"Transfer-Encoding", "chunked"}, {"Upgrade", "h2c"}},
+			want:    []Header{{":method", "GET"}, {":scheme", "http"}, {":authority", "internal.example"}, {":path", "/"}},
+		},
+		{
+			name:    "explicit pseudo-headers are sent as listed",
+			origin:  "https://example.com",
+			target:  "/",
+			headers: []Header{{":method", "GET"}, {":path", "/admin"}, {":path", "/"}, {"Host", "example.com"}, {":authority", "other"}},
+			want:    []Header{{":method", "GET"}, {":path", "/admin"}, {":path", "/"}, {"Host", "example.com"}, {":authority", "other"}},
+		},
+	}
+
+	for _, tt := range tests {
+		req := &Request{Method: "GET", Origin: tt.origin, Target: tt.target, Headers: tt.headers}
+		if got := req.HeaderFields(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: HeaderFields = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// h2Server accepts one HTTP/2 connection, reads the request on stream 1 and
+// answers it with respond. The request's header fields and body are sent on
+// the returned channel.
+func h2Server(t *testing.T, respond func(framer *http2.Framer, encode func(fields ...string) []byte)) (string, <-chan *Request) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := make(chan *Request, 1)
+
+	go func() {
+		defer ln.Close()
+		defer close(requests)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		preface := make([]byte, len(http2.ClientPreface))
+		if _, err := io.ReadFull(conn, preface); err != nil || string(preface) != http2.ClientPreface {
+			return
+		}
+		framer := http2.NewFramer(conn, conn)
+		framer.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+		framer.MaxHeaderListSize = 1 << 20
+
+		req := &Request{}
+		for done := false; !done; {
+			frame, err := framer.ReadFrame()
+			if err != nil {
+				return
+			}
+			switch f := frame.(type) {
+			case *http2.MetaHeadersFrame:
+				for _, field := range f.Fields {
+					req.Headers = append(req.Headers, Header{field.Name, field.Value})
+				}
+				done = f.StreamEnded()
+			case *http2.DataFrame:
+				req.Body = append(req.Body, f.Data()...)
+				done = f.StreamEnded()
+			}
+		}
+		requests <- req
+
+		var block bytes.Buffer
+		encoder := hpack.NewEncoder(&block)
+		respond(framer, func(fields ...string) []byte {
+			block.Reset()
+			for i := 0; i+1 < len(fields); i += 2 {
+				encoder.WriteField(hpack.HeaderField{Name: fields[i], Value: fields[i+1]})
+			}
+			return append([]byte(nil), block.Bytes()...)
+		})
+	}()
+
+	return ln.Addr().String(), requests
+}
+
+func TestH2Conn(t *testing.T) {
+	long := strings.Repeat("0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ", 600)
+
+	tests := []struct {
+		name    string
+		headers []Header
+		body    string
+		respond func(framer *http2.Framer, encode func(fields ...string) []byte)
+		status  int
+		header  string
+		resBody string
+		wantErr bool
+	}{
+		{
+			name:    "headers and data",
+			headers: []Header{{"Host", "example.com"}, {"X-Test", "1"}},
+			respond: func(framer *http2.Framer, encode func(fields ...string) []byte) {
+				framer.WriteSettings()
+				framer.WritePing(false, [8]byte{1})
+				framer.WriteHeaders(http2.HeadersFrameParam{StreamID: 1, EndHeaders: true,
+					BlockFragment: encode(":status", "200", "server", "test")})
+				framer.WriteData(1, false, []byte("hello "))
+				framer.WriteData(1, true, []byte("world"))
+			},
+			status:  200,
+			header:  "test",
+			resBody: "hello world",
+		},
+		{
+			name:    "request body, informational response and another stream",
+			headers: []Header{{":method", "POST"}, {":path", "/upload"}, {":authority", "example.com"}, {":scheme", "https"}},
+			body:    "data",
+			respond: func(framer *http2.Framer, encode func(fields ...string) []byte) {
+				framer.WriteHeaders(http2.HeadersFrameParam{StreamID: 1, EndHeaders: true,
+					BlockFragment: encode(":status", "103", "link", "</style.css>")})
+				framer.WriteHeaders(http2.HeadersFrameParam{StreamID: 3, EndHeaders: true, EndStream: true,
+					BlockFragment: encode(":status", "500", "server", "other")})
+				framer.WriteData(3, true, []byte("other stream"))
+				framer.WriteHeaders(http2.HeadersFrameParam{StreamID: 1, EndHeaders: true, EndStream: true,
+					BlockFragment: encode(":status", "403", "server", "test")})
+			},
+			status: 403,
+			header: "test",
+		},
+		{
+			name:    "header blocks split into continuations",
+			headers: []Header{{"Host", "example.com"}, {"X-Long", long}},
+			respond: func(framer *http2.Framer, encode func(fields ...string) []byte) {
+				block := encode(":status", "200", "server", "test", "x-long", long)
+				framer.WriteHeaders(http2.HeadersFrameParam{StreamID: 1, BlockFragment: block[:10]})
+				framer.WriteContinuation(1, false, block[10:20])
+				framer.WriteContinuation(1, true, block[20:])
+				framer.WriteData(1, true, nil)
+			},
+			status: 200,
+			header: "test",
+		},
+		{
+			name:    "stream reset",
+			headers: []Header{{"Host", "example.com"}},
+			respond: func(framer *http2.Framer, encode func(fields ...string) []byte) {
+				framer.WriteRSTStream(1, http2.ErrCodeRefusedStream)
+			},
+			wantErr: true,
+		},
+		{
+			name:    "invalid status",
+			headers: []Header{{"Host", "example.com"}},
+			respond: func(framer *http2.Framer, encode func(fields ...string) []byte) {
+				framer.WriteHeaders(http2.HeadersFrameParam{StreamID: 1, EndHeaders: true, EndStream: true,
+					BlockFragment: encode(":status", "abc")})
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		addr, requests := h2Server(t, tt.respond)
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := &Request{Method: "GET", Origin: "https://example.com", Target: "/", Headers: tt.headers, Body: []byte(tt.body)}
+		h, err := newH2Conn(conn, conn)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if err := h.writeRequest(1, req); err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		resp, err := h.readResponse(1, nil)
+		conn.Close()
+
+		sent := <-requests
+		if sent == nil {
+			t.Fatalf("%s: the server read no request", tt.name)
+		}
+		if !reflect.DeepEqual(sent.Headers, req.HeaderFields()) || string(sent.Body) != tt.body {
+			t.Errorf("%s: server read %v %q, want %v %q", tt.name, sent.Headers, sent.Body, req.HeaderFields(), tt.body)
+		}
+
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if resp.StatusCode != tt.status || resp.Header.Get("Server") != tt.header || string(resp.Body) != tt.resBody {
+			t.Errorf("%s: response %d, server %q, body %q, want %d, %q, %q", tt.name,
+				resp.StatusCode, resp.Header.Get("Server"), resp.Body, tt.status, tt.header, tt.resBody)
+		}
+		if !bytes.HasPrefix(resp.Raw, []byte("HTTP/2 ")) || !bytes.HasSuffix(resp.Raw, resp.Body) {
+			t.Errorf("%s: raw = %q", tt.name, resp.Raw)
+		}
+	}
+}
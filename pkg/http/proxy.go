@@ -0,0 +1,402 @@
+package http
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProxyConfig selects the upstream proxy for each request. HTTP, HTTPS and
+// SOCKS5 proxies are supported; socks5h leaves name resolution to the proxy.
+type ProxyConfig struct {
+	// HTTP and HTTPS are the proxies for http:// and https:// targets, nil for a direct connection
+	HTTP  *url.URL
+	HTTPS *url.URL
+	// NoProxy lists the hosts, domains and networks connected to directly, in NO_PROXY syntax
+	NoProxy []string
+}
+
+// ParseProxy parses a proxy URL. A URL without a scheme is taken as an HTTP
+// proxy, so "127.0.0.1:8080" works for Burp.
+func ParseProxy(raw string) (*url.URL, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %s", err)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q (use http, https, socks5 or socks5h)", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid proxy URL, missing host: %s", raw)
+	}
+
+	return u, nil
+}
+
+// NewProxyConfig sends every request through proxyURL, except to the hosts in
+// NO_PROXY. An empty proxyURL falls back to the environment.
+func NewProxyConfig(proxyURL string) (ProxyConfig, error) {
+	if proxyURL == "" {
+		return ProxyFromEnvironment()
+	}
+
+	u, err := ParseProxy(proxyURL)
+	if err != nil {
+		return ProxyConfig{}, err
+	}
+	return ProxyConfig{HTTP: u, HTTPS: u, NoProxy: splitNoProxy(getEnv("NO_PROXY"))}, nil
+}
+
+// ProxyFromEnvironment reads HTTP_PROXY, HTTPS_PROXY, ALL_PROXY and NO_PROXY,
+// or their lowercase forms
+func ProxyFromEnvironment() (ProxyConfig, error) {
+	var cfg ProxyConfig
+	all := getEnv("ALL_PROXY")
+
+	for _, p := range []struct {
+		name string
+		dst  **url.URL
+	}{{"HTTP_PROXY", &cfg.HTTP}, {"HTTPS_PROXY", &cfg.HTTPS}} {
+		raw := getEnv(p.name)
+		if raw == "" {
+			raw = all
+		}
+		if raw == "" {
+			continue
+		}
+		u, err := ParseProxy(raw)
+		if err != nil {
+			return ProxyConfig{}, fmt.Errorf("%s: %s", p.name, err)
+		}
+		*p.dst = u
+	}
+
+	cfg.NoProxy = splitNoProxy(getEnv("NO_PROXY"))
+	return cfg, nil
+}
+
+// getEnv returns an environment variable, trying the lowercase name as well
+func getEnv(name string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return os.Getenv(strings.ToLower(name))
+}
+
+// splitNoProxy splits a NO_PROXY list into its entries
+func splitNoProxy(list string) []string {
+	var entries []string
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.ToLower(strings.TrimSpace(entry)); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// SetProxy routes the client's requests according to cfg
+func (c *Client) SetProxy(cfg ProxyConfig) {
+	c.proxy = cfg
+}
+
+// proxyFor returns the proxy for a target, or nil to connect directly
+func (cfg ProxyConfig) proxyFor(scheme, host string) *url.URL {
+	proxy := cfg.HTTP
+	if scheme == "https" {
+		proxy = cfg.HTTPS
+	}
+	if proxy == nil || cfg.bypass(host) {
+		return nil
+	}
+	return proxy
+}
+
+// bypass reports whether NO_PROXY exempts host[:port]. Entries match a host
+// name and its subdomains, an IP address or a CIDR network, optionally with a
+// port, and "*" matches everything.
+func (cfg ProxyConfig) bypass(host string) bool {
+	name, port := strings.ToLower(hostname(host)), ""
+	if _, p, err := net.SplitHostPort(host); err == nil {
+		port = p
+	}
+	ip := net.ParseIP(name)
+
+	for _, entry := range cfg.NoProxy {
+		if entry == "*" {
+			return true
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		entryHost, entryPort := entry, ""
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			entryHost, entryPort = h, p
+		}
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+		entryHost = strings.TrimPrefix(strings.Trim(entryHost, "[]"), "*")
+
+		if entryIP := net.ParseIP(entryHost); entryIP != nil {
+			if ip != nil && ip.Equal(entryIP) {
+				return true
+			}
+			continue
+		}
+		if name == strings.TrimPrefix(entryHost, ".") || strings.HasSuffix(name, "."+strings.TrimPrefix(entryHost, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// dialProxy connects to the target address host:port through an upstream proxy
+func (c *Client) dialProxy(proxy *url.URL, host, port string) (net.Conn, error) {
+	proxyPort := proxy.Port()
+	if proxyPort == "" {
+		switch proxy.Scheme {
+		case "http":
+			proxyPort = "80"
+		case "https":
+			proxyPort = "443"
+		default:
+			proxyPort = "1080"
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: c.Timeout}
+	conn, err := dialer.Dial("tcp", net.JoinHostPort(proxy.Hostname(), proxyPort))
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to proxy %s: %s", proxy.Host, err)
+	}
+	if c.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(c.Timeout))
+	}
+
+	switch proxy.Scheme {
+	case "https":
+		// The proxy's certificate is verified like the target's, but the
+		// client certificate is meant for the target alone
+		tlsConfig := c.tlsConfig.Clone()
+		tlsConfig.ServerName = proxy.Hostname()
+		tlsConfig.Certificates = nil
+		tlsConfig.NextProtos = nil
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("TLS handshake with proxy %s failed: %s", proxy.Host, err)
+		}
+		conn = tlsConn
+		fallthrough
+	case "http":
+		err = httpConnect(conn, proxy, net.JoinHostPort(host, port))
+	default:
+		err = socks5Connect(conn, proxy, host, port)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// proxyAuthorization returns the Proxy-Authorization value for the proxy's credentials
+func proxyAuthorization(proxy *url.URL) string {
+	if proxy.User == nil {
+		return ""
+	}
+	password, _ := proxy.User.Password()
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(proxy.User.Username()+":"+password))
+}
+
+// httpConnect opens a tunnel to address with CONNECT. Tunnelling plain HTTP as
+// well keeps the request-target exactly as the technique wrote it, which an
+// absolute-form request through the proxy would not.
+func httpConnect(conn net.Conn, proxy *url.URL, address string) error {
+	request := "CONNECT " + address + " HTTP/1.1\r\nHost: " + address + "\r\n"
+	if auth := proxyAuthorization(proxy); auth != "" {
+		request += "Proxy-Authorization: " + auth + "\r\n"
+	}
+	request += "\r\n"
+	if _, err := io.WriteString(conn, request); err != nil {
+		return fmt.Errorf("error writing CONNECT to proxy: %s", err)
+	}
+
+	// Read byte by byte up to the end of the headers, so nothing the target
+	// sends after them is consumed here
+	resp, err := http.ReadResponse(bufio.NewReaderSize(&byteReader{conn}, 1), &http.Request{Method: "CONNECT"})
+	if err != nil {
+		return fmt.Errorf("error reading CONNECT response from proxy: %s", err)
+	}
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("proxy refused CONNECT to %s: %s", address, resp.Status)
+	}
+	return nil
+}
+
+// byteReader reads one byte at a time
+type byteReader struct {
+	r io.Reader
+}
+
+func (b *byteReader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	return b.r.Read(p)
+}
+
+// socks5Connect asks a SOCKS5 proxy (RFC 1928) to connect to host:port, with
+// username/password authentication (RFC 1929) when the proxy URL has credentials.
+// For socks5 the host name is resolved locally, for socks5h by the proxy.
+func socks5Connect(conn net.Conn, proxy *url.URL, host, port string) error {
+	methods := []byte{0x00}
+	if proxy.User != nil {
+		methods = []byte{0x02, 0x00}
+	}
+	if _, err := conn.Write(append([]byte{0x05, byte(len(methods))}, methods...)); err != nil {
+		return fmt.Errorf("error writing SOCKS5 greeting: %s", err)
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("error reading SOCKS5 greeting: %s", err)
+	}
+	if reply[0] != 0x05 {
+		return errors.New("proxy is not a SOCKS5 server")
+	}
+
+	switch reply[1] {
+	case 0x00:
+	case 0x02:
+		username := proxy.User.Username()
+		password, _ := proxy.User.Password()
+		if len(username) > 255 || len(password) > 255 {
+			return errors.New("SOCKS5 username and password are limited to 255 bytes")
+		}
+		auth := []byte{0x01, byte(len(username))}
+		auth = append(auth, username...)
+		auth = append(auth, byte(len(password)))
+		auth = append(auth, password...)
+		if _, err := conn.Write(auth); err != nil {
+			return fmt.Errorf("error writing SOCKS5 authentication: %s", err)
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return fmt.Errorf("error reading SOCKS5 authentication: %s", err)
+		}
+		if reply[1] != 0x00 {
+			return errors.New("SOCKS5 authentication failed")
+		}
+	default:
+		return errors.New("SOCKS5 proxy accepts none of the offered authentication methods")
+	}
+
+	portNum, err := strconv.Atoi(port)
+	if err != nil {
+		return fmt.Errorf("invalid port %q", port)
+	}
+
+	request := []byte{0x05, 0x01, 0x00}
+	ip := net.ParseIP(host)
+	if ip == nil && proxy.Scheme == "socks5" {
+		addrs, err := net.LookupIP(host)
+		if err != nil || len(addrs) == 0 {
+			return fmt.Errorf("error resolving %s: %s", host, err)
+		}
+		ip = addrs[0]
+	}
+	switch {
+	case ip == nil:
+		if len(host) > 255 {
+			return fmt.Errorf("host name too long for SOCKS5: %s", host)
+		}
+		request = append(request, 0x03, byte(len(host)))
+		request = append(request, host...)
+	case ip.To4() != nil:
+		request = append(request, 0x01)
+		request = append(request, ip.To4()...)
+	default:
+		request = append(request, 0x04)
+		request = append(request, ip.To16()...)
+	}
+	request = binary.BigEndian.AppendUint16(request, uint16(portNum))
+
+	if _, err := conn.Write(request); err != nil {
+		return fmt.Errorf("error writing SOCKS5 connect: %s", err)
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return fmt.Errorf("error reading SOCKS5 connect reply: %s", err)
+	}
+	if header[1] != 0x00 {
+		return fmt.Errorf("SOCKS5 proxy could not connect to %s: %s", net.JoinHostPort(host, port), socks5Error(header[1]))
+	}
+
+	// Skip the bound address and port
+	var skip int
+	switch header[3] {
+	case 0x01:
+		skip = 4
+	case 0x04:
+		skip = 16
+	case 0x03:
+		size := make([]byte, 1)
+		if _, err := io.ReadFull(conn, size); err != nil {
+			return fmt.Errorf("error reading SOCKS5 connect reply: %s", err)
+		}
+		skip = int(size[0])
+	}
+	if _, err := io.ReadFull(conn, make([]byte, skip+2)); err != nil {
+		return fmt.Errorf("error reading SOCKS5 connect reply: %s", err)
+	}
+
+	return nil
+}
+
+// socks5Error describes a SOCKS5 reply code
+func socks5Error(code byte) string {
+	switch code {
+	case 0x01:
+		return "general failure"
+	case 0x02:
+		return "connection not allowed by ruleset"
+	case 0x03:
+		return "network unreachable"
+	case 0x04:
+		return "host unreachable"
+	case 0x05:
+		return "connection refused"
+	case 0x06:
+		return "TTL expired"
+	case 0x07:
+		return "command not supported"
+	case 0x08:
+		return "address type not supported"
+	}
+	return "error " + strconv.Itoa(int(code))
+}
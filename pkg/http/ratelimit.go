@@ -0,0 +1,180 @@
+package http
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit controls how fast the client sends requests. Zero values mean no limit.
+type RateLimit struct {
+	// RPS is the global number of requests per second across all hosts
+	RPS float64
+	// HostRPS is the number of requests per second to any single host
+	HostRPS float64
+	// Burst is the number of requests that may be sent back to back before the rate applies
+	Burst int
+	// Jitter is the maximum random delay added before each request
+	Jitter time.Duration
+}
+
+// Profiles are the named timing presets selectable with -profile
+var Profiles = map[string]RateLimit{
+	"stealth":    {RPS: 1, HostRPS: 1, Burst: 1, Jitter: 3 * time.Second},
+	"polite":     {RPS: 5, HostRPS: 2, Burst: 2, Jitter: 500 * time.Millisecond},
+	"normal":     {RPS: 50, HostRPS: 20, Burst: 10, Jitter: 50 * time.Millisecond},
+	"aggressive": {},
+}
+
+// ProfileNames returns the names of the timing profiles, sorted
+func ProfileNames() []string {
+	names := make([]string, 0, len(Profiles))
+	for name := range Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetProfile returns the timing profile with the given name
+func GetProfile(name string) (RateLimit, error) {
+	profile, ok := Profiles[strings.ToLower(name)]
+	if !ok {
+		return RateLimit{}, fmt.Errorf("unknown timing profile %q (available: %s)",
+			name, strings.Join(ProfileNames(), ", "))
+	}
+	return profile, nil
+}
+
+// SetRateLimit applies a rate limit to every request the client sends
+func (c *Client) SetRateLimit(rl RateLimit) {
+	c.limitMu.Lock()
+	defer c.limitMu.Unlock()
+
+	c.rateLimit = rl
+	c.global = newLimiter(rl.RPS, rl.Burst)
+	c.hostLimits = make(map[string]*limiter)
+}
+
+// globalLimiter returns the limiter shared by requests to every host, and
+// the rate it was configured with
+func (c *Client) globalLimiter() (*limiter, float64) {
+	c.limitMu.Lock()
+	defer c.limitMu.Unlock()
+	return c.global, c.rateLimit.RPS
+}
+
+// throttle blocks until a request to the origin is allowed by the global and
+// per-host limits, then waits a random jitter
+func (c *Client) throttle(origin string) {
+	c.limitMu.Lock()
+	rl := c.rateLimit
+	global := c.global
+	host := c.hostLimits[origin]
+	if host == nil && rl.HostRPS > 0 {
+		host = newLimiter(rl.HostRPS, rl.Burst)
+		c.hostLimits[origin] = host
+	}
+	c.limitMu.Unlock()
+
+	global.wait()
+	if host != nil {
+		host.wait()
+	}
+	if rl.Jitter > 0 {
+		time.Sleep(time.Duration(rand.Int63n(int64(rl.Jitter))))
+	}
+}
+
+// limiter is a token bucket; a nil or zero-rate limiter never blocks
+type limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newLimiter creates a token bucket allowing rate requests per second
+func newLimiter(rate float64, burst int) *limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &limiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// wait takes a token, sleeping until one is available
+func (l *limiter) wait() {
+	l.mu.Lock()
+	if l.rate <= 0 {
+		l.mu.Unlock()
+		return
+	}
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	// Reserve the token now, even if it is not there yet, so waiters queue up fairly
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	time.Sleep(delay)
+}
+
+// slowDown halves the rate, starting from 10 requests per second when there
+// was no limit and never going below one request every two seconds. It returns
+// the new rate and whether it changed.
+func (l *limiter) slowDown() (float64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch {
+	case l.rate <= 0:
+		l.rate = 10
+		l.tokens = 0
+		l.last = time.Now()
+	case l.rate > 0.5:
+		l.rate /= 2
+		if l.rate < 0.5 {
+			l.rate = 0.5
+		}
+	default:
+		return l.rate, false
+	}
+	return l.rate, true
+}
+
+// speedUp raises a reduced rate by half again, never above max. With no
+// configured maximum the limit is lifted once the rate is back to 100/s.
+func (l *limiter) speedUp(max float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		return
+	}
+
+	l.rate *= 1.5
+	if max > 0 && l.rate > max {
+		l.rate = max
+	}
+	if max <= 0 && l.rate >= 100 {
+		l.rate = 0
+	}
+}
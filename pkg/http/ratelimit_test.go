@@ -0,0 +1,111 @@
+package http
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    RateLimit
+		wantErr bool
+	}{
+		{"polite", Profiles["polite"], false},
+		{"STEALTH", Profiles["stealth"], false},
+		{"aggressive", RateLimit{}, false},
+		{"ludicrous", RateLimit{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := GetProfile(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("GetProfile(%q) = %+v, %v, want %+v, wantErr %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestLimiterWait(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     float64
+		burst    int
+		requests int
+		min      time.Duration
+	}{
+		{"unlimited", 0, 0, 100, 0},
+		{"burst is free", 10, 5, 5, 0},
+		{"rate after the burst", 100, 5, 15, 90 * time.Millisecond},
+		{"one at a time", 50, 0, 6, 90 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		l := newLimiter(tt.rate, tt.burst)
+		start := time.Now()
+		for i := 0; i < tt.requests; i++ {
+			l.wait()
+		}
+		if elapsed := time.Since(start); elapsed < tt.min || elapsed > tt.min+time.Second {
+			t.Errorf("%s: %d requests took %s, want about %s", tt.name, tt.requests, elapsed, tt.min)
+		}
+	}
+}
+
+func TestLimiterSlowDown(t *testing.T) {
+	tests := []struct {
+		rate    float64
+		want    float64
+		changed bool
+	}{
+		{0, 10, true},
+		{10, 5, true},
+		{0.8, 0.5, true},
+		{0.5, 0.5, false},
+	}
+
+	for _, tt := range tests {
+		l := newLimiter(tt.rate, 1)
+		if got, changed := l.slowDown(); got != tt.want || changed != tt.changed {
+			t.Errorf("slowDown from %.2f = %.2f, %v, want %.2f, %v", tt.rate, got, changed, tt.want, tt.changed)
+		}
+	}
+}
+
+func TestLimiterSpeedUp(t *testing.T) {
+	tests := []struct {
+		rate float64
+		max  float64
+		want float64
+	}{
+		{10, 0, 15},
+		{10, 12, 12},
+		{80, 0, 0},
+		{80, 200, 120},
+		{0, 5, 0},
+	}
+
+	for _, tt := range tests {
+		l := newLimiter(tt.rate, 1)
+		if l.speedUp(tt.max); l.rate != tt.want {
+			t.Errorf("speedUp from %.2f with max %.2f = %.2f, want %.2f", tt.rate, tt.max, l.rate, tt.want)
+		}
+	}
+}
+
+func TestThrottlePerHost(t *testing.T) {
+	c := NewClient(5, "")
+	c.SetRateLimit(RateLimit{HostRPS: 20})
+
+	// Each host has its own bucket, so alternating hosts halves the wait
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		c.throttle("https://a.example")
+		c.throttle("https://b.example")
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond || elapsed > time.Second {
+		t.Errorf("3 requests to each of 2 hosts at 20/s per host took %s, want about 100ms", elapsed)
+	}
+	if len(c.hostLimits) != 2 {
+		t.Errorf("%d host limiters, want 2", len(c.hostLimits))
+	}
+}
@@ -0,0 +1,467 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxBodySize is the maximum number of response body bytes read per request
+const MaxBodySize = 10 * 1024 * 1024
+
+// Header is a single HTTP header, kept in order and written exactly as given
+type Header struct {
+	Name  string
+	Value string
+}
+
+// Request is an HTTP request that is written to the wire byte-for-byte.
+// Unlike net/http, nothing in the request line or headers is normalized,
+// escaped or reordered, so payloads such as "%2f", "/./", "//", "\" or "#"
+// reach the server exactly as the technique built them.
+type Request struct {
+	// Method is the request method, written verbatim
+	Method string
+	// Origin is the scheme://host[:port] the connection is made to
+	Origin string
+	// Target is the request-target written on the request line. It may be
+	// origin-form ("/admin%2f"), absolute-form ("http://host/admin") or
+	// anything else a technique wants to send.
+	Target string
+	// Proto is the protocol version written on the request line
+	Proto string
+	// Headers are written in order, duplicates included
+	Headers []Header
+	// Body is written after the headers as-is
+	Body []byte
+
+	// Address, if set, is the host:port connected to instead of the origin's,
+	// while the Host header and TLS server name still name the origin
+	Address string
+	// ServerName, if set, is sent as the TLS server name (SNI) instead of the
+	// origin's host name
+	ServerName string
+	// Anonymous, if set, keeps the client from answering Digest and NTLM
+	// challenges with its credentials, for requests to hosts that are not
+	// the target
+	Anonymous bool
+
+	// Upgrade, if set, is an HTTP/1.1 request sent first to upgrade the
+	// connection to h2c; the request itself then follows as HTTP/2 on the
+	// upgraded connection. See Client.Send.
+	Upgrade *Request
+}
+
+// Response is the parsed response to a raw Request
+type Response struct {
+	StatusCode int
+	Status     string
+	Proto      string
+	Header     http.Header
+	Body       []byte
+	Duration   time.Duration
+	// Raw is the response exactly as read from the connection, status line,
+	// headers and undecoded body included. HTTP/2 responses are rendered in
+	// the same text form.
+	Raw []byte
+	// RemoteAddr is the address the request was sent to
+	RemoteAddr string
+	// Started is when the request began, and Timing how long each phase took
+	Started time.Time
+	Timing  Timing
+	// TLS is the state of the TLS connection, nil for plain HTTP
+	TLS *tls.ConnectionState
+}
+
+// Timing breaks the duration of a request down into its phases
+type Timing struct {
+	// Connect is the time to resolve the host and open the TCP connection
+	Connect time.Duration
+	// TLS is the time spent on the TLS handshake, zero for plain HTTP
+	TLS time.Duration
+	// Send is the time to write the request
+	Send time.Duration
+	// Wait is the time from the request being sent to the response headers arriving
+	Wait time.Duration
+	// Receive is the time to read the response body
+	Receive time.Duration
+}
+
+// SplitURL splits a URL into its scheme://host[:port] origin and the raw
+// request-target that follows it, without decoding or re-escaping anything
+func SplitURL(rawURL string) (origin, target string, err error) {
+	idx := strings.Index(rawURL, "://")
+	if idx <= 0 {
+		return "", "", fmt.Errorf("invalid URL: %s", rawURL)
+	}
+
+	rest := rawURL[idx+3:]
+	end := strings.IndexAny(rest, "/?#")
+	if end == -1 {
+		end = len(rest)
+	}
+	if end == 0 {
+		return "", "", fmt.Errorf("invalid URL, missing host: %s", rawURL)
+	}
+
+	origin = rawURL[:idx+3+end]
+	target = rest[end:]
+	if target == "" || target[0] != '/' {
+		target = "/" + target
+	}
+
+	return origin, target, nil
+}
+
+// Origin returns the origin of a URL the way requests to it carry it: the
+// scheme lowercased and any userinfo dropped
+func Origin(rawURL string) (string, error) {
+	scheme, host, _, err := splitOrigin(rawURL)
+	if err != nil {
+		return "", err
+	}
+	return scheme + "://" + host, nil
+}
+
+// NewRequest creates a raw request for target on the given origin. The Host
+// header is derived from the origin and the connection is closed after the
+// response so that every request travels on a fresh connection.
+func NewRequest(method, origin, target string) (*Request, error) {
+	scheme, host, _, err := splitOrigin(origin)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &Request{
+		Method: method,
+		Origin: scheme + "://" + host,
+		Target: target,
+		Proto:  "HTTP/1.1",
+		Headers: []Header{
+			{"Host", host},
+		},
+	}
+
+	// Mirror net/http, which always sends a length for body-carrying methods
+	switch method {
+	case "POST", "PUT", "PATCH":
+		req.Headers = append(req.Headers, Header{"Content-Length", "0"})
+	}
+
+	req.Headers = append(req.Headers, Header{"Connection", "close"})
+
+	return req, nil
+}
+
+// URL returns the request as a URL string, with the target appended to the origin
+// unchanged. Absolute-form targets are returned as they are.
+func (r *Request) URL() string {
+	if !strings.HasPrefix(r.Target, "/") && strings.Contains(r.Target, "://") {
+		return r.Target
+	}
+	return r.Origin + r.Target
+}
+
+// Header returns the value of the first header matching name, case-insensitively
+func (r *Request) Header(name string) string {
+	for _, h := range r.Headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// SetHeader replaces every header matching name with a single header carrying
+// value, or appends it if the request does not have one yet
+func (r *Request) SetHeader(name, value string) {
+	replaced := false
+	headers := r.Headers[:0]
+	for _, h := range r.Headers {
+		if strings.EqualFold(h.Name, name) {
+			if replaced {
+				continue
+			}
+			h = Header{name, value}
+			replaced = true
+		}
+		headers = append(headers, h)
+	}
+	r.Headers = headers
+
+	if !replaced {
+		r.Headers = append(r.Headers, Header{name, value})
+	}
+}
+
+// AddHeader appends a header, keeping any existing headers with the same name
+func (r *Request) AddHeader(name, value string) {
+	r.Headers = append(r.Headers, Header{name, value})
+}
+
+// DelHeader removes every header matching name
+func (r *Request) DelHeader(name string) {
+	headers := r.Headers[:0]
+	for _, h := range r.Headers {
+		if !strings.EqualFold(h.Name, name) {
+			headers = append(headers, h)
+		}
+	}
+	r.Headers = headers
+}
+
+// SetBody sets the request body and a matching Content-Length header
+func (r *Request) SetBody(body []byte) {
+	r.Body = body
+	r.SetHeader("Content-Length", strconv.Itoa(len(body)))
+}
+
+// Clone returns a deep copy of the request
+func (r *Request) Clone() *Request {
+	clone := *r
+	clone.Headers = append([]Header(nil), r.Headers...)
+	if r.Body != nil {
+		clone.Body = append([]byte(nil), r.Body...)
+	}
+	if r.Upgrade != nil {
+		clone.Upgrade = r.Upgrade.Clone()
+	}
+	return &clone
+}
+
+// Bytes returns the exact bytes written to the wire for this request. HTTP/2
+// requests are rendered in the same text form, pseudo-headers included.
+func (r *Request) Bytes() []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString(r.Method + " " + r.Target + " " + r.Proto + "\r\n")
+	for _, h := range r.Headers {
+		buf.WriteString(h.Name + ": " + h.Value + "\r\n")
+	}
+	buf.WriteString("\r\n")
+	buf.Write(r.Body)
+
+	return buf.Bytes()
+}
+
+// Send writes the raw request to a new connection and reads the response.
+// Redirects are never followed. Requests with an Upgrade request that the
+// server does not switch protocols for return an *UpgradeError carrying the
+// response to the upgrade. The client's rate limit is applied first, and
+// throttled requests are retried with back-off according to the retry policy;
+// if they are still throttled after that a *ThrottledError is returned.
+func (c *Client) Send(req *Request) (*Response, error) {
+	for attempt := 0; ; attempt++ {
+		c.waitForBreaker()
+		c.throttle(req.Origin)
+
+		resp, err := c.roundTripAuth(req)
+
+		throttled, retryAfter := c.throttledBy(req.Origin, resp, err)
+		if !throttled {
+			if err == nil {
+				c.recordSuccess()
+			}
+			return resp, err
+		}
+
+		c.recordThrottle()
+		if attempt >= c.retry.MaxRetries {
+			return nil, &ThrottledError{Response: resp, Err: err}
+		}
+
+		time.Sleep(c.backoff(attempt, retryAfter))
+	}
+}
+
+// roundTrip sends the request once on a fresh connection, as HTTP/2 if the
+// request or the client's HTTP/2 mode asks for it
+func (c *Client) roundTrip(req *Request) (*Response, error) {
+	start := time.Now()
+	var timing Timing
+
+	conn, err := c.dial(req, &timing)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if c.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(c.Timeout))
+	}
+
+	var resp *Response
+	switch {
+	case req.Upgrade != nil:
+		resp, err = c.roundTripH2C(req, conn, &timing)
+	case c.sendsHTTP2(req, conn):
+		resp, err = c.roundTripHTTP2(req, conn, &timing)
+	case c.ntlmTransaction(req):
+		resp, err = c.roundTripNTLM(req, conn, &timing)
+	default:
+		resp, err = c.roundTripHTTP1(req, conn, &timing)
+	}
+
+	var upgrade *UpgradeError
+	if errors.As(err, &upgrade) {
+		resp = upgrade.Response
+	} else if err != nil {
+		return nil, err
+	}
+
+	resp.Duration = time.Since(start)
+	resp.RemoteAddr = conn.RemoteAddr().String()
+	resp.Started = start
+	resp.Timing = timing
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		state := tlsConn.ConnectionState()
+		resp.TLS = &state
+	}
+	return resp, err
+}
+
+// roundTripHTTP1 writes the request as-is and reads the HTTP/1.x response
+func (c *Client) roundTripHTTP1(req *Request, conn net.Conn, timing *Timing) (*Response, error) {
+	mark := time.Now()
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		return nil, fmt.Errorf("error writing request: %w", err)
+	}
+	timing.Send = time.Since(mark)
+
+	// Keep a copy of everything read so the response can be exported byte for byte
+	var raw bytes.Buffer
+	return readHTTP1(bufio.NewReader(io.TeeReader(conn, &raw)), &raw, req.Method, timing)
+}
+
+// readHTTP1 reads an HTTP/1.x response to a request with the given method.
+// raw must receive everything r reads from the connection.
+func readHTTP1(r *bufio.Reader, raw *bytes.Buffer, method string, timing *Timing) (*Response, error) {
+	mark := time.Now()
+	resp, err := http.ReadResponse(r, &http.Request{Method: method})
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+	defer resp.Body.Close()
+	timing.Wait = time.Since(mark)
+
+	mark = time.Now()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxBodySize))
+	if err != nil && len(body) == 0 && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+	timing.Receive = time.Since(mark)
+
+	return &Response{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Proto:      resp.Proto,
+		Header:     resp.Header,
+		Body:       body,
+		Raw:        raw.Bytes(),
+	}, nil
+}
+
+// dial opens a connection for the request, to the origin or the address it
+// is resolved to, through the upstream proxy if one applies, performing the TLS
+// handshake for https, and records how long each step took
+func (c *Client) dial(req *Request, timing *Timing) (net.Conn, error) {
+	scheme, host, port, err := splitOrigin(req.Origin)
+	if err != nil {
+		return nil, err
+	}
+
+	address := req.Address
+	if address == "" {
+		address = c.resolve(hostname(host), port)
+	}
+	connectHost, connectPort, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connect address %q: %s", address, err)
+	}
+
+	mark := time.Now()
+	var conn net.Conn
+	if proxy := c.proxy.proxyFor(scheme, host); proxy != nil {
+		conn, err = c.dialProxy(proxy, connectHost, connectPort)
+	} else {
+		dialer := &net.Dialer{Timeout: c.Timeout}
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	timing.Connect = time.Since(mark)
+
+	if scheme != "https" {
+		return conn, nil
+	}
+
+	tlsConfig := c.tlsConfig.Clone()
+	tlsConfig.ServerName = hostname(host)
+	if c.serverName != "" {
+		tlsConfig.ServerName = c.serverName
+	}
+	if req.ServerName != "" {
+		tlsConfig.ServerName = req.ServerName
+	}
+	tlsConfig.NextProtos, _ = c.http2Protocols(req)
+	tlsConn := tls.Client(conn, tlsConfig)
+	if c.Timeout > 0 {
+		tlsConn.SetDeadline(time.Now().Add(c.Timeout))
+	}
+	mark = time.Now()
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("TLS handshake failed: %s", err)
+	}
+	timing.TLS = time.Since(mark)
+
+	return tlsConn, nil
+}
+
+// splitOrigin returns the scheme, host (as written, including any port) and
+// port to connect to for an origin or full URL
+func splitOrigin(origin string) (scheme, host, port string, err error) {
+	origin, _, err = SplitURL(origin)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	idx := strings.Index(origin, "://")
+	scheme = strings.ToLower(origin[:idx])
+	host = origin[idx+3:]
+	if at := strings.LastIndex(host, "@"); at != -1 {
+		host = host[at+1:]
+	}
+
+	switch scheme {
+	case "http":
+		port = "80"
+	case "https":
+		port = "443"
+	default:
+		return "", "", "", fmt.Errorf("unsupported scheme: %s", scheme)
+	}
+
+	if _, p, err := net.SplitHostPort(host); err == nil && p != "" {
+		port = p
+	}
+
+	return scheme, host, port, nil
+}
+
+// hostname strips the port and IPv6 brackets from a host
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return strings.Trim(host, "[]")
+}
@@ -0,0 +1,124 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSplitURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		origin  string
+		target  string
+		wantErr bool
+	}{
+		{"https://example.com/admin?x=1", "https://example.com", "/admin?x=1", false},
+		{"https://example.com", "https://example.com", "/", false},
+		{"http://example.com:8080?x=1", "http://example.com:8080", "/?x=1", false},
+		{"http://[::1]:8080/a/../b", "http://[::1]:8080", "/a/../b", false},
+		{"https://example.com//admin//", "https://example.com", "//admin//", false},
+		{"example.com/admin", "", "", true},
+		{"https:///admin", "", "", true},
+	}
+
+	for _, tt := range tests {
+		origin, target, err := SplitURL(tt.url)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SplitURL(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+			continue
+		}
+		if origin != tt.origin || target != tt.target {
+			t.Errorf("SplitURL(%q) = %q, %q, want %q, %q", tt.url, origin, target, tt.origin, tt.target)
+		}
+	}
+}
+
+func TestReadHTTP1(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		data    string
+		status  int
+		header  string
+		body    string
+		wantErr bool
+	}{
+		{
+			name:   "Content-Length",
+			method: "GET",
+			data:   "HTTP/1.1 200 OK\r\nContent-Length: 5\r\nServer: test\r\n\r\nhello",
+			status: 200,
+			header: "test",
+			body:   "hello",
+		},
+		{
+			name:   "chunked",
+			method: "GET",
+			data:   "HTTP/1.1 403 Forbidden\r\nTransfer-Encoding: chunked\r\nServer: test\r\n\r\n4\r\nnope\r\n3\r\n!!!\r\n0\r\n\r\n",
+			status: 403,
+			header: "test",
+			body:   "nope!!!",
+		},
+		{
+			name:   "HEAD has no body",
+			method: "HEAD",
+			data:   "HTTP/1.1 200 OK\r\nContent-Length: 1234\r\nServer: test\r\n\r\n",
+			status: 200,
+			header: "test",
+		},
+		{
+			name:   "body read until close",
+			method: "GET",
+			data:   "HTTP/1.0 200 OK\r\nServer: test\r\n\r\nuntil the end",
+			status: 200,
+			header: "test",
+			body:   "until the end",
+		},
+		{
+			name:   "truncated body keeps what arrived",
+			method: "GET",
+			data:   "HTTP/1.1 200 OK\r\nContent-Length: 100\r\nServer: test\r\n\r\npartial",
+			status: 200,
+			header: "test",
+			body:   "partial",
+		},
+		{
+			name:   "no content",
+			method: "DELETE",
+			data:   "HTTP/1.1 204 No Content\r\nServer: test\r\n\r\n",
+			status: 204,
+			header: "test",
+		},
+		{name: "not HTTP", method: "GET", data: "SSH-2.0-OpenSSH_9.6\r\n", wantErr: true},
+		{name: "empty", method: "GET", data: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		var raw bytes.Buffer
+		r := bufio.NewReader(io.TeeReader(strings.NewReader(tt.data), &raw))
+		resp, err := readHTTP1(r, &raw, tt.method, &Timing{})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+		if got := resp.Header.Get("Server"); got != tt.header {
+			t.Errorf("%s: Server header = %q, want %q", tt.name, got, tt.header)
+		}
+		if string(resp.Body) != tt.body {
+			t.Errorf("%s: body = %q, want %q", tt.name, resp.Body, tt.body)
+		}
+		if string(resp.Raw) != tt.data {
+			t.Errorf("%s: raw = %q, want the response as read, %q", tt.name, resp.Raw, tt.data)
+		}
+	}
+}
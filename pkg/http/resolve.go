@@ -0,0 +1,80 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ParseResolve parses curl --resolve style entries, "host:port:address", into
+// a map from host:port to the address connected to instead. The address may
+// carry its own port ("10.0.0.5:8443", "[2001:db8::1]:8443"), otherwise the
+// port is kept. A host of "*" matches every host on that port.
+func ParseResolve(entries []string) (map[string]string, error) {
+	resolve := make(map[string]string)
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("invalid resolve entry %q, expected host:port:address", entry)
+		}
+		host, port, address := strings.ToLower(strings.Trim(parts[0], "[]")), parts[1], parts[2]
+
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			// No port of its own: a bare IPv4 or IPv6 address, or a host name
+			address = net.JoinHostPort(strings.Trim(address, "[]"), port)
+		}
+		resolve[net.JoinHostPort(host, port)] = address
+	}
+	return resolve, nil
+}
+
+// SetResolve makes connections to the hosts in resolve go to other addresses,
+// like curl --resolve. The Host header and TLS server name are unchanged, so
+// every technique can be run against e.g. an origin server behind a CDN.
+func (c *Client) SetResolve(resolve map[string]string) {
+	c.resolveMap = resolve
+}
+
+// SetServerName sends name as the TLS server name (SNI) of every connection,
+// whatever the origin and Host header are
+func (c *Client) SetServerName(name string) {
+	c.serverName = name
+}
+
+// Lookup resolves the host of an origin the way connections to it are made.
+// If -resolve overrides it, the connect address is returned and nothing is
+// looked up; otherwise addrs are what the system resolver returns for the host.
+func (c *Client) Lookup(origin string) (override string, addrs []string, err error) {
+	_, host, port, err := splitOrigin(origin)
+	if err != nil {
+		return "", nil, err
+	}
+
+	name := hostname(host)
+	if address := c.resolve(name, port); address != net.JoinHostPort(name, port) {
+		return address, nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	addrs, err = net.DefaultResolver.LookupHost(ctx, name)
+	return "", addrs, err
+}
+
+// resolve returns the address to connect to for host and port
+func (c *Client) resolve(host, port string) string {
+	address := net.JoinHostPort(host, port)
+	if override, ok := c.resolveMap[net.JoinHostPort(strings.ToLower(host), port)]; ok {
+		return override
+	}
+	if override, ok := c.resolveMap[net.JoinHostPort("*", port)]; ok {
+		return override
+	}
+	return address
+}
@@ -0,0 +1,36 @@
+package http
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseResolve(t *testing.T) {
+	tests := []struct {
+		entries []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{nil, map[string]string{}, false},
+		{[]string{"example.com:443:10.0.0.5"}, map[string]string{"example.com:443": "10.0.0.5:443"}, false},
+		{[]string{"Example.COM:443:10.0.0.5:8443"}, map[string]string{"example.com:443": "10.0.0.5:8443"}, false},
+		{[]string{"example.com:80:2001:db8::1"}, map[string]string{"example.com:80": "[2001:db8::1]:80"}, false},
+		{[]string{"example.com:80:[2001:db8::1]"}, map[string]string{"example.com:80": "[2001:db8::1]:80"}, false},
+		{[]string{"example.com:443:[2001:db8::1]:8443"}, map[string]string{"example.com:443": "[2001:db8::1]:8443"}, false},
+		{[]string{"*:443:10.0.0.5", " ", "a.example:80:10.0.0.6"}, map[string]string{"*:443": "10.0.0.5:443", "a.example:80": "10.0.0.6:80"}, false},
+		{[]string{"example.com:443"}, nil, true},
+		{[]string{"example.com::10.0.0.5"}, nil, true},
+		{[]string{":443:10.0.0.5"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseResolve(tt.entries)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseResolve(%q) error = %v, wantErr %v", tt.entries, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseResolve(%q) = %v, want %v", tt.entries, got, tt.want)
+		}
+	}
+}
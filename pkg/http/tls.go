@@ -0,0 +1,121 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// TLSOptions configure certificate verification and client certificates.
+// The zero value accepts any server certificate, which is what a scanner
+// usually wants.
+type TLSOptions struct {
+	// Verify enables certificate chain and host name verification
+	Verify bool
+	// CAFile is a PEM bundle of CAs trusted instead of the system roots. It
+	// enables verification too, since the bundle would go unused otherwise.
+	CAFile string
+	// CertFile is a client certificate, either PEM (with the key in KeyFile or
+	// in the same file) or PKCS#12 (.p12/.pfx) protected by CertPassword
+	CertFile     string
+	KeyFile      string
+	CertPassword string
+	// MinVersion is the lowest TLS version offered: 1.0, 1.1, 1.2 or 1.3
+	MinVersion string
+}
+
+// tlsVersions maps the accepted MinVersion values to their constants
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Config builds the TLS configuration shared by every connection. Files are
+// read here, so errors show up before the scan starts.
+func (o TLSOptions) Config() (*tls.Config, error) {
+	cfg := &tls.Config{InsecureSkipVerify: !o.Verify && o.CAFile == ""}
+
+	if o.MinVersion != "" {
+		version, ok := tlsVersions[strings.TrimPrefix(o.MinVersion, "TLS")]
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS version %q (use 1.0, 1.1, 1.2 or 1.3)", o.MinVersion)
+		}
+		cfg.MinVersion = version
+	}
+
+	if o.CAFile != "" {
+		data, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA bundle: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no PEM certificates found in CA bundle %s", o.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if o.CertFile != "" {
+		cert, err := o.clientCertificate()
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	} else if o.KeyFile != "" {
+		return nil, errors.New("a client key needs a client certificate")
+	}
+
+	return cfg, nil
+}
+
+// clientCertificate loads the client certificate from PEM or PKCS#12
+func (o TLSOptions) clientCertificate() (tls.Certificate, error) {
+	data, err := os.ReadFile(o.CertFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("error reading client certificate: %s", err)
+	}
+
+	// Anything that isn't PEM is taken as PKCS#12
+	if block, _ := pem.Decode(data); block == nil {
+		key, cert, chain, err := pkcs12.DecodeChain(data, o.CertPassword)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("error decoding PKCS#12 client certificate: %s", err)
+		}
+		certificate := tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key, Leaf: cert}
+		for _, ca := range chain {
+			certificate.Certificate = append(certificate.Certificate, ca.Raw)
+		}
+		return certificate, nil
+	}
+
+	keyData := data
+	if o.KeyFile != "" {
+		if keyData, err = os.ReadFile(o.KeyFile); err != nil {
+			return tls.Certificate{}, fmt.Errorf("error reading client key: %s", err)
+		}
+	}
+	cert, err := tls.X509KeyPair(data, keyData)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("error loading client certificate: %s", err)
+	}
+	return cert, nil
+}
+
+// SetTLS applies the TLS options to every connection the client opens
+func (c *Client) SetTLS(opts TLSOptions) error {
+	cfg, err := opts.Config()
+	if err != nil {
+		return err
+	}
+
+	c.tlsConfig = cfg
+	return nil
+}
@@ -0,0 +1,284 @@
+package matcher
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ibrahimsql/bypass403/pkg/bypass"
+)
+
+// Options holds the user-supplied match and filter expressions, in ffuf syntax
+type Options struct {
+	MatchStatus  string
+	MatchSize    string
+	MatchWords   string
+	MatchLines   string
+	MatchRegex   string
+	MatchHeader  string
+	MatchTime    string
+	FilterStatus string
+	FilterSize   string
+	FilterWords  string
+	FilterLines  string
+	FilterRegex  string
+	FilterHeader string
+	FilterTime   string
+
+	// Mode is "or" (any matcher) or "and" (every matcher)
+	Mode          string
+	MinConfidence int
+}
+
+// Range is an inclusive range of integers
+type Range struct {
+	Min int
+	Max int
+}
+
+// TimeRule compares the response time against a threshold
+type TimeRule struct {
+	Above     bool
+	Threshold time.Duration
+}
+
+// rule is a single parsed matcher or filter
+type rule struct {
+	status  []Range
+	size    []Range
+	words   []Range
+	lines   []Range
+	regex   *regexp.Regexp
+	header  *regexp.Regexp
+	time    *TimeRule
+	anyCode bool
+}
+
+// Rules decides which results count as a bypass. Without any matchers the
+// baseline classification decides; filters always apply on top.
+type Rules struct {
+	match         rule
+	filter        rule
+	andMode       bool
+	minConfidence int
+}
+
+// New parses the options into a set of rules
+func New(opts Options) (*Rules, error) {
+	rules := &Rules{minConfidence: opts.MinConfidence}
+
+	switch strings.ToLower(opts.Mode) {
+	case "", "or":
+	case "and":
+		rules.andMode = true
+	default:
+		return nil, fmt.Errorf("invalid matcher mode: %s (use and/or)", opts.Mode)
+	}
+
+	if opts.MinConfidence < 0 || opts.MinConfidence > 100 {
+		return nil, fmt.Errorf("minimum confidence must be between 0 and 100")
+	}
+
+	var err error
+	if rules.match, err = parseRule(opts.MatchStatus, opts.MatchSize, opts.MatchWords, opts.MatchLines,
+		opts.MatchRegex, opts.MatchHeader, opts.MatchTime); err != nil {
+		return nil, fmt.Errorf("invalid matcher: %s", err)
+	}
+	if rules.filter, err = parseRule(opts.FilterStatus, opts.FilterSize, opts.FilterWords, opts.FilterLines,
+		opts.FilterRegex, opts.FilterHeader, opts.FilterTime); err != nil {
+		return nil, fmt.Errorf("invalid filter: %s", err)
+	}
+
+	return rules, nil
+}
+
+// Match reports whether a classified result counts as a bypass
+func (r *Rules) Match(result bypass.Result) bool {
+	if result.Throttled || result.UpgradeRefused || result.StatusCode == 0 || result.Confidence < r.minConfidence {
+		return false
+	}
+
+	checks := r.match.checks(result)
+	if len(checks) == 0 {
+		// No matchers configured: trust the comparison with the baseline
+		if result.Classification != bypass.ClassBypass {
+			return false
+		}
+	} else if !combine(checks, r.andMode) {
+		return false
+	}
+
+	// Any filter that matches drops the result
+	return !combine(r.filter.checks(result), false)
+}
+
+// checks evaluates every configured part of the rule against the result
+func (ru rule) checks(result bypass.Result) []bool {
+	var checks []bool
+
+	if ru.anyCode {
+		checks = append(checks, true)
+	} else if ru.status != nil {
+		checks = append(checks, inRanges(ru.status, result.StatusCode))
+	}
+	if ru.size != nil {
+		checks = append(checks, inRanges(ru.size, result.ContentLength))
+	}
+	if ru.words != nil {
+		checks = append(checks, inRanges(ru.words, result.Words))
+	}
+	if ru.lines != nil {
+		checks = append(checks, inRanges(ru.lines, result.Lines))
+	}
+	if ru.regex != nil {
+		checks = append(checks, ru.regex.Match(result.Response.Body))
+	}
+	if ru.header != nil {
+		checks = append(checks, ru.header.MatchString(headerBlock(result.Response.Headers)))
+	}
+	if ru.time != nil {
+		if ru.time.Above {
+			checks = append(checks, result.Response.Time > ru.time.Threshold)
+		} else {
+			checks = append(checks, result.Response.Time < ru.time.Threshold)
+		}
+	}
+
+	return checks
+}
+
+// combine folds the checks with AND or OR; no checks means no match
+func combine(checks []bool, and bool) bool {
+	if len(checks) == 0 {
+		return false
+	}
+	for _, c := range checks {
+		if and && !c {
+			return false
+		}
+		if !and && c {
+			return true
+		}
+	}
+	return and
+}
+
+// parseRule parses one set of matcher or filter expressions
+func parseRule(status, size, words, lines, regex, header, timing string) (rule, error) {
+	var ru rule
+	var err error
+
+	if strings.EqualFold(strings.TrimSpace(status), "all") {
+		ru.anyCode = true
+	} else if ru.status, err = ParseRanges(status); err != nil {
+		return ru, fmt.Errorf("status %q: %s", status, err)
+	}
+	if ru.size, err = ParseRanges(size); err != nil {
+		return ru, fmt.Errorf("size %q: %s", size, err)
+	}
+	if ru.words, err = ParseRanges(words); err != nil {
+		return ru, fmt.Errorf("words %q: %s", words, err)
+	}
+	if ru.lines, err = ParseRanges(lines); err != nil {
+		return ru, fmt.Errorf("lines %q: %s", lines, err)
+	}
+	if regex != "" {
+		if ru.regex, err = regexp.Compile(regex); err != nil {
+			return ru, fmt.Errorf("regex %q: %s", regex, err)
+		}
+	}
+	if header != "" {
+		// Header regexes run against "Name: value" lines, one per header
+		if ru.header, err = regexp.Compile("(?im)" + header); err != nil {
+			return ru, fmt.Errorf("header regex %q: %s", header, err)
+		}
+	}
+	if ru.time, err = ParseTime(timing); err != nil {
+		return ru, fmt.Errorf("time %q: %s", timing, err)
+	}
+
+	return ru, nil
+}
+
+// ParseRanges parses a comma-separated list of numbers and ranges, e.g. "200,300-399"
+func ParseRanges(s string) ([]Range, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var ranges []Range
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		bounds := strings.SplitN(part, "-", 2)
+		min, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", bounds[0])
+		}
+		max := min
+		if len(bounds) == 2 {
+			if max, err = strconv.Atoi(strings.TrimSpace(bounds[1])); err != nil {
+				return nil, fmt.Errorf("invalid number %q", bounds[1])
+			}
+		}
+		if max < min {
+			return nil, fmt.Errorf("invalid range %q", part)
+		}
+
+		ranges = append(ranges, Range{min, max})
+	}
+
+	return ranges, nil
+}
+
+// ParseTime parses a response time threshold in milliseconds, e.g. ">500" or "<100"
+func ParseTime(s string) (*TimeRule, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	if len(s) < 2 || (s[0] != '>' && s[0] != '<') {
+		return nil, fmt.Errorf("expected >N or <N milliseconds")
+	}
+
+	ms, err := strconv.Atoi(strings.TrimSpace(s[1:]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q", s[1:])
+	}
+
+	return &TimeRule{Above: s[0] == '>', Threshold: time.Duration(ms) * time.Millisecond}, nil
+}
+
+// inRanges reports whether n falls in any of the ranges
+func inRanges(ranges []Range, n int) bool {
+	for _, r := range ranges {
+		if n >= r.Min && n <= r.Max {
+			return true
+		}
+	}
+	return false
+}
+
+// headerBlock renders response headers as sorted "Name: value" lines
+func headerBlock(headers map[string][]string) string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var block strings.Builder
+	for _, name := range names {
+		for _, value := range headers[name] {
+			block.WriteString(name + ": " + value + "\n")
+		}
+	}
+	return block.String()
+}
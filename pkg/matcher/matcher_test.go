@@ -0,0 +1,142 @@
+package matcher
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ibrahimsql/bypass403/pkg/bypass"
+)
+
+func TestParseRanges(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []Range
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"200", []Range{{200, 200}}, false},
+		{"200,300-399", []Range{{200, 200}, {300, 399}}, false},
+		{" 200 , 204 ,", []Range{{200, 200}, {204, 204}}, false},
+		{"0-100", []Range{{0, 100}}, false},
+		{"399-300", nil, true},
+		{"abc", nil, true},
+		{"200-x", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRanges(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRanges(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseRanges(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    *TimeRule
+		wantErr bool
+	}{
+		{"", nil, false},
+		{">500", &TimeRule{Above: true, Threshold: 500 * time.Millisecond}, false},
+		{"<100", &TimeRule{Above: false, Threshold: 100 * time.Millisecond}, false},
+		{"500", nil, true},
+		{">", nil, true},
+		{">fast", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTime(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTime(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseTime(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	tests := []Options{
+		{Mode: "xor"},
+		{MinConfidence: 101},
+		{MatchStatus: "2xx"},
+		{MatchRegex: "("},
+		{FilterHeader: "["},
+		{FilterTime: "=5"},
+	}
+
+	for _, opts := range tests {
+		if _, err := New(opts); err == nil {
+			t.Errorf("New(%+v) succeeded, want an error", opts)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	bypassed := bypass.Result{
+		StatusCode:     200,
+		Fingerprint:    bypass.Fingerprint{ContentLength: 120, Words: 12, Lines: 3},
+		Classification: bypass.ClassBypass,
+		Confidence:     90,
+		Response: bypass.ResponseEvidence{
+			Headers: map[string][]string{"Server": {"nginx"}, "X-Cache": {"MISS"}},
+			Body:    []byte("<title>Admin</title>welcome back"),
+			Time:    300 * time.Millisecond,
+		},
+	}
+	redirect := bypassed
+	redirect.StatusCode = 302
+	redirect.Classification = bypass.ClassRedirect
+	redirect.Confidence = 40
+
+	throttled := bypassed
+	throttled.Throttled = true
+
+	refused := bypassed
+	refused.UpgradeRefused = true
+
+	tests := []struct {
+		name   string
+		opts   Options
+		result bypass.Result
+		want   bool
+	}{
+		{"classification decides", Options{}, bypassed, true},
+		{"classification rejects", Options{}, redirect, false},
+		{"throttled never matches", Options{MatchStatus: "all"}, throttled, false},
+		{"refused upgrade never matches", Options{MatchStatus: "all"}, refused, false},
+		{"status range", Options{MatchStatus: "300-399"}, redirect, true},
+		{"status miss", Options{MatchStatus: "200"}, redirect, false},
+		{"all codes", Options{MatchStatus: "all"}, redirect, true},
+		{"size", Options{MatchSize: "100-200"}, bypassed, true},
+		{"words miss", Options{MatchWords: "1-5"}, bypassed, false},
+		{"lines", Options{MatchLines: "3"}, bypassed, true},
+		{"regex", Options{MatchRegex: "welcome"}, bypassed, true},
+		{"header", Options{MatchHeader: "^x-cache: miss$"}, bypassed, true},
+		{"time above", Options{MatchTime: ">200"}, bypassed, true},
+		{"time below", Options{MatchTime: "<200"}, bypassed, false},
+		{"or mode", Options{MatchStatus: "500", MatchRegex: "welcome"}, bypassed, true},
+		{"and mode", Options{MatchStatus: "500", MatchRegex: "welcome", Mode: "and"}, bypassed, false},
+		{"filter status", Options{FilterStatus: "200"}, bypassed, false},
+		{"filter size miss", Options{FilterSize: "0"}, bypassed, true},
+		{"filter regex", Options{MatchStatus: "all", FilterRegex: "Admin"}, bypassed, false},
+		{"min confidence", Options{MatchStatus: "all", MinConfidence: 50}, redirect, false},
+	}
+
+	for _, tt := range tests {
+		rules, err := New(tt.opts)
+		if err != nil {
+			t.Fatalf("%s: New: %s", tt.name, err)
+		}
+		if got := rules.Match(tt.result); got != tt.want {
+			t.Errorf("%s: Match = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,113 +1,164 @@
 package output
 
 import (
+	"encoding/base64"
 	"fmt"
+	"net"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/ibrahimsql/bypass403/pkg/bypass"
 )
 
-// GenerateBurpSuiteProject creates a Burp Suite project file for successful bypasses
-func GenerateBurpSuiteProject(results []bypass.Result, filename string) error {
-	if !strings.HasSuffix(filename, ".burp") {
-		filename += ".burp"
-	}
+// burpDoctype is the document type declaration Burp writes in its "Save items" export
+const burpDoctype = `<!DOCTYPE items [
+<!ELEMENT items (item*)>
+<!ATTLIST items burpVersion CDATA "">
+<!ATTLIST items exportTime CDATA "">
+<!ELEMENT item (time, url, host, port, protocol, method, path, extension, request, status, responselength, mimetype, response, comment)>
+<!ELEMENT time (#PCDATA)>
+<!ELEMENT url (#PCDATA)>
+<!ELEMENT host (#PCDATA)>
+<!ATTLIST host ip CDATA "">
+<!ELEMENT port (#PCDATA)>
+<!ELEMENT protocol (#PCDATA)>
+<!ELEMENT method (#PCDATA)>
+<!ELEMENT path (#PCDATA)>
+<!ELEMENT extension (#PCDATA)>
+<!ELEMENT request (#PCDATA)>
+<!ATTLIST request base64 (true|false) "false">
+<!ELEMENT status (#PCDATA)>
+<!ELEMENT responselength (#PCDATA)>
+<!ELEMENT mimetype (#PCDATA)>
+<!ELEMENT response (#PCDATA)>
+<!ATTLIST response base64 (true|false) "false">
+<!ELEMENT comment (#PCDATA)>
+]>
+`
+
+// burpTimeFormat is the Java Date format Burp uses for timestamps
+const burpTimeFormat = "Mon Jan 02 15:04:05 MST 2006"
 
+// GenerateBurpItems writes confirmed bypasses in Burp Suite's "Save items" XML
+// format, with the exact request and response bytes base64 encoded, so they can
+// be imported and sent to Repeater
+func GenerateBurpItems(results []bypass.Result, filename string) error {
 	file, err := os.Create(filename)
 	if err != nil {
-		return fmt.Errorf("error creating Burp Suite project file: %s", err)
+		return fmt.Errorf("error creating Burp Suite items file: %s", err)
 	}
 	defer file.Close()
 
-	// Write Burp Suite XML format
-	file.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
-	file.WriteString("<items burpVersion=\"2023.1.2\" exportTime=\"" + time.Now().Format(time.RFC3339) + "\">\n")
+	var items strings.Builder
+	items.WriteString("<?xml version=\"1.0\"?>\n")
+	items.WriteString(burpDoctype)
+	items.WriteString("<items burpVersion=\"2023.1.2\" exportTime=\"" + time.Now().Format(burpTimeFormat) + "\">\n")
 
-	for _, result := range results {
-		if result.StatusCode != 403 && result.StatusCode != 404 {
-			// Only include successful bypasses
-			file.WriteString(generateBurpItem(result))
+	// Only include results the match rules accepted as bypasses, with the
+	// items of each target kept together
+	for _, group := range bypass.GroupByTarget(nil, results) {
+		for _, result := range group.Results {
+			if result.IsBypass() {
+				items.WriteString(generateBurpItem(result))
+			}
 		}
 	}
 
-	file.WriteString("</items>\n")
+	items.WriteString("</items>\n")
+
+	if _, err := file.WriteString(items.String()); err != nil {
+		return fmt.Errorf("error writing Burp Suite items file: %s", err)
+	}
 	return nil
 }
 
 // generateBurpItem creates a Burp Suite item for a bypass result
 func generateBurpItem(result bypass.Result) string {
 	parsedURL := parseURL(result.URL)
+	path := parsedURL.Path
+	if parsedURL.RawQuery != "" {
+		path += "?" + parsedURL.RawQuery
+	}
+
+	// The connection target and raw request-target are known exactly when the request was recorded
+	if result.Request != nil {
+		parsedURL = parseURL(result.Request.Origin)
+		path = result.Request.Target
+	}
+
+	request := []byte(generateRequest(result))
+	response := result.Response.Raw
+	if response == nil {
+		response = []byte(generateResponse(result))
+	}
+
+	ip := ""
+	if host, _, err := net.SplitHostPort(result.Response.RemoteAddr); err == nil {
+		ip = host
+	}
 
 	var burpItem strings.Builder
 
 	burpItem.WriteString("  <item>\n")
-	burpItem.WriteString("    <time>" + time.Now().Format(time.RFC3339) + "</time>\n")
-	burpItem.WriteString("    <url>" + escapeXML(result.URL) + "</url>\n")
-	burpItem.WriteString("    <host>" + escapeXML(parsedURL.Host) + "</host>\n")
+	burpItem.WriteString("    <time>" + time.Now().Format(burpTimeFormat) + "</time>\n")
+	burpItem.WriteString("    <url>" + cdata(result.URL) + "</url>\n")
+	burpItem.WriteString("    <host ip=\"" + escapeXML(ip) + "\">" + escapeXML(hostname(parsedURL.Host)) + "</host>\n")
 	burpItem.WriteString("    <port>" + getPort(parsedURL) + "</port>\n")
 	burpItem.WriteString("    <protocol>" + escapeXML(parsedURL.Scheme) + "</protocol>\n")
-	burpItem.WriteString("    <method>" + escapeXML(result.Method) + "</method>\n")
-	burpItem.WriteString("    <path>" + escapeXML(parsedURL.Path) + "</path>\n")
-
-	// Generate request
-	request := generateRequest(result)
-	burpItem.WriteString("    <request base64=\"false\">" + escapeXML(request) + "</request>\n")
-
-	// Generate response placeholder
-	burpItem.WriteString("    <response base64=\"false\">HTTP/1.1 " + fmt.Sprintf("%d", result.StatusCode) + " " + getStatusText(result.StatusCode) + "\r\n\r\n</response>\n")
-
+	burpItem.WriteString("    <method>" + cdata(result.Method) + "</method>\n")
+	burpItem.WriteString("    <path>" + cdata(path) + "</path>\n")
+	burpItem.WriteString("    <extension>" + escapeXML(extension(path)) + "</extension>\n")
+	burpItem.WriteString("    <request base64=\"true\">" + cdata(base64.StdEncoding.EncodeToString(request)) + "</request>\n")
+	burpItem.WriteString("    <status>" + strconv.Itoa(result.StatusCode) + "</status>\n")
+	burpItem.WriteString("    <responselength>" + strconv.Itoa(len(response)) + "</responselength>\n")
+	burpItem.WriteString("    <mimetype>" + mimeType(result.Response.Headers) + "</mimetype>\n")
+	burpItem.WriteString("    <response base64=\"true\">" + cdata(base64.StdEncoding.EncodeToString(response)) + "</response>\n")
 	burpItem.WriteString("    <comment>" + escapeXML("403 Bypass: "+result.Technique) + "</comment>\n")
-	burpItem.WriteString("    <highlight>green</highlight>\n")
-	burpItem.WriteString("    <tags>\n")
-	burpItem.WriteString("      <tag>403 Bypass</tag>\n")
-	burpItem.WriteString("      <tag>" + escapeXML(result.Technique) + "</tag>\n")
-	burpItem.WriteString("    </tags>\n")
 	burpItem.WriteString("  </item>\n")
 
 	return burpItem.String()
 }
 
-// generateRequest creates an HTTP request string for a bypass result
+// generateRequest returns the exact HTTP request sent for a bypass result
 func generateRequest(result bypass.Result) string {
-	parsedURL := parseURL(result.URL)
+	if result.Request == nil {
+		return ""
+	}
+	return string(result.Request.Bytes())
+}
 
-	var request strings.Builder
+// generateResponse rebuilds the HTTP response recorded for a bypass result
+func generateResponse(result bypass.Result) string {
+	var response strings.Builder
 
-	// Request line
-	path := parsedURL.Path
-	if parsedURL.RawQuery != "" {
-		path += "?" + parsedURL.RawQuery
+	proto := result.Response.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
 	}
-	if path == "" {
-		path = "/"
+	status := result.Response.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", result.StatusCode, getStatusText(result.StatusCode))
 	}
-	request.WriteString(result.Method + " " + path + " HTTP/1.1\r\n")
-
-	// Headers
-	request.WriteString("Host: " + parsedURL.Host + "\r\n")
+	response.WriteString(proto + " " + status + "\r\n")
 
-	// Add technique-specific headers
-	if strings.Contains(result.Technique, "Header") {
-		headerName := strings.TrimPrefix(strings.Split(result.Technique, ":")[1], " ")
-		if headerName == "X-Original-URL" {
-			request.WriteString("X-Original-URL: /\r\n")
-		} else if headerName == "X-Rewrite-URL" {
-			request.WriteString("X-Rewrite-URL: /\r\n")
-		} else if strings.Contains(headerName, "Forwarded-For") {
-			request.WriteString(headerName + ": 127.0.0.1\r\n")
+	names := make([]string, 0, len(result.Response.Headers))
+	for name := range result.Response.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range result.Response.Headers[name] {
+			response.WriteString(name + ": " + value + "\r\n")
 		}
 	}
 
-	// Common headers
-	request.WriteString("User-Agent: Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36\r\n")
-	request.WriteString("Accept: text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8\r\n")
-	request.WriteString("Accept-Language: en-US,en;q=0.5\r\n")
-	request.WriteString("Connection: close\r\n")
-	request.WriteString("\r\n")
+	response.WriteString("\r\n")
+	response.Write(result.Response.BodySample)
 
-	return request.String()
+	return response.String()
 }
 
 // parseURL parses a URL string and returns its components
@@ -160,9 +211,8 @@ func getPort(parsedURL struct {
 	Path     string
 	RawQuery string
 }) string {
-	if strings.Contains(parsedURL.Host, ":") {
-		parts := strings.Split(parsedURL.Host, ":")
-		return parts[1]
+	if _, port, err := net.SplitHostPort(parsedURL.Host); err == nil && port != "" {
+		return port
 	}
 
 	if parsedURL.Scheme == "https" {
@@ -199,6 +249,61 @@ func getStatusText(code int) string {
 	}
 }
 
+// hostname strips the port from a host
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return strings.Trim(h, "[]")
+	}
+	return host
+}
+
+// extension returns the file extension of a request path the way Burp records
+// it, or "null" when there is none
+func extension(path string) string {
+	if idx := strings.IndexAny(path, "?#;"); idx != -1 {
+		path = path[:idx]
+	}
+	segment := path[strings.LastIndex(path, "/")+1:]
+	if idx := strings.LastIndex(segment, "."); idx != -1 && idx < len(segment)-1 {
+		return segment[idx+1:]
+	}
+	return "null"
+}
+
+// mimeType maps the response Content-Type to Burp's MIME type names
+func mimeType(headers map[string][]string) string {
+	contentType := ""
+	if values := headers["Content-Type"]; len(values) > 0 {
+		contentType = strings.ToLower(values[0])
+	}
+
+	switch {
+	case contentType == "":
+		return ""
+	case strings.Contains(contentType, "html"):
+		return "HTML"
+	case strings.Contains(contentType, "json"):
+		return "JSON"
+	case strings.Contains(contentType, "xml"):
+		return "XML"
+	case strings.Contains(contentType, "javascript"):
+		return "script"
+	case strings.Contains(contentType, "css"):
+		return "CSS"
+	case strings.HasPrefix(contentType, "image/"):
+		return "image"
+	case strings.HasPrefix(contentType, "text/"):
+		return "text"
+	default:
+		return "app"
+	}
+}
+
+// cdata wraps a string in a CDATA section, splitting any "]]>" it contains
+func cdata(s string) string {
+	return "<![CDATA[" + strings.ReplaceAll(s, "]]>", "]]]]><![CDATA[>") + "]]>"
+}
+
 // escapeXML escapes special characters in XML
 func escapeXML(s string) string {
 	s = strings.ReplaceAll(s, "&", "&amp;")
@@ -0,0 +1,123 @@
+package output
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"os"
+	"testing"
+)
+
+// burpItems is the part of Burp's "Save items" XML the tests read back
+type burpItems struct {
+	XMLName xml.Name `xml:"items"`
+	Items   []struct {
+		URL  string `xml:"url"`
+		Host struct {
+			IP   string `xml:"ip,attr"`
+			Name string `xml:",chardata"`
+		} `xml:"host"`
+		Port      string `xml:"port"`
+		Protocol  string `xml:"protocol"`
+		Method    string `xml:"method"`
+		Path      string `xml:"path"`
+		Extension string `xml:"extension"`
+		Request   string `xml:"request"`
+		Status    int    `xml:"status"`
+		Length    int    `xml:"responselength"`
+		MimeType  string `xml:"mimetype"`
+		Response  string `xml:"response"`
+		Comment   string `xml:"comment"`
+	} `xml:"item"`
+}
+
+// readBurpItems parses a Burp items file written by GenerateBurpItems
+func readBurpItems(t *testing.T, filename string) burpItems {
+	t.Helper()
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items burpItems
+	if err := xml.Unmarshal(data, &items); err != nil {
+		t.Fatalf("Burp items are not valid XML: %s\n%s", err, data)
+	}
+	return items
+}
+
+func TestGenerateBurpItems(t *testing.T) {
+	results := testResults()
+	filename := tempFile(t, "items.xml")
+	if err := GenerateBurpItems(results, filename); err != nil {
+		t.Fatal(err)
+	}
+
+	items := readBurpItems(t, filename)
+	if len(items.Items) != 1 {
+		t.Fatalf("got %d items, want only the bypass", len(items.Items))
+	}
+	item := items.Items[0]
+
+	checks := []struct{ name, got, want string }{
+		{"url", item.URL, "https://example.com/admin%2f?debug=1"},
+		{"host", item.Host.Name, "example.com"},
+		{"host ip", item.Host.IP, "93.184.216.34"},
+		{"port", item.Port, "443"},
+		{"protocol", item.Protocol, "https"},
+		{"method", item.Method, "GET"},
+		{"path", item.Path, "/admin%2f?debug=1"},
+		{"extension", item.Extension, "null"},
+		{"mimetype", item.MimeType, "HTML"},
+		{"comment", item.Comment, "403 Bypass: Path: %2f"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %q, want %q", c.name, c.got, c.want)
+		}
+	}
+	if item.Status != 200 {
+		t.Errorf("status = %d, want 200", item.Status)
+	}
+
+	request, err := base64.StdEncoding.DecodeString(item.Request)
+	if err != nil {
+		t.Fatalf("request is not base64: %s", err)
+	}
+	if want := string(results[0].Request.Bytes()); string(request) != want {
+		t.Errorf("request = %q, want the bytes sent %q", request, want)
+	}
+
+	response, err := base64.StdEncoding.DecodeString(item.Response)
+	if err != nil {
+		t.Fatalf("response is not base64: %s", err)
+	}
+	if string(response) != string(results[0].Response.Raw) {
+		t.Errorf("response = %q, want the bytes read %q", response, results[0].Response.Raw)
+	}
+	if item.Length != len(response) {
+		t.Errorf("responselength = %d, want %d", item.Length, len(response))
+	}
+}
+
+func TestGenerateBurpItemsEmpty(t *testing.T) {
+	filename := tempFile(t, "items.xml")
+	if err := GenerateBurpItems(nil, filename); err != nil {
+		t.Fatal(err)
+	}
+
+	if items := readBurpItems(t, filename); len(items.Items) != 0 {
+		t.Errorf("got %d items from a scan without bypasses", len(items.Items))
+	}
+}
+
+func TestCDATA(t *testing.T) {
+	var v struct {
+		Text string `xml:"url"`
+	}
+	want := "/a]]>b]]>"
+	if err := xml.Unmarshal([]byte("<item><url>"+cdata(want)+"</url></item>"), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Text != want {
+		t.Errorf("cdata round trip = %q, want %q", v.Text, want)
+	}
+}
@@ -0,0 +1,340 @@
+package output
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ibrahimsql/bypass403/pkg/bypass"
+)
+
+// The HAR types below follow the HTTP Archive 1.2 specification. Fields
+// starting with an underscore are custom fields, which the specification allows.
+
+type harFile struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Pages   []harPage  `json:"pages"`
+	Entries []harEntry `json:"entries"`
+	Comment string     `json:"comment,omitempty"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harPage struct {
+	StartedDateTime string         `json:"startedDateTime"`
+	ID              string         `json:"id"`
+	Title           string         `json:"title"`
+	PageTimings     harPageTimings `json:"pageTimings"`
+}
+
+type harPageTimings struct {
+	OnContentLoad float64 `json:"onContentLoad"`
+	OnLoad        float64 `json:"onLoad"`
+}
+
+type harEntry struct {
+	Pageref         string      `json:"pageref"`
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+
+	Technique      string `json:"_technique"`
+	Category       string `json:"_category,omitempty"`
+	Classification string `json:"_classification"`
+	Confidence     int    `json:"_confidence"`
+	Bypass         bool   `json:"_bypass"`
+	Throttled      bool   `json:"_throttled,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+	// RequestTarget is the request-target exactly as sent, which may not be
+	// recoverable from the URL
+	RequestTarget string `json:"_requestTarget"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type harTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// GenerateHAR writes the attempts of a scan as a HAR 1.2 archive, for browser
+// devtools, OWASP ZAP and other HAR viewers, with one page per target. With
+// bypassesOnly set, only the attempts accepted as bypasses are included.
+func GenerateHAR(scan Scan, results []bypass.Result, filename string, bypassesOnly bool) error {
+	har := harFile{Log: harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: scan.Tool, Version: scan.Version},
+		Pages:   []harPage{},
+		Entries: []harEntry{},
+	}}
+	if bypassesOnly {
+		har.Log.Comment = "Confirmed bypasses only"
+	}
+
+	for i, group := range scan.Groups(results) {
+		page := fmt.Sprintf("target_%d", i+1)
+		har.Log.Pages = append(har.Log.Pages, harPage{
+			StartedDateTime: harTime(scan.Started),
+			ID:              page,
+			Title:           group.Target,
+			PageTimings:     harPageTimings{OnContentLoad: -1, OnLoad: -1},
+		})
+		for _, result := range group.Results {
+			if bypassesOnly && !result.IsBypass() {
+				continue
+			}
+			har.Log.Entries = append(har.Log.Entries, newHAREntry(result, page, scan.Started))
+		}
+	}
+
+	// Viewers expect entries in the order they were sent
+	sort.SliceStable(har.Log.Entries, func(i, j int) bool {
+		return har.Log.Entries[i].StartedDateTime < har.Log.Entries[j].StartedDateTime
+	})
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creating HAR file: %s", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(har); err != nil {
+		return fmt.Errorf("error writing HAR file: %s", err)
+	}
+
+	return nil
+}
+
+// newHAREntry converts an attempt into a HAR entry
+func newHAREntry(result bypass.Result, page string, scanStarted time.Time) harEntry {
+	evidence := result.Response
+
+	started := evidence.Started
+	if started.IsZero() {
+		// No response was recorded, e.g. the request stayed throttled
+		started = scanStarted
+	}
+
+	entry := harEntry{
+		Pageref:         page,
+		StartedDateTime: harTime(started),
+		Time:            milliseconds(evidence.Time),
+		Request:         newHARRequest(result),
+		Response:        newHARResponse(result),
+		Timings: harTimings{
+			Blocked: -1,
+			DNS:     -1,
+			Connect: milliseconds(evidence.Timing.Connect + evidence.Timing.TLS),
+			Send:    milliseconds(evidence.Timing.Send),
+			Wait:    milliseconds(evidence.Timing.Wait),
+			Receive: milliseconds(evidence.Timing.Receive),
+			SSL:     -1,
+		},
+		Technique:      result.Technique,
+		Category:       result.Category,
+		Classification: result.Classification,
+		Confidence:     result.Confidence,
+		Bypass:         result.IsBypass(),
+		Throttled:      result.Throttled,
+	}
+
+	if evidence.Timing.TLS > 0 {
+		entry.Timings.SSL = milliseconds(evidence.Timing.TLS)
+	}
+	if idx := strings.LastIndex(evidence.RemoteAddr, ":"); idx != -1 {
+		entry.ServerIPAddress = strings.Trim(evidence.RemoteAddr[:idx], "[]")
+	}
+
+	return entry
+}
+
+// newHARRequest records the request of an attempt
+func newHARRequest(result bypass.Result) harRequest {
+	request := harRequest{
+		Method:        result.Method,
+		URL:           result.URL,
+		HTTPVersion:   "HTTP/1.1",
+		Cookies:       []harNameValue{},
+		Headers:       []harNameValue{},
+		QueryString:   []harNameValue{},
+		HeadersSize:   -1,
+		BodySize:      0,
+		RequestTarget: result.URL,
+	}
+
+	req := result.Request
+	if req == nil {
+		return request
+	}
+
+	request.HTTPVersion = req.Proto
+	request.RequestTarget = req.Target
+	for _, h := range req.Headers {
+		request.Headers = append(request.Headers, harNameValue{h.Name, h.Value})
+		if strings.EqualFold(h.Name, "Cookie") {
+			request.Cookies = append(request.Cookies, parseCookies(h.Value)...)
+		}
+	}
+
+	// Query parameters are listed as sent, without decoding
+	if idx := strings.Index(req.Target, "?"); idx != -1 {
+		for _, pair := range strings.Split(req.Target[idx+1:], "&") {
+			name, value, _ := strings.Cut(pair, "=")
+			request.QueryString = append(request.QueryString, harNameValue{name, value})
+		}
+	}
+
+	raw := req.Bytes()
+	request.HeadersSize = len(raw) - len(req.Body)
+	request.BodySize = len(req.Body)
+	if len(req.Body) > 0 {
+		request.PostData = &harPostData{MimeType: req.Header("Content-Type"), Text: string(req.Body)}
+	}
+
+	return request
+}
+
+// newHARResponse records the response evidence of an attempt
+func newHARResponse(result bypass.Result) harResponse {
+	evidence := result.Response
+
+	response := harResponse{
+		Status:      result.StatusCode,
+		HTTPVersion: evidence.Proto,
+		Cookies:     []harNameValue{},
+		Headers:     []harNameValue{},
+		RedirectURL: evidence.Location,
+		HeadersSize: -1,
+		BodySize:    result.ContentLength,
+		Content: harContent{
+			Size:     result.ContentLength,
+			MimeType: firstValue(evidence.Headers, "Content-Type"),
+		},
+	}
+
+	if _, text, ok := strings.Cut(evidence.Status, " "); ok {
+		response.StatusText = text
+	}
+
+	names := make([]string, 0, len(evidence.Headers))
+	for name := range evidence.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range evidence.Headers[name] {
+			response.Headers = append(response.Headers, harNameValue{name, value})
+			if name == "Set-Cookie" {
+				cookie, _, _ := strings.Cut(value, ";")
+				response.Cookies = append(response.Cookies, parseCookies(cookie)...)
+			}
+		}
+	}
+
+	// Only a sample of the body is kept as evidence
+	body := evidence.BodySample
+	if utf8.Valid(body) {
+		response.Content.Text = string(body)
+	} else {
+		response.Content.Text = base64.StdEncoding.EncodeToString(body)
+		response.Content.Encoding = "base64"
+	}
+	if evidence.Truncated {
+		response.Content.Comment = fmt.Sprintf("Body truncated to the first %d bytes", len(body))
+	}
+
+	return response
+}
+
+// parseCookies splits a Cookie header into name/value pairs
+func parseCookies(header string) []harNameValue {
+	var cookies []harNameValue
+	for _, part := range strings.Split(header, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			cookies = append(cookies, harNameValue{name, value})
+		}
+	}
+	return cookies
+}
+
+// firstValue returns the first value of a header, or an empty string
+func firstValue(headers map[string][]string, name string) string {
+	if values := headers[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// harTime formats a time the way HAR expects, ISO 8601 with milliseconds
+func harTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z07:00")
+}
+
+// milliseconds converts a duration to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,121 @@
+package output
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/ibrahimsql/bypass403/pkg/bypass"
+)
+
+// readHAR parses a HAR archive written by GenerateHAR
+func readHAR(t *testing.T, filename string) harLog {
+	t.Helper()
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		t.Fatalf("HAR archive is not valid JSON: %s", err)
+	}
+	if har.Log.Version != "1.2" || har.Log.Creator.Name != "bypass403" {
+		t.Errorf("log = version %q by %q, want 1.2 by bypass403", har.Log.Version, har.Log.Creator.Name)
+	}
+	return har.Log
+}
+
+func TestGenerateHAR(t *testing.T) {
+	results := testResults()
+	// Recorded out of order, as concurrent workers deliver them
+	results[0], results[1] = results[1], results[0]
+	filename := tempFile(t, "scan.har")
+	if err := GenerateHAR(testScan(), results, filename, false); err != nil {
+		t.Fatal(err)
+	}
+	log := readHAR(t, filename)
+
+	if len(log.Pages) != 1 || log.Pages[0].ID != "target_1" || log.Pages[0].Title != testTarget {
+		t.Fatalf("pages = %+v, want one page for the target", log.Pages)
+	}
+	if len(log.Entries) != 2 {
+		t.Fatalf("got %d entries, want every attempt", len(log.Entries))
+	}
+	if log.Entries[0].StartedDateTime != "2024-05-01T12:00:01.000Z" || log.Entries[1].StartedDateTime != "2024-05-01T12:00:02.000Z" {
+		t.Errorf("entries start at %s and %s, want them in the order they were sent",
+			log.Entries[0].StartedDateTime, log.Entries[1].StartedDateTime)
+	}
+
+	entry := log.Entries[0]
+	if entry.Pageref != "target_1" || !entry.Bypass || entry.Technique != "Path: %2f" || entry.Time != 12 ||
+		entry.ServerIPAddress != "93.184.216.34" {
+		t.Errorf("entry = %+v, want the bypass", entry)
+	}
+
+	request := entry.Request
+	raw := results[1].Request.Bytes()
+	if request.Method != "GET" || request.URL != "https://example.com/admin%2f?debug=1" || request.HTTPVersion != "HTTP/1.1" ||
+		request.RequestTarget != "/admin%2f?debug=1" || request.HeadersSize != len(raw) || request.BodySize != 0 {
+		t.Errorf("request = %+v, want the request sent", request)
+	}
+	if want := []harNameValue{{"session", "abc"}, {"theme", "dark"}}; !reflect.DeepEqual(request.Cookies, want) {
+		t.Errorf("cookies = %v, want %v", request.Cookies, want)
+	}
+	if want := []harNameValue{{"debug", "1"}}; !reflect.DeepEqual(request.QueryString, want) {
+		t.Errorf("queryString = %v, want %v", request.QueryString, want)
+	}
+	if len(request.Headers) != len(results[1].Request.Headers) {
+		t.Errorf("request has %d headers, want %d", len(request.Headers), len(results[1].Request.Headers))
+	}
+
+	response := entry.Response
+	if response.Status != 200 || response.StatusText != "OK" || response.HTTPVersion != "HTTP/1.1" ||
+		response.Content.MimeType != "text/html; charset=utf-8" || response.Content.Text != string(results[1].Response.BodySample) {
+		t.Errorf("response = %+v, want the 200 with its body", response)
+	}
+}
+
+func TestGenerateHARBypassesOnly(t *testing.T) {
+	filename := tempFile(t, "scan.har")
+	if err := GenerateHAR(testScan(), testResults(), filename, true); err != nil {
+		t.Fatal(err)
+	}
+	log := readHAR(t, filename)
+
+	if len(log.Entries) != 1 || !log.Entries[0].Bypass {
+		t.Errorf("got %d entries, want only the bypass", len(log.Entries))
+	}
+	if log.Comment == "" {
+		t.Errorf("log doesn't say it only holds bypasses")
+	}
+}
+
+func TestHARBinaryBody(t *testing.T) {
+	result := testResults()[0]
+	result.Response.BodySample = []byte{0x89, 'P', 'N', 'G', 0xff, 0x00}
+	result.Response.Truncated = true
+
+	content := newHARResponse(result).Content
+	if content.Encoding != "base64" {
+		t.Fatalf("encoding = %q, want base64 for a binary body", content.Encoding)
+	}
+	body, err := base64.StdEncoding.DecodeString(content.Text)
+	if err != nil || string(body) != string(result.Response.BodySample) {
+		t.Errorf("text = %q, want the body base64 encoded", content.Text)
+	}
+	if content.Comment == "" {
+		t.Errorf("truncated body has no comment")
+	}
+}
+
+func TestHARThrottledEntry(t *testing.T) {
+	result := testResults()[1]
+	result.Response = bypass.ResponseEvidence{}
+	result.Throttled = true
+	entry := newHAREntry(result, "target_1", testStarted)
+	if entry.StartedDateTime != "2024-05-01T12:00:00.000Z" || !entry.Throttled {
+		t.Errorf("entry = %+v, want the scan start and the throttled flag", entry)
+	}
+}
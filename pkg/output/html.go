@@ -0,0 +1,265 @@
+package output
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ibrahimsql/bypass403/pkg/bypass"
+	"github.com/ibrahimsql/bypass403/pkg/snippet"
+)
+
+//go:embed templates/report.html
+var htmlTemplate string
+
+// htmlReport is the data rendered into the HTML report
+type htmlReport struct {
+	Scan      Scan
+	Duration  time.Duration
+	Generated time.Time
+	Attempts  int
+	Classes   []htmlCount
+
+	Findings  int
+	Responses int
+	Targets   []htmlTarget
+}
+
+// htmlTarget is the section of the report for one scanned target
+type htmlTarget struct {
+	URL      string
+	Baseline *bypass.Baseline
+	RawBlock string
+
+	Findings   int
+	Categories []htmlCategory
+	Clusters   []htmlCluster
+}
+
+// htmlCount is the number of attempts with a given classification
+type htmlCount struct {
+	Name  string
+	Count int
+}
+
+// htmlCategory groups the findings of one technique category
+type htmlCategory struct {
+	Name        string
+	Remediation string
+	Findings    []htmlFinding
+}
+
+// htmlCluster groups findings that received the same response
+type htmlCluster struct {
+	ID         string
+	StatusCode int
+	Length     int
+	Title      string
+	Techniques []string
+	// More is the number of further techniques not listed
+	More  int
+	Count int
+}
+
+// htmlFinding is a single confirmed bypass
+type htmlFinding struct {
+	bypass.Result
+	ID          string
+	Cluster     string
+	Comparison  []htmlComparison
+	RawResponse string
+	Snippets    []htmlSnippet
+}
+
+// htmlSnippet is one reproduction tab of a finding
+type htmlSnippet struct {
+	Format string
+	Label  string
+	Code   string
+}
+
+// htmlComparison is one row of the baseline vs. bypass table
+type htmlComparison struct {
+	Field    string
+	Baseline string
+	Bypass   string
+	Changed  bool
+}
+
+// GenerateHTML writes a self-contained HTML report of the scan, with a section
+// per target. Findings are grouped by technique category and by response, and
+// each comes with the comparison against the baseline, reproduction commands
+// and remediation notes.
+func GenerateHTML(scan Scan, results []bypass.Result, formats []string, filename string) error {
+	tmpl, err := template.New("report").Parse(htmlTemplate)
+	if err != nil {
+		return fmt.Errorf("error parsing HTML template: %s", err)
+	}
+
+	report := htmlReport{
+		Scan:      scan,
+		Duration:  scan.Finished.Sub(scan.Started).Round(time.Millisecond),
+		Generated: time.Now(),
+		Attempts:  len(results),
+	}
+
+	classes := make(map[string]int)
+	for _, result := range results {
+		classes[result.Classification]++
+	}
+	for name, count := range classes {
+		if name == "" {
+			name = "unclassified"
+		}
+		report.Classes = append(report.Classes, htmlCount{name, count})
+	}
+	sort.Slice(report.Classes, func(i, j int) bool {
+		return report.Classes[i].Count > report.Classes[j].Count
+	})
+
+	for _, group := range scan.Groups(results) {
+		report.Targets = append(report.Targets, newHTMLTarget(group, formats, &report))
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creating HTML report file: %s", err)
+	}
+	defer file.Close()
+
+	if err := tmpl.Execute(file, report); err != nil {
+		return fmt.Errorf("error writing HTML report: %s", err)
+	}
+
+	return nil
+}
+
+// newHTMLTarget prepares the section of one target. Finding and cluster
+// numbers continue from the targets before it, so they stay unique in the report.
+func newHTMLTarget(group TargetGroup, formats []string, report *htmlReport) htmlTarget {
+	target := htmlTarget{URL: group.Target, Baseline: group.Baseline}
+	baseline := group.Baseline
+	if baseline != nil {
+		target.RawBlock = generateResponse(bypass.Result{StatusCode: baseline.StatusCode, Response: baseline.Response})
+	}
+
+	categories := make(map[string]*htmlCategory)
+	clusters := make(map[string]*htmlCluster)
+	var clusterOrder []string
+
+	for _, result := range group.Results {
+		if !result.IsBypass() {
+			continue
+		}
+		report.Findings++
+		target.Findings++
+
+		// Identical responses point at one underlying weakness reached several ways
+		key := strconv.Itoa(result.StatusCode) + "|" + result.BodyHash
+		cluster, ok := clusters[key]
+		if !ok {
+			report.Responses++
+			cluster = &htmlCluster{
+				ID:         "C" + strconv.Itoa(report.Responses),
+				StatusCode: result.StatusCode,
+				Length:     result.ContentLength,
+				Title:      result.Title,
+			}
+			clusters[key] = cluster
+			clusterOrder = append(clusterOrder, key)
+		}
+		cluster.Count++
+		if !slices.Contains(cluster.Techniques, result.Technique) {
+			if len(cluster.Techniques) < 10 {
+				cluster.Techniques = append(cluster.Techniques, result.Technique)
+			} else {
+				cluster.More++
+			}
+		}
+
+		name := result.Category
+		if name == "" {
+			name = "Uncategorized"
+		}
+		category, ok := categories[name]
+		if !ok {
+			category = &htmlCategory{Name: name, Remediation: RemediationFor(result.Category)}
+			categories[name] = category
+		}
+		category.Findings = append(category.Findings, newHTMLFinding(result, baseline, formats, report.Findings, cluster.ID))
+	}
+
+	for _, category := range categories {
+		sort.SliceStable(category.Findings, func(i, j int) bool {
+			return category.Findings[i].Confidence > category.Findings[j].Confidence
+		})
+		target.Categories = append(target.Categories, *category)
+	}
+	sort.Slice(target.Categories, func(i, j int) bool {
+		return target.Categories[i].Name < target.Categories[j].Name
+	})
+
+	for _, key := range clusterOrder {
+		target.Clusters = append(target.Clusters, *clusters[key])
+	}
+
+	return target
+}
+
+// newHTMLFinding prepares a bypass for display, with its comparison to the baseline
+func newHTMLFinding(result bypass.Result, baseline *bypass.Baseline, formats []string, n int, cluster string) htmlFinding {
+	finding := htmlFinding{
+		Result:      result,
+		ID:          "finding-" + strconv.Itoa(n),
+		Cluster:     cluster,
+		RawResponse: generateResponse(result),
+	}
+
+	req := snippet.ForResult(result)
+	for _, format := range formats {
+		finding.Snippets = append(finding.Snippets, htmlSnippet{format, snippet.Label(format), snippet.Generate(format, req)})
+	}
+
+	if baseline == nil {
+		return finding
+	}
+
+	add := func(field, before, after string) {
+		finding.Comparison = append(finding.Comparison, htmlComparison{field, before, after, before != after})
+	}
+	add("Status", strconv.Itoa(baseline.StatusCode), strconv.Itoa(result.StatusCode))
+	add("Length", strconv.Itoa(baseline.ContentLength), strconv.Itoa(result.ContentLength))
+	add("Words", strconv.Itoa(baseline.Words), strconv.Itoa(result.Words))
+	add("Lines", strconv.Itoa(baseline.Lines), strconv.Itoa(result.Lines))
+	add("Title", baseline.Title, result.Title)
+	add("Body hash", shortHash(baseline.BodyHash), shortHash(result.BodyHash))
+
+	var names []string
+	for name := range baseline.KeyHeaders {
+		names = append(names, name)
+	}
+	for name := range result.KeyHeaders {
+		if _, ok := baseline.KeyHeaders[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		add(name, baseline.KeyHeaders[name], result.KeyHeaders[name])
+	}
+
+	return finding
+}
+
+// shortHash shortens a body hash for display
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
@@ -0,0 +1,79 @@
+package output
+
+import (
+	"html/template"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ibrahimsql/bypass403/pkg/bypass"
+	"github.com/ibrahimsql/bypass403/pkg/snippet"
+)
+
+// generateHTML writes the HTML report of results and returns it
+func generateHTML(t *testing.T, scan Scan, results []bypass.Result, formats []string) string {
+	t.Helper()
+	filename := tempFile(t, "report.html")
+	if err := GenerateHTML(scan, results, formats, filename); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestGenerateHTML(t *testing.T) {
+	results := testResults()
+	results[0].Technique = "Path: <script>alert(1)</script>"
+	formats := []string{snippet.Curl, snippet.Raw}
+	report := generateHTML(t, testScan(), results, formats)
+
+	if n := strings.Count(report, `<details class="finding"`); n != 1 {
+		t.Errorf("report has %d findings, want only the bypass", n)
+	}
+
+	want := []string{
+		`id="finding-1"`,
+		"Path: &lt;script&gt;alert(1)&lt;/script&gt;",
+		`<tr class="changed"><td>Status</td><td>403</td><td class="after">200</td></tr>`,
+		`<tr class="changed"><td>Title</td><td>Error</td><td class="after">Admin</td></tr>`,
+		template.HTMLEscapeString(generateResponse(results[0])),
+		template.HTMLEscapeString(RemediationFor("path")),
+	}
+	for _, format := range formats {
+		want = append(want,
+			`data-pane="`+format+`">`+snippet.Label(format)+`</button>`,
+			template.HTMLEscapeString(snippet.Generate(format, results[0].Request)))
+	}
+	for _, w := range want {
+		if !strings.Contains(report, w) {
+			t.Errorf("report doesn't contain %q", w)
+		}
+	}
+
+	if strings.Contains(report, "<script>alert(1)") {
+		t.Errorf("report contains the technique unescaped")
+	}
+	// The report is self-contained and opens offline
+	for _, external := range []string{`src="http`, `href="http`, "<link"} {
+		if strings.Contains(report, external) {
+			t.Errorf("report loads an external resource: %s", external)
+		}
+	}
+}
+
+func TestGenerateHTMLNoFindings(t *testing.T) {
+	report := generateHTML(t, testScan(), testResults()[1:], snippet.DefaultFormats)
+
+	if strings.Contains(report, `<details class="finding"`) {
+		t.Errorf("report lists a blocked attempt as a finding")
+	}
+	if !strings.Contains(report, "No bypasses were confirmed for this target.") {
+		t.Errorf("report doesn't say the target had no bypasses")
+	}
+	if !strings.Contains(report, testTarget) {
+		t.Errorf("report doesn't name the target")
+	}
+}
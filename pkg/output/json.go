@@ -0,0 +1,281 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ibrahimsql/bypass403/pkg/bypass"
+	"github.com/ibrahimsql/bypass403/pkg/http"
+)
+
+// Scan describes a scan run, recorded in the structured outputs
+type Scan struct {
+	Tool       string    `json:"tool"`
+	Version    string    `json:"version"`
+	Targets    []string  `json:"targets"`
+	Categories []string  `json:"categories"`
+	Started    time.Time `json:"started"`
+	Finished   time.Time `json:"finished"`
+
+	// Baselines are the blocked responses of the targets, and Preflights
+	// what the targets answered before the scan, by target URL
+	Baselines  map[string]*bypass.Baseline  `json:"-"`
+	Preflights map[string]*bypass.Preflight `json:"-"`
+}
+
+// TargetGroup is a scanned target with its preflight, baseline and results
+type TargetGroup struct {
+	Target    string
+	Preflight *bypass.Preflight
+	Baseline  *bypass.Baseline
+	Results   []bypass.Result
+}
+
+// Groups groups results by target, in scan order, so every output presents
+// the targets of a multi-target scan one after another
+func (s Scan) Groups(results []bypass.Result) []TargetGroup {
+	var groups []TargetGroup
+	for _, g := range bypass.GroupByTarget(s.Targets, results) {
+		groups = append(groups, TargetGroup{
+			Target:    g.Target,
+			Preflight: s.Preflights[g.Target],
+			Baseline:  s.Baselines[g.Target],
+			Results:   g.Results,
+		})
+	}
+	return groups
+}
+
+// Title names the scan after its target, or the number of targets
+func (s Scan) Title() string {
+	if len(s.Targets) == 1 {
+		return s.Targets[0]
+	}
+	return fmt.Sprintf("%d targets", len(s.Targets))
+}
+
+// JSONReport is the document written by -json
+type JSONReport struct {
+	Scan    JSONScan     `json:"scan"`
+	Targets []JSONTarget `json:"targets"`
+}
+
+// JSONTarget is a scanned target with its preflight, baseline and attempts
+type JSONTarget struct {
+	URL       string            `json:"url"`
+	Preflight *bypass.Preflight `json:"preflight,omitempty"`
+	Baseline  *JSONBaseline     `json:"baseline,omitempty"`
+	Results   []JSONAttempt     `json:"results"`
+}
+
+// JSONScan is the scan metadata of a JSON report, with totals
+type JSONScan struct {
+	Scan
+	DurationMs int64 `json:"duration_ms"`
+	Attempts   int   `json:"attempts"`
+	Bypasses   int   `json:"bypasses"`
+	Throttled  int   `json:"throttled"`
+}
+
+// JSONBaseline is the blocked response every attempt was compared with
+type JSONBaseline struct {
+	StatusCode    int                   `json:"status_code"`
+	ContentLength int                   `json:"content_length"`
+	Words         int                   `json:"words"`
+	Lines         int                   `json:"lines"`
+	Title         string                `json:"title,omitempty"`
+	BodyHash      string                `json:"body_hash"`
+	Dynamic       bool                  `json:"dynamic"`
+	Signature     bypass.BlockSignature `json:"signature"`
+	Request       *JSONRequest          `json:"request,omitempty"`
+	Response      JSONResponse          `json:"response"`
+}
+
+// JSONAttempt is a single attempt, as written in a JSON report and as one line of -jsonl
+type JSONAttempt struct {
+	Target         string       `json:"target,omitempty"`
+	URL            string       `json:"url"`
+	Method         string       `json:"method"`
+	Technique      string       `json:"technique"`
+	Category       string       `json:"category,omitempty"`
+	StatusCode     int          `json:"status_code"`
+	ContentLength  int          `json:"content_length"`
+	Words          int          `json:"words"`
+	Lines          int          `json:"lines"`
+	Title          string       `json:"title,omitempty"`
+	BodyHash       string       `json:"body_hash,omitempty"`
+	Classification string       `json:"classification"`
+	Confidence     int          `json:"confidence"`
+	Bypass         bool         `json:"bypass"`
+	Throttled      bool         `json:"throttled,omitempty"`
+	Request        *JSONRequest `json:"request,omitempty"`
+	Response       JSONResponse `json:"response"`
+}
+
+// JSONRequest is the exact request sent
+type JSONRequest struct {
+	Origin string `json:"origin"`
+	Target string `json:"target"`
+	Raw    string `json:"raw"`
+}
+
+// JSONResponse is the response evidence recorded for an attempt
+type JSONResponse struct {
+	Proto      string              `json:"proto,omitempty"`
+	Status     string              `json:"status,omitempty"`
+	Headers    map[string][]string `json:"headers,omitempty"`
+	BodySample string              `json:"body_sample"`
+	Truncated  bool                `json:"truncated"`
+	TimeMs     int64               `json:"time_ms"`
+	Location   string              `json:"location,omitempty"`
+}
+
+// NewJSONAttempt converts a result into its JSON form
+func NewJSONAttempt(result bypass.Result) JSONAttempt {
+	return JSONAttempt{
+		Target:         result.Target,
+		URL:            result.URL,
+		Method:         result.Method,
+		Technique:      result.Technique,
+		Category:       result.Category,
+		StatusCode:     result.StatusCode,
+		ContentLength:  result.ContentLength,
+		Words:          result.Words,
+		Lines:          result.Lines,
+		Title:          result.Title,
+		BodyHash:       result.BodyHash,
+		Classification: result.Classification,
+		Confidence:     result.Confidence,
+		Bypass:         result.IsBypass(),
+		Throttled:      result.Throttled,
+		Request:        newJSONRequest(result.Request),
+		Response:       newJSONResponse(result.Response),
+	}
+}
+
+// GenerateJSON writes a single JSON document with the scan metadata and,
+// for each target, its baseline and every attempt
+func GenerateJSON(scan Scan, results []bypass.Result, filename string) error {
+	report := JSONReport{
+		Scan: JSONScan{
+			Scan:       scan,
+			DurationMs: scan.Finished.Sub(scan.Started).Milliseconds(),
+			Attempts:   len(results),
+		},
+		Targets: []JSONTarget{},
+	}
+
+	for _, group := range scan.Groups(results) {
+		target := JSONTarget{
+			URL:       group.Target,
+			Preflight: group.Preflight,
+			Baseline:  newJSONBaseline(group.Baseline),
+			Results:   make([]JSONAttempt, 0, len(group.Results)),
+		}
+		for _, result := range group.Results {
+			if result.IsBypass() {
+				report.Scan.Bypasses++
+			}
+			if result.Throttled {
+				report.Scan.Throttled++
+			}
+			target.Results = append(target.Results, NewJSONAttempt(result))
+		}
+		report.Targets = append(report.Targets, target)
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creating JSON output file: %s", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("error writing JSON output: %s", err)
+	}
+
+	return nil
+}
+
+// JSONLWriter streams attempts to a file as JSON Lines, one attempt per line.
+// It is safe for concurrent use.
+type JSONLWriter struct {
+	mu      sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// NewJSONLWriter creates the JSON Lines output file
+func NewJSONLWriter(filename string) (*JSONLWriter, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error creating JSON Lines output file: %s", err)
+	}
+
+	return &JSONLWriter{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+// Write appends one attempt as a line of JSON
+func (w *JSONLWriter) Write(result bypass.Result) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.encoder.Encode(NewJSONAttempt(result)); err != nil {
+		return fmt.Errorf("error writing JSON Lines output: %s", err)
+	}
+	return nil
+}
+
+// Close closes the output file
+func (w *JSONLWriter) Close() error {
+	return w.file.Close()
+}
+
+// newJSONBaseline converts a baseline, if one was captured
+func newJSONBaseline(baseline *bypass.Baseline) *JSONBaseline {
+	if baseline == nil {
+		return nil
+	}
+	return &JSONBaseline{
+		StatusCode:    baseline.StatusCode,
+		ContentLength: baseline.ContentLength,
+		Words:         baseline.Words,
+		Lines:         baseline.Lines,
+		Title:         baseline.Title,
+		BodyHash:      baseline.BodyHash,
+		Dynamic:       baseline.Dynamic,
+		Signature:     baseline.Signature,
+		Request:       newJSONRequest(baseline.Request),
+		Response:      newJSONResponse(baseline.Response),
+	}
+}
+
+// newJSONRequest converts a raw request, if one was recorded
+func newJSONRequest(req *http.Request) *JSONRequest {
+	if req == nil {
+		return nil
+	}
+	return &JSONRequest{
+		Origin: req.Origin,
+		Target: req.Target,
+		Raw:    string(req.Bytes()),
+	}
+}
+
+// newJSONResponse converts the response evidence
+func newJSONResponse(evidence bypass.ResponseEvidence) JSONResponse {
+	return JSONResponse{
+		Proto:      evidence.Proto,
+		Status:     evidence.Status,
+		Headers:    evidence.Headers,
+		BodySample: string(evidence.BodySample),
+		Truncated:  evidence.Truncated,
+		TimeMs:     evidence.Time.Milliseconds(),
+		Location:   evidence.Location,
+	}
+}
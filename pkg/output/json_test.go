@@ -0,0 +1,129 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestGenerateJSON(t *testing.T) {
+	scan := testScan()
+	results := testResults()
+	filename := tempFile(t, "results.json")
+	if err := GenerateJSON(scan, results, filename); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report JSONReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("JSON report doesn't parse back: %s", err)
+	}
+
+	if report.Scan.Tool != "bypass403" || !report.Scan.Started.Equal(scan.Started) {
+		t.Errorf("scan = %+v, want the scan metadata", report.Scan.Scan)
+	}
+	if report.Scan.DurationMs != 3000 || report.Scan.Attempts != 2 || report.Scan.Bypasses != 1 || report.Scan.Throttled != 0 {
+		t.Errorf("scan counts = %d ms, %d attempts, %d bypasses, %d throttled, want 3000 ms, 2, 1, 0",
+			report.Scan.DurationMs, report.Scan.Attempts, report.Scan.Bypasses, report.Scan.Throttled)
+	}
+
+	if len(report.Targets) != 1 {
+		t.Fatalf("got %d targets, want 1", len(report.Targets))
+	}
+	target := report.Targets[0]
+	if target.URL != testTarget {
+		t.Errorf("target = %q, want %q", target.URL, testTarget)
+	}
+	if target.Baseline == nil || target.Baseline.StatusCode != 403 || target.Baseline.Signature.Phrase != "access denied" {
+		t.Errorf("baseline = %+v, want the 403 with its signature", target.Baseline)
+	}
+	if len(target.Results) != 2 {
+		t.Fatalf("got %d results, want every attempt", len(target.Results))
+	}
+
+	got := target.Results[0]
+	want := NewJSONAttempt(results[0])
+	if got.URL != want.URL || got.Technique != want.Technique || got.StatusCode != 200 || !got.Bypass || got.Confidence != 90 {
+		t.Errorf("bypass = %+v, want %+v", got, want)
+	}
+	if got.Request == nil || got.Request.Raw != string(results[0].Request.Bytes()) {
+		t.Errorf("request = %+v, want the exact bytes sent", got.Request)
+	}
+	if got.Response.BodySample != string(results[0].Response.BodySample) || got.Response.TimeMs != 12 {
+		t.Errorf("response = %+v, want the recorded evidence", got.Response)
+	}
+	if target.Results[1].Bypass {
+		t.Errorf("blocked attempt reported as a bypass")
+	}
+}
+
+func TestGenerateJSONEmpty(t *testing.T) {
+	filename := tempFile(t, "results.json")
+	if err := GenerateJSON(Scan{}, nil, filename); err != nil {
+		t.Fatal(err)
+	}
+
+	var report map[string]interface{}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if targets, ok := report["targets"].([]interface{}); !ok || len(targets) != 0 {
+		t.Errorf("targets = %v, want an empty list rather than null", report["targets"])
+	}
+}
+
+func TestJSONLWriter(t *testing.T) {
+	results := testResults()
+	filename := tempFile(t, "attempts.jsonl")
+	w, err := NewJSONLWriter(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		if err := w.Write(result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var lines []JSONAttempt
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var attempt JSONAttempt
+		if err := json.Unmarshal(scanner.Bytes(), &attempt); err != nil {
+			t.Fatalf("line %d doesn't parse: %s", len(lines)+1, err)
+		}
+		lines = append(lines, attempt)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lines) != len(results) {
+		t.Fatalf("got %d lines, want one per attempt", len(lines))
+	}
+	for i, line := range lines {
+		want := NewJSONAttempt(results[i])
+		if line.Target != want.Target || line.URL != want.URL || line.Technique != want.Technique ||
+			line.StatusCode != want.StatusCode || line.Bypass != want.Bypass {
+			t.Errorf("line %d = %+v, want %+v", i+1, line, want)
+		}
+	}
+}
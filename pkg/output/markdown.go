@@ -0,0 +1,257 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ibrahimsql/bypass403/pkg/bypass"
+	"github.com/ibrahimsql/bypass403/pkg/snippet"
+)
+
+// excerptSize is the number of response body bytes quoted in the report
+const excerptSize = 800
+
+// GenerateMarkdown writes a bug-bounty style Markdown write-up of the confirmed
+// bypasses: for each target the blocked baseline, then one section per
+// technique category with impact, reproduction steps, a response excerpt and
+// remediation guidance
+func GenerateMarkdown(scan Scan, results []bypass.Result, formats []string, filename string) error {
+	groups := scan.Groups(results)
+	var all []string
+	seen := make(map[string]bool)
+	for _, group := range groups {
+		for _, name := range bypassCategories(group.Results) {
+			if !seen[name] {
+				seen[name] = true
+				all = append(all, name)
+			}
+		}
+	}
+	sort.Strings(all)
+
+	var md strings.Builder
+
+	md.WriteString("# 403 bypass on " + scan.Title() + "\n\n")
+	md.WriteString("| | |\n|---|---|\n")
+	for _, target := range scan.Targets {
+		md.WriteString("| Target | `" + target + "` |\n")
+	}
+	md.WriteString("| Tested | " + scan.Started.UTC().Format(time.RFC1123) + " |\n")
+	md.WriteString("| Tool | " + scan.Tool + " " + scan.Version + " |\n")
+	md.WriteString("| Bypassed by | " + strings.Join(all, ", ") + " |\n")
+
+	// A single target keeps its sections at the top level; several targets
+	// get a section each, with theirs nested one level down
+	level := "##"
+	if len(groups) > 1 {
+		level = "###"
+	}
+	for _, group := range groups {
+		if len(groups) > 1 {
+			md.WriteString("\n## " + group.Target + "\n")
+		}
+		writeMarkdownTarget(&md, group, formats, level)
+	}
+
+	md.WriteString("\n---\n\n_Generated by " + scan.Tool + " " + scan.Version +
+		". Verify each finding manually before reporting it._\n")
+
+	if err := os.WriteFile(filename, []byte(md.String()), 0644); err != nil {
+		return fmt.Errorf("error writing Markdown report: %s", err)
+	}
+	return nil
+}
+
+// bypassCategories returns the sorted categories of the confirmed bypasses
+func bypassCategories(results []bypass.Result) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, result := range results {
+		if !result.IsBypass() {
+			continue
+		}
+		name := result.Category
+		if name == "" {
+			name = "Uncategorized"
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeMarkdownTarget writes the summary, baseline and category sections of
+// one target, with headings at the given level
+func writeMarkdownTarget(md *strings.Builder, group TargetGroup, formats []string, level string) {
+	categories := make(map[string][]bypass.Result)
+	for _, result := range group.Results {
+		if !result.IsBypass() {
+			continue
+		}
+		name := result.Category
+		if name == "" {
+			name = "Uncategorized"
+		}
+		categories[name] = append(categories[name], result)
+	}
+	names := bypassCategories(group.Results)
+
+	md.WriteString("\n" + level + " Summary\n\n")
+	if len(names) == 0 {
+		md.WriteString("No bypass of the access control was confirmed.\n")
+	} else {
+		total := 0
+		for _, name := range names {
+			total += len(categories[name])
+		}
+		noun := "categories"
+		if len(names) == 1 {
+			noun = "category"
+		}
+		md.WriteString(fmt.Sprintf("Access to `%s` is denied for a normal request, but %d request variant(s) "+
+			"from %d technique %s reached the resource anyway. The access control can therefore be "+
+			"bypassed without credentials.\n", group.Target, total, len(names), noun))
+	}
+
+	baseline := group.Baseline
+	if baseline != nil {
+		md.WriteString("\n" + level + " Blocked baseline\n\n")
+		md.WriteString(fmt.Sprintf("The unmodified request is blocked with **%d** (%d bytes", baseline.StatusCode, baseline.ContentLength))
+		if baseline.Title != "" {
+			md.WriteString(", title \"" + baseline.Title + "\"")
+		}
+		md.WriteString(fmt.Sprintf("). Responses matching the block signature, %s, count as blocked:\n\n", baseline.Signature))
+		if baseline.Request != nil {
+			md.WriteString(fence(string(baseline.Request.Bytes()), "http"))
+		}
+		md.WriteString(fence(responseExcerpt(bypass.Result{StatusCode: baseline.StatusCode, Response: baseline.Response}), "http"))
+	}
+
+	for _, name := range names {
+		findings := categories[name]
+		sort.SliceStable(findings, func(i, j int) bool {
+			return findings[i].Confidence > findings[j].Confidence
+		})
+		writeMarkdownCategory(md, name, findings, baseline, formats, group.Target, level)
+	}
+}
+
+// writeMarkdownCategory writes the section for one technique category. The most
+// convincing finding is written up in full; the others are listed in a table.
+func writeMarkdownCategory(md *strings.Builder, name string, findings []bypass.Result, baseline *bypass.Baseline, formats []string, target, level string) {
+	best := findings[0]
+	category := best.Category
+
+	md.WriteString("\n" + level + " " + name + "\n\n")
+	md.WriteString("**Severity:** " + severityLabel(best) + "  \n")
+	md.WriteString("**Technique:** " + best.Technique + "  \n")
+	md.WriteString("**Confidence:** " + strconv.Itoa(best.Confidence) + "%\n\n")
+
+	md.WriteString(level + "# Impact\n\n" + ImpactFor(category) + "\n\n")
+
+	md.WriteString(level + "# Steps to reproduce\n\n")
+	if baseline != nil {
+		md.WriteString(fmt.Sprintf("1. Request `%s` normally and observe that it is blocked with %d.\n",
+			target, baseline.StatusCode))
+	}
+	md.WriteString("1. Send the following request exactly as written:\n\n")
+	req := snippet.ForResult(best)
+	md.WriteString(indent(fence(snippet.Generate(snippet.Raw, req), "http"), "   "))
+	for _, format := range formats {
+		if format == snippet.Raw {
+			continue
+		}
+		md.WriteString("   or with " + snippet.Label(format) + ":\n\n")
+		md.WriteString(indent(fence(snippet.Generate(format, req), snippet.Language(format)), "   "))
+	}
+	md.WriteString(fmt.Sprintf("1. Observe that the server answers **%d** instead of the blocked response:\n\n", best.StatusCode))
+	md.WriteString(indent(fence(responseExcerpt(best), "http"), "   "))
+
+	if baseline != nil {
+		md.WriteString(level + "# Baseline vs. bypass\n\n")
+		md.WriteString("| | Blocked | Bypass |\n|---|---|---|\n")
+		md.WriteString(fmt.Sprintf("| Status | %d | %d |\n", baseline.StatusCode, best.StatusCode))
+		md.WriteString(fmt.Sprintf("| Length | %d | %d |\n", baseline.ContentLength, best.ContentLength))
+		md.WriteString(fmt.Sprintf("| Words | %d | %d |\n", baseline.Words, best.Words))
+		md.WriteString(fmt.Sprintf("| Title | %s | %s |\n", tableCell(baseline.Title), tableCell(best.Title)))
+		md.WriteString("\n")
+	}
+
+	if len(findings) > 1 {
+		md.WriteString(level + "# Other requests in this category\n\n")
+		md.WriteString("| Method | Request target | Technique | Status | Length | Confidence |\n")
+		md.WriteString("|---|---|---|---|---|---|\n")
+		for _, f := range findings[1:] {
+			target := f.URL
+			if f.Request != nil {
+				target = f.Request.Target
+			}
+			md.WriteString(fmt.Sprintf("| %s | `%s` | %s | %d | %d | %d%% |\n",
+				f.Method, tableCell(target), tableCell(f.Technique), f.StatusCode, f.ContentLength, f.Confidence))
+		}
+		md.WriteString("\n")
+	}
+
+	md.WriteString(level + "# Remediation\n\n" + RemediationFor(category) + "\n")
+}
+
+// severityLabel turns the response class of a finding into a report severity
+func severityLabel(result bypass.Result) string {
+	switch level, _ := sarifSeverity(result); level {
+	case "error":
+		return "High"
+	case "warning":
+		return "Medium"
+	default:
+		return "Low"
+	}
+}
+
+// responseExcerpt returns the start of the recorded response, status line and headers included
+func responseExcerpt(result bypass.Result) string {
+	excerpt := result
+	if len(excerpt.Response.BodySample) > excerptSize {
+		excerpt.Response.BodySample = excerpt.Response.BodySample[:excerptSize]
+		excerpt.Response.Truncated = true
+	}
+
+	response := strings.ReplaceAll(generateResponse(excerpt), "\r\n", "\n")
+	if excerpt.Response.Truncated {
+		response += "\n[... truncated]"
+	}
+	return response
+}
+
+// fence wraps content in a fenced code block long enough not to clash with any
+// backticks in the content
+func fence(content, lang string) string {
+	ticks := "```"
+	for strings.Contains(content, ticks) {
+		ticks += "`"
+	}
+	content = strings.TrimRight(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	return ticks + lang + "\n" + content + "\n" + ticks + "\n\n"
+}
+
+// indent prefixes every non-empty line, so blocks nest under list items
+func indent(s, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// tableCell escapes a value for use in a Markdown table cell
+func tableCell(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "|", "\\|"), "\n", " ")
+}
@@ -0,0 +1,105 @@
+package output
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ibrahimsql/bypass403/pkg/bypass"
+	"github.com/ibrahimsql/bypass403/pkg/snippet"
+)
+
+// generateMarkdown writes the Markdown report of results and returns it
+func generateMarkdown(t *testing.T, scan Scan, results []bypass.Result) string {
+	t.Helper()
+	filename := tempFile(t, "report.md")
+	if err := GenerateMarkdown(scan, results, []string{snippet.Curl}, filename); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every code block is closed, so nothing after one is swallowed by it
+	if fences := strings.Count("\n"+string(data), "\n```") + strings.Count(string(data), "\n   ```"); fences%2 != 0 {
+		t.Errorf("report has %d fence lines, want them in pairs", fences)
+	}
+	return string(data)
+}
+
+func TestGenerateMarkdown(t *testing.T) {
+	results := testResults()
+	report := generateMarkdown(t, testScan(), results)
+
+	want := []string{
+		"# 403 bypass on " + testTarget + "\n",
+		"| Target | `" + testTarget + "` |\n",
+		"| Tested | Wed, 01 May 2024 12:00:00 UTC |\n",
+		"| Bypassed by | path |\n",
+		"\n## Summary\n\nAccess to `" + testTarget + "` is denied for a normal request, but 1 request variant(s) from 1 technique category",
+		"\n## Blocked baseline\n\nThe unmodified request is blocked with **403** (33 bytes, title \"Error\")",
+		"\n## path\n\n**Severity:** High  \n**Technique:** Path: %2f  \n**Confidence:** 90%\n",
+		"\n### Impact\n\n" + ImpactFor("path") + "\n",
+		"1. Request `" + testTarget + "` normally and observe that it is blocked with 403.\n",
+		"   ```http\n   GET /admin%2f?debug=1 HTTP/1.1\n   Host: example.com\n",
+		"   or with curl:\n\n   ```bash\n",
+		"1. Observe that the server answers **200** instead of the blocked response:\n\n   ```http\n   HTTP/1.1 200 OK\n",
+		"| Status | 403 | 200 |\n",
+		"| Title | Error | Admin |\n",
+		"\n### Remediation\n\n" + RemediationFor("path") + "\n",
+	}
+	for _, w := range want {
+		if !strings.Contains(report, w) {
+			t.Errorf("report doesn't contain %q", w)
+		}
+	}
+
+	if strings.Contains(report, "X-Original-URL") {
+		t.Errorf("report includes the blocked attempt")
+	}
+	if strings.Contains(report, "\r") {
+		t.Errorf("report contains CRLF line endings from the raw request")
+	}
+}
+
+func TestGenerateMarkdownTargets(t *testing.T) {
+	scan := testScan()
+	other := "https://example.org/private"
+	scan.Targets = append(scan.Targets, other)
+	results := append(testResults(), bypass.Result{Target: other, URL: other, StatusCode: 403, Method: "GET"})
+	report := generateMarkdown(t, scan, results)
+
+	want := []string{
+		"# 403 bypass on 2 targets\n",
+		"\n## " + testTarget + "\n\n### Summary\n",
+		"\n### path\n",
+		"\n#### Steps to reproduce\n",
+		"\n## " + other + "\n\n### Summary\n\nNo bypass of the access control was confirmed.\n",
+	}
+	for _, w := range want {
+		if !strings.Contains(report, w) {
+			t.Errorf("report doesn't contain %q", w)
+		}
+	}
+}
+
+func TestFence(t *testing.T) {
+	tests := []struct {
+		content, want string
+	}{
+		{"GET / HTTP/1.1\r\nHost: a\r\n\r\n", "```http\nGET / HTTP/1.1\nHost: a\n```\n\n"},
+		{"echo ```x```", "````http\necho ```x```\n````\n\n"},
+	}
+	for _, tt := range tests {
+		if got := fence(tt.content, "http"); got != tt.want {
+			t.Errorf("fence(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestTableCell(t *testing.T) {
+	if got, want := tableCell("a|b\nc"), `a\|b c`; got != want {
+		t.Errorf("tableCell = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,260 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ibrahimsql/bypass403/pkg/bypass"
+	"github.com/ibrahimsql/bypass403/pkg/http"
+)
+
+// nucleiWord matches the candidate words used for body matchers
+var nucleiWord = regexp.MustCompile(`[A-Za-z][A-Za-z0-9_-]{3,}`)
+
+// nonSlug matches the characters not allowed in a template id
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// nonDirName matches the characters replaced in a target's directory name
+var nonDirName = regexp.MustCompile(`[^a-z0-9.]+`)
+
+// GenerateNuclei writes one nuclei template per confirmed bypass into dir, so
+// fixed findings can be re-verified on a schedule. The request is embedded raw
+// and sent unsafe so non-normalized paths reach the server untouched, and the
+// matchers require the bypass status plus a body word (or the body hash) that
+// the blocked baseline does not have. HTTP/2 requests are left out, as nuclei
+// sends unsafe raw requests over HTTP/1.x. When several targets were scanned,
+// each gets its own subdirectory. It returns the number of templates written.
+func GenerateNuclei(scan Scan, results []bypass.Result, dir string) (int, error) {
+	groups := scan.Groups(results)
+	written := 0
+	for i, group := range groups {
+		targetDir := dir
+		if len(groups) > 1 {
+			targetDir = filepath.Join(dir, fmt.Sprintf("%02d-%s", i+1, targetSlug(group.Target)))
+		}
+		n, err := writeNucleiTemplates(scan, group, targetDir)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// writeNucleiTemplates writes the templates of one target into dir
+func writeNucleiTemplates(scan Scan, group TargetGroup, dir string) (int, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, fmt.Errorf("error creating nuclei template directory: %s", err)
+	}
+
+	seen := make(map[string]bool)
+	perCategory := make(map[string]int)
+	written := 0
+	for _, result := range group.Results {
+		if !result.IsBypass() || result.Request == nil || result.Request.Proto == http.ProtoHTTP2 {
+			continue
+		}
+		raw := string(result.Request.Bytes())
+		if seen[raw] {
+			continue
+		}
+		seen[raw] = true
+		written++
+
+		slug := nucleiSlug(result)
+		perCategory[slug]++
+		id := fmt.Sprintf("bypass403-%s-%d", slug, perCategory[slug])
+		template := nucleiTemplate(id, scan, group.Target, group.Baseline, result)
+		if err := os.WriteFile(filepath.Join(dir, id+".yaml"), []byte(template), 0644); err != nil {
+			return written - 1, fmt.Errorf("error writing nuclei template: %s", err)
+		}
+	}
+
+	return written, nil
+}
+
+// targetSlug turns a target URL into a directory name: its host and path
+// with everything but letters, digits and dots replaced by dashes
+func targetSlug(target string) string {
+	name := target
+	if u, err := url.Parse(target); err == nil && u.Host != "" {
+		name = u.Host + u.Path
+	}
+	slug := strings.Trim(nonDirName.ReplaceAllString(strings.ToLower(name), "-"), "-.")
+	if slug == "" {
+		return "target"
+	}
+	return slug
+}
+
+// nucleiSlug turns the category of a result into the letters, digits and
+// dashes nuclei allows in template ids and tags
+func nucleiSlug(result bypass.Result) string {
+	slug := strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(result.Category), "-"), "-")
+	if slug == "" {
+		return "bypass"
+	}
+	return slug
+}
+
+// nucleiTemplate renders the YAML template for a single bypass
+func nucleiTemplate(id string, scan Scan, target string, baseline *bypass.Baseline, result bypass.Result) string {
+	var y strings.Builder
+
+	severity := strings.ToLower(severityLabel(result))
+	tags := "403,bypass"
+	if slug := nucleiSlug(result); slug != "bypass" {
+		tags += "," + slug
+	}
+
+	y.WriteString("id: " + id + "\n\n")
+	y.WriteString("info:\n")
+	y.WriteString("  name: " + yamlQuote("403 bypass via "+result.Technique) + "\n")
+	y.WriteString("  author: " + scan.Tool + "\n")
+	y.WriteString("  severity: " + severity + "\n")
+	y.WriteString("  description: " + yamlQuote(ImpactFor(result.Category)) + "\n")
+	y.WriteString("  remediation: " + yamlQuote(RemediationFor(result.Category)) + "\n")
+	y.WriteString("  tags: " + tags + "\n")
+	y.WriteString("  metadata:\n")
+	y.WriteString("    target: " + yamlQuote(target) + "\n")
+	y.WriteString("    technique: " + yamlQuote(result.Technique) + "\n")
+	y.WriteString("    confidence: " + strconv.Itoa(result.Confidence) + "\n")
+	y.WriteString("    verified: " + yamlQuote(scan.Started.UTC().Format("2006-01-02")) + "\n")
+	if baseline != nil {
+		y.WriteString("    blocked-status: " + strconv.Itoa(baseline.StatusCode) + "\n")
+	}
+	y.WriteString("    max-request: 1\n\n")
+
+	y.WriteString("http:\n")
+	y.WriteString("  - raw:\n")
+	y.WriteString(nucleiRaw(result))
+	y.WriteString("\n")
+	// Without unsafe, nuclei would normalize the request-target and rebuild the headers
+	y.WriteString("    unsafe: true\n")
+	y.WriteString("    matchers-condition: and\n")
+	y.WriteString("    matchers:\n")
+	y.WriteString("      - type: status\n")
+	y.WriteString("        status:\n")
+	y.WriteString("          - " + strconv.Itoa(result.StatusCode) + "\n")
+
+	if word := distinctiveWord(baseline, result); word != "" {
+		y.WriteString("\n      - type: word\n")
+		y.WriteString("        part: body\n")
+		y.WriteString("        words:\n")
+		y.WriteString("          - " + yamlQuote(word) + "\n")
+	} else if result.BodyHash != "" {
+		y.WriteString("\n      - type: dsl\n")
+		y.WriteString("        dsl:\n")
+		y.WriteString("          - " + yamlQuote("sha256(body) == \""+result.BodyHash+"\"") + "\n")
+	}
+
+	return y.String()
+}
+
+// nucleiRaw writes the request as a raw nuclei request. The Host header is
+// replaced by {{Hostname}} unless the technique changed it on purpose.
+// Requests that can't be written as a literal block without changing their
+// bytes are written as a quoted string instead.
+func nucleiRaw(result bypass.Result) string {
+	req := result.Request.Clone()
+	if u, err := url.Parse(req.Origin); err == nil {
+		// Only the first Host header is replaced, in place, so a duplicate
+		// one the technique added is kept
+		for i, h := range req.Headers {
+			if strings.EqualFold(h.Name, "Host") {
+				if h.Value == u.Host {
+					req.Headers[i].Value = "{{Hostname}}"
+				}
+				break
+			}
+		}
+	}
+	raw := req.Bytes()
+
+	// The literal block turns line breaks into CRLF again, so only requests
+	// without a body and without stray control characters survive it
+	text := strings.ReplaceAll(string(raw), "\r\n", "\n")
+	literal := len(req.Body) == 0 && utf8.ValidString(text) && !strings.HasPrefix(text, " ")
+	for _, r := range text {
+		if r != '\n' && r != '\t' && (r < 0x20 || r == 0x7f) {
+			literal = false
+			break
+		}
+	}
+
+	if !literal {
+		return "      - " + yamlQuote(string(raw)) + "\n"
+	}
+
+	var block strings.Builder
+	block.WriteString("      - |\n")
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		if line == "" {
+			block.WriteString("\n")
+			continue
+		}
+		block.WriteString("        " + line + "\n")
+	}
+	return block.String()
+}
+
+// distinctiveWord picks a word from the bypass response that the blocked
+// baseline doesn't contain, preferring the page title
+func distinctiveWord(baseline *bypass.Baseline, result bypass.Result) string {
+	var blocked []byte
+	if baseline != nil {
+		blocked = baseline.Response.BodySample
+	}
+	body := result.Response.BodySample
+
+	if result.Title != "" {
+		if title := "<title>" + result.Title; bytes.Contains(body, []byte(title)) && !bytes.Contains(blocked, []byte(title)) {
+			return title
+		}
+	}
+
+	for _, word := range nucleiWord.FindAll(body, -1) {
+		if !bytes.Contains(blocked, word) {
+			return string(word)
+		}
+	}
+	return ""
+}
+
+// yamlQuote writes s as a double-quoted YAML scalar
+func yamlQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == utf8.RuneError && size == 1:
+			// YAML has no escape for a raw byte, so invalid UTF-8 becomes the
+			// code point of the same value
+			b.WriteString(fmt.Sprintf(`\x%02x`, s[i]))
+		case r < 0x20 || r == 0x7f:
+			b.WriteString(fmt.Sprintf(`\x%02x`, r))
+		default:
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	b.WriteByte('"')
+	return b.String()
+}
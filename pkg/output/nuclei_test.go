@@ -0,0 +1,180 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/ibrahimsql/bypass403/pkg/http"
+)
+
+// nucleiDoc is the part of a nuclei template the tests read back
+type nucleiDoc struct {
+	ID   string `yaml:"id"`
+	Info struct {
+		Name        string            `yaml:"name"`
+		Author      string            `yaml:"author"`
+		Severity    string            `yaml:"severity"`
+		Description string            `yaml:"description"`
+		Remediation string            `yaml:"remediation"`
+		Tags        string            `yaml:"tags"`
+		Metadata    map[string]string `yaml:"metadata"`
+	} `yaml:"info"`
+	HTTP []struct {
+		Raw               []string `yaml:"raw"`
+		Unsafe            bool     `yaml:"unsafe"`
+		MatchersCondition string   `yaml:"matchers-condition"`
+		Matchers          []struct {
+			Type   string   `yaml:"type"`
+			Part   string   `yaml:"part"`
+			Status []int    `yaml:"status"`
+			Words  []string `yaml:"words"`
+			DSL    []string `yaml:"dsl"`
+		} `yaml:"matchers"`
+	} `yaml:"http"`
+}
+
+// readNuclei parses every template GenerateNuclei wrote under dir, by path
+// relative to dir
+func readNuclei(t *testing.T, dir string) map[string]nucleiDoc {
+	t.Helper()
+	docs := make(map[string]nucleiDoc)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var doc nucleiDoc
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			t.Fatalf("%s is not valid YAML: %s\n%s", path, err, data)
+		}
+		rel, _ := filepath.Rel(dir, path)
+		docs[filepath.ToSlash(rel)] = doc
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return docs
+}
+
+func TestGenerateNuclei(t *testing.T) {
+	results := testResults()
+	dir := t.TempDir()
+	n, err := GenerateNuclei(testScan(), results, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := readNuclei(t, dir)
+	if n != 1 || len(docs) != 1 {
+		t.Fatalf("wrote %d templates, found %d, want one for the bypass", n, len(docs))
+	}
+
+	doc, ok := docs["bypass403-path-1.yaml"]
+	if !ok {
+		t.Fatalf("templates = %v, want bypass403-path-1.yaml", docs)
+	}
+	if doc.ID != "bypass403-path-1" || doc.Info.Name != "403 bypass via Path: %2f" || doc.Info.Severity != "high" ||
+		doc.Info.Tags != "403,bypass,path" {
+		t.Errorf("info = %+v, want the bypass described", doc.Info)
+	}
+	if doc.Info.Metadata["target"] != testTarget || doc.Info.Metadata["blocked-status"] != "403" ||
+		doc.Info.Metadata["verified"] != "2024-05-01" {
+		t.Errorf("metadata = %v, want the target, baseline status and scan date", doc.Info.Metadata)
+	}
+
+	if len(doc.HTTP) != 1 || len(doc.HTTP[0].Raw) != 1 {
+		t.Fatalf("http = %+v, want a single raw request", doc.HTTP)
+	}
+	request := doc.HTTP[0]
+	want := "GET /admin%2f?debug=1 HTTP/1.1\nHost: {{Hostname}}\nUser-Agent: bypass403\nCookie: session=abc; theme=dark\n"
+	if request.Raw[0] != want {
+		t.Errorf("raw = %q, want %q", request.Raw[0], want)
+	}
+	if !request.Unsafe || request.MatchersCondition != "and" || len(request.Matchers) != 2 {
+		t.Fatalf("request = %+v, want unsafe with two matchers", request)
+	}
+	if m := request.Matchers[0]; m.Type != "status" || len(m.Status) != 1 || m.Status[0] != 200 {
+		t.Errorf("first matcher = %+v, want status 200", m)
+	}
+	if m := request.Matchers[1]; m.Type != "word" || m.Part != "body" || len(m.Words) != 1 || m.Words[0] != "<title>Admin" {
+		t.Errorf("second matcher = %+v, want the bypass title", m)
+	}
+}
+
+func TestNucleiRawQuoted(t *testing.T) {
+	result := testResults()[0]
+	result.Request.Method = "POST"
+	result.Request.Headers = append(result.Request.Headers, http.Header{Name: "Host", Value: "internal"})
+	result.Request.Body = []byte("{\"a\":\"b\\\\\"}\r\n\tx\x01")
+
+	var doc struct {
+		Raw []string `yaml:"raw"`
+	}
+	if err := yaml.Unmarshal([]byte("raw:\n"+nucleiRaw(result)), &doc); err != nil {
+		t.Fatalf("raw request is not valid YAML: %s", err)
+	}
+
+	// The duplicate Host header the technique added is kept, and the body
+	// survives byte for byte
+	want := strings.Replace(string(result.Request.Bytes()), "Host: example.com", "Host: {{Hostname}}", 1)
+	if len(doc.Raw) != 1 || doc.Raw[0] != want {
+		t.Errorf("raw = %q, want %q", doc.Raw, want)
+	}
+}
+
+func TestGenerateNucleiTargets(t *testing.T) {
+	scan := testScan()
+	other := "https://example.org:8443/private"
+	scan.Targets = append(scan.Targets, other)
+
+	results := testResults()
+	// A duplicate of the first bypass, and one sent over HTTP/2
+	results = append(results, results[0])
+	h2 := results[0]
+	h2.Target = other
+	h2.Request = testRequest("/private/")
+	h2.Request.Proto = http.ProtoHTTP2
+	results = append(results, h2)
+
+	withoutHost := results[0]
+	withoutHost.Target = other
+	withoutHost.Category = ""
+	withoutHost.Request = testRequest("/private/.")
+	withoutHost.Request.Origin = "https://example.org:8443"
+	results = append(results, withoutHost)
+
+	dir := t.TempDir()
+	n, err := GenerateNuclei(scan, results, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := readNuclei(t, dir)
+	if n != 2 || len(docs) != 2 {
+		t.Fatalf("wrote %d templates, found %v, want one per target", n, docs)
+	}
+	for _, name := range []string{"01-example.com-admin/bypass403-path-1.yaml", "02-example.org-8443-private/bypass403-bypass-1.yaml"} {
+		if _, ok := docs[name]; !ok {
+			t.Errorf("templates = %v, want %s", docs, name)
+		}
+	}
+}
+
+func TestYAMLQuote(t *testing.T) {
+	for _, s := range []string{"plain", `"quoted" \ back`, "line\r\nbreak\ttab", "bell\x07 del\x7f", "ünïcode"} {
+		var got string
+		if err := yaml.Unmarshal([]byte(yamlQuote(s)), &got); err != nil {
+			t.Errorf("yamlQuote(%q) = %s is not valid YAML: %s", s, yamlQuote(s), err)
+			continue
+		}
+		if got != s {
+			t.Errorf("yamlQuote(%q) reads back as %q", s, got)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package output
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ibrahimsql/bypass403/pkg/bypass"
+	"github.com/ibrahimsql/bypass403/pkg/http"
+)
+
+const testTarget = "https://example.com/admin"
+
+var testStarted = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// testScan describes a scan of testTarget with its baseline
+func testScan() Scan {
+	return Scan{
+		Tool:       "bypass403",
+		Version:    "test",
+		Targets:    []string{testTarget},
+		Categories: []string{"path", "header"},
+		Started:    testStarted,
+		Finished:   testStarted.Add(3 * time.Second),
+		Baselines: map[string]*bypass.Baseline{
+			testTarget: {
+				StatusCode:  403,
+				Fingerprint: bypass.Fingerprint{ContentLength: 33, Words: 3, Lines: 1, Title: "Error", BodyHash: "b10c"},
+				Signature:   bypass.BlockSignature{StatusCode: 403, Phrase: "access denied"},
+				Request:     testRequest("/admin"),
+				Response: bypass.ResponseEvidence{
+					Proto:      "HTTP/1.1",
+					Status:     "403 Forbidden",
+					Headers:    map[string][]string{"Content-Type": {"text/html"}},
+					BodySample: []byte("<title>Error</title>Access denied"),
+				},
+			},
+		},
+	}
+}
+
+// testRequest builds the raw request recorded for a request-target of testTarget
+func testRequest(target string) *http.Request {
+	return &http.Request{
+		Method: "GET",
+		Origin: "https://example.com",
+		Target: target,
+		Proto:  "HTTP/1.1",
+		Headers: []http.Header{
+			{Name: "Host", Value: "example.com"},
+			{Name: "User-Agent", Value: "bypass403"},
+			{Name: "Cookie", Value: "session=abc; theme=dark"},
+		},
+	}
+}
+
+// testResults returns a confirmed bypass of testTarget followed by an attempt
+// that was still blocked
+func testResults() []bypass.Result {
+	body := "<title>Admin</title>Welcome back"
+	return []bypass.Result{
+		{
+			Target:      testTarget,
+			URL:         "https://example.com/admin%2f?debug=1",
+			StatusCode:  200,
+			Method:      "GET",
+			Technique:   "Path: %2f",
+			Category:    "path",
+			Fingerprint: bypass.Fingerprint{ContentLength: len(body), Words: 3, Lines: 1, Title: "Admin", BodyHash: "ad31"},
+			Request:     testRequest("/admin%2f?debug=1"),
+			Response: bypass.ResponseEvidence{
+				Proto:      "HTTP/1.1",
+				Status:     "200 OK",
+				Headers:    map[string][]string{"Content-Type": {"text/html; charset=utf-8"}},
+				BodySample: []byte(body),
+				Raw:        []byte("HTTP/1.1 200 OK\r\nContent-Type: text/html; charset=utf-8\r\n\r\n" + body),
+				Time:       12 * time.Millisecond,
+				RemoteAddr: "93.184.216.34:443",
+				Started:    testStarted.Add(time.Second),
+			},
+			Classification: bypass.ClassBypass,
+			Confidence:     90,
+			Matched:        true,
+		},
+		{
+			Target:      testTarget,
+			URL:         testTarget,
+			StatusCode:  403,
+			Method:      "GET",
+			Technique:   "Header: X-Original-URL",
+			Category:    "header",
+			Fingerprint: bypass.Fingerprint{ContentLength: 33, Words: 3, Lines: 1, Title: "Error", BodyHash: "b10c"},
+			Request:     testRequest("/admin"),
+			Response: bypass.ResponseEvidence{
+				Proto:      "HTTP/1.1",
+				Status:     "403 Forbidden",
+				Headers:    map[string][]string{"Content-Type": {"text/html"}},
+				BodySample: []byte("<title>Error</title>Access denied"),
+				Time:       8 * time.Millisecond,
+				Started:    testStarted.Add(2 * time.Second),
+			},
+			Classification: bypass.ClassBlocked,
+			Confidence:     95,
+		},
+	}
+}
+
+// tempFile returns the path of a file named name in a new temporary directory
+func tempFile(t *testing.T, name string) string {
+	t.Helper()
+	return filepath.Join(t.TempDir(), name)
+}
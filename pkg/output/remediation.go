@@ -0,0 +1,109 @@
+package output
+
+// remediation holds the advice for fixing each category of bypass, keyed by
+// the technique categories of bypass.GetTechniques
+var remediation = map[string]string{
+	"Request Method": "Enforce access control for every HTTP method, not just GET and POST. " +
+		"Deny methods the application does not use (e.g. TRACE, PUT, arbitrary verbs) at the edge, " +
+		"and make sure HEAD and OPTIONS are routed through the same authorization checks.",
+	"URL Path": "Normalize the request path once, before any access control decision, and make " +
+		"the proxy and the application agree on that normalization. Reject or canonicalize " +
+		"repeated slashes, dot segments, trailing characters and case variations instead of " +
+		"matching rules against the raw path.",
+	"Headers": "Do not let client-supplied headers such as X-Original-URL, X-Rewrite-URL or " +
+		"X-Forwarded-* change routing or authorization. Strip them at the edge proxy and only " +
+		"trust them when set by infrastructure you control.",
+	"IP Spoofing": "Never base access decisions on X-Forwarded-For, X-Real-IP, Client-IP or similar " +
+		"headers sent by the client. Take the client address from the connection, or from a " +
+		"header that your own trusted proxy overwrites, and restrict internal-only paths at the network layer.",
+	"URL Encoding": "Decode the path exactly once and apply access control to the decoded, " +
+		"normalized form. Reject double-encoded, overlong UTF-8 and encoded slash sequences " +
+		"(%2f, %5c) unless the application genuinely needs them.",
+	"Protocol": "Apply the same rules regardless of scheme, protocol version or request-target form. " +
+		"Redirect plain HTTP to HTTPS before routing, and reject absolute-form request targets " +
+		"whose host does not match the virtual host.",
+	"Path Traversal": "Resolve ../ and ..; segments before routing and authorization, and make sure " +
+		"path parameters (;) are handled identically by the proxy and the backend server.",
+	"Proxy": "Make cache and proxy configuration consistent with the origin's access control. " +
+		"Ignore client-supplied forwarding and cache-key headers, and never cache authorization " +
+		"failures or protected content under keys an attacker can influence.",
+	"Specialized": "Validate and normalize the complete request (query string, fragments, control " +
+		"characters, CRLF sequences, User-Agent and Referer) before authorization, and do not grant " +
+		"exceptions to crawlers or referrers based on headers the client controls.",
+	"Wordlist": "Protect the resource itself rather than a list of known path spellings: deny by " +
+		"default, and apply authorization in the application after routing so that every alias " +
+		"of the resource is covered.",
+	"Origin IP": "Only accept traffic to the origin from the CDN or WAF in front of it: restrict the origin's " +
+		"firewall to the edge's address ranges or require an authenticated connection (mTLS, a secret header " +
+		"set by the edge), and enforce the same access rules on the origin itself.",
+	"HTTP/2": "Reject malformed HTTP/2 requests at the edge (duplicate or misplaced pseudo-headers, uppercase " +
+		"header names, :authority disagreeing with Host) and make the edge and the backend derive the path and " +
+		"host from the same fields, or downgrade to HTTP/1.1 at the edge with the same normalization as direct traffic.",
+	"h2c Smuggling": "Don't forward Upgrade: h2c from the proxy (strip the Upgrade and HTTP2-Settings headers, " +
+		"or only pass the upgrade types the application needs, such as websocket), or enforce the access rules " +
+		"on the backend, which sees every request on an upgraded connection.",
+	"Combined": "Several weaknesses combine here. Normalize the path and strip untrusted headers at " +
+		"the edge, then enforce authorization in the application itself so no single layer's " +
+		"parsing decides access.",
+}
+
+// defaultRemediation is the advice for categories without specific notes
+const defaultRemediation = "Enforce authorization in the application on the normalized request, " +
+	"deny by default, and make sure every proxy in front of it parses requests the same way."
+
+// impact describes what each category of bypass means for the application
+var impact = map[string]string{
+	"Request Method": "The resource is only protected for some HTTP methods. An attacker can reach it " +
+		"by switching method, which may expose its content or let state-changing handlers run " +
+		"without authorization.",
+	"URL Path": "The access control rule matches a specific spelling of the path, while the server " +
+		"resolves variants of it to the same resource. Anyone can read the protected resource by " +
+		"requesting it under an alternative path.",
+	"Headers": "A request header sent by the client changes how the request is routed or authorized. " +
+		"An unauthenticated attacker can use it to reach resources that the front-end blocks.",
+	"IP Spoofing": "The application trusts a client-supplied header to determine the caller's IP address. " +
+		"An external attacker can impersonate an internal or allow-listed address and access " +
+		"resources restricted to it.",
+	"URL Encoding": "The access control layer and the backend decode the path differently. An attacker " +
+		"can encode the path so the block rule does not match while the backend still serves the " +
+		"protected resource.",
+	"Protocol": "Access control depends on the protocol, scheme or request-target form. An attacker can " +
+		"reach the protected resource by changing how the request is expressed rather than what it asks for.",
+	"Path Traversal": "Dot segments or path parameters are resolved after the access control decision. " +
+		"An attacker can route around the protected prefix and reach the resource from an allowed path.",
+	"Proxy": "A caching or reverse proxy in front of the application can be steered with request headers. " +
+		"An attacker can retrieve the protected content through the proxy, and cached responses may " +
+		"expose it to other users.",
+	"Specialized": "The request is parsed differently by the components in front of the application. " +
+		"An attacker can exploit that discrepancy to access the protected resource.",
+	"Wordlist": "The protected resource is reachable under alternative paths that the access control does " +
+		"not cover. Anyone who knows or guesses one of them can access it.",
+	"Origin IP": "The access control is enforced only at the CDN or WAF edge, and the origin server behind it " +
+		"is reachable directly. Anyone who finds the origin's address can request the resource without passing the edge.",
+	"HTTP/2": "The edge and the backend read HTTP/2 pseudo-headers or header names differently. An attacker " +
+		"can send a request the edge allows but the backend serves as the protected resource.",
+	"h2c Smuggling": "The proxy forwards h2c upgrades and then only tunnels the connection. An attacker can " +
+		"upgrade on an allowed path and request the protected resource over HTTP/2 without the proxy checking it.",
+	"Combined": "A combination of request manipulations defeats the access control. An attacker who " +
+		"chains them can access the protected resource.",
+}
+
+// defaultImpact is the impact text for categories without a specific description
+const defaultImpact = "The access control protecting the resource can be bypassed, exposing it to " +
+	"unauthorized users."
+
+// ImpactFor returns the impact description for a technique category
+func ImpactFor(category string) string {
+	if text, ok := impact[category]; ok {
+		return text
+	}
+	return defaultImpact
+}
+
+// RemediationFor returns the remediation advice for a technique category
+func RemediationFor(category string) string {
+	if text, ok := remediation[category]; ok {
+		return text
+	}
+	return defaultRemediation
+}
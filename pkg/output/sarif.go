@@ -0,0 +1,348 @@
+package output
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ibrahimsql/bypass403/pkg/bypass"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	toolURI      = "https://github.com/ibrahimsql/bypass403"
+)
+
+// The SARIF types below cover the subset of SARIF 2.1.0 written by GenerateSARIF
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	Invocations []sarifInvocation `json:"invocations"`
+	Results     []sarifResult     `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string            `json:"id"`
+	Name                 string            `json:"name"`
+	ShortDescription     sarifMessage      `json:"shortDescription"`
+	FullDescription      sarifMessage      `json:"fullDescription"`
+	DefaultConfiguration sarifRuleConfig   `json:"defaultConfiguration"`
+	Properties           sarifRuleProperty `json:"properties"`
+}
+
+type sarifRuleProperty struct {
+	Category         string   `json:"category"`
+	SecuritySeverity string   `json:"security-severity"`
+	Tags             []string `json:"tags"`
+}
+
+type sarifRuleConfig struct {
+	Level string `json:"level"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful bool   `json:"executionSuccessful"`
+	StartTimeUTC        string `json:"startTimeUtc"`
+	EndTimeUTC          string `json:"endTimeUtc"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string              `json:"ruleId"`
+	Level               string              `json:"level"`
+	Message             sarifMessage        `json:"message"`
+	Locations           []sarifLocation     `json:"locations"`
+	PartialFingerprints map[string]string   `json:"partialFingerprints"`
+	WebRequest          sarifWebRequest     `json:"webRequest"`
+	WebResponse         sarifWebResponse    `json:"webResponse"`
+	Properties          sarifResultProperty `json:"properties"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifWebRequest struct {
+	Protocol string            `json:"protocol,omitempty"`
+	Version  string            `json:"version,omitempty"`
+	Target   string            `json:"target"`
+	Method   string            `json:"method"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Body     *sarifContent     `json:"body,omitempty"`
+}
+
+type sarifWebResponse struct {
+	Protocol     string            `json:"protocol,omitempty"`
+	Version      string            `json:"version,omitempty"`
+	StatusCode   int               `json:"statusCode"`
+	ReasonPhrase string            `json:"reasonPhrase,omitempty"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	Body         *sarifContent     `json:"body,omitempty"`
+}
+
+type sarifContent struct {
+	Text string `json:"text"`
+}
+
+type sarifResultProperty struct {
+	Target         string `json:"target,omitempty"`
+	Category       string `json:"category,omitempty"`
+	Technique      string `json:"technique"`
+	Classification string `json:"classification"`
+	Confidence     int    `json:"confidence"`
+	RawRequest     string `json:"rawRequest,omitempty"`
+}
+
+// GenerateSARIF writes confirmed bypasses as a SARIF 2.1.0 log for code-scanning
+// dashboards. Each technique category is a rule, and each bypass a result
+// naming the technique variant and carrying the exact request and response
+// as evidence.
+func GenerateSARIF(scan Scan, results []bypass.Result, filename string) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           scan.Tool,
+			Version:        scan.Version,
+			InformationURI: toolURI,
+			Rules:          []sarifRule{},
+		}},
+		Invocations: []sarifInvocation{{
+			ExecutionSuccessful: true,
+			StartTimeUTC:        scan.Started.UTC().Format(time.RFC3339),
+			EndTimeUTC:          scan.Finished.UTC().Format(time.RFC3339),
+		}},
+		Results: []sarifResult{},
+	}
+
+	rules := make(map[string]sarifRule)
+	for _, group := range scan.Groups(results) {
+		for _, result := range group.Results {
+			if !result.IsBypass() {
+				continue
+			}
+
+			level, severity := sarifSeverity(result)
+			id := ruleID(result.Category)
+			if rule, ok := rules[id]; !ok || severityRank(level) > severityRank(rule.DefaultConfiguration.Level) {
+				rules[id] = newSARIFRule(result, level, severity)
+			}
+
+			run.Results = append(run.Results, newSARIFResult(result, level))
+		}
+	}
+
+	ids := make([]string, 0, len(rules))
+	for id := range rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rules[id])
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creating SARIF output file: %s", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(sarifLog{Version: sarifVersion, Schema: sarifSchema, Runs: []sarifRun{run}}); err != nil {
+		return fmt.Errorf("error writing SARIF output: %s", err)
+	}
+
+	return nil
+}
+
+// sarifSeverity derives the SARIF level and a CVSS-like security severity
+// from the response class: content served in place of the block page is an
+// error, a redirect or a different error page is worth a warning
+func sarifSeverity(result bypass.Result) (string, string) {
+	switch {
+	case result.StatusCode >= 200 && result.StatusCode < 300 && result.Confidence >= 70:
+		return "error", "8.1"
+	case result.StatusCode >= 200 && result.StatusCode < 300:
+		return "warning", "5.3"
+	case result.StatusCode >= 300 && result.StatusCode < 400:
+		return "warning", "4.3"
+	default:
+		return "note", "3.1"
+	}
+}
+
+// severityRank orders SARIF levels so a rule gets the level of its worst result
+func severityRank(level string) int {
+	switch level {
+	case "error":
+		return 3
+	case "warning":
+		return 2
+	default:
+		return 1
+	}
+}
+
+// newSARIFRule describes a technique category as a SARIF rule
+func newSARIFRule(result bypass.Result, level, severity string) sarifRule {
+	category := ruleCategory(result.Category)
+
+	return sarifRule{
+		ID:               ruleID(result.Category),
+		Name:             ruleName(category),
+		ShortDescription: sarifMessage{Text: "403 bypass: " + category},
+		FullDescription: sarifMessage{Text: fmt.Sprintf(
+			"The access control protecting the resource was bypassed with a technique of the %s category.",
+			category)},
+		DefaultConfiguration: sarifRuleConfig{Level: level},
+		Properties: sarifRuleProperty{
+			Category:         category,
+			SecuritySeverity: severity,
+			Tags:             []string{"security", "access-control"},
+		},
+	}
+}
+
+// newSARIFResult records a bypass with its request and response as evidence
+func newSARIFResult(result bypass.Result, level string) sarifResult {
+	sarif := sarifResult{
+		RuleID: ruleID(result.Category),
+		Level:  level,
+		Message: sarifMessage{Text: fmt.Sprintf("%s %s returned %d instead of the blocked response with %s (%s, confidence %d%%)",
+			result.Method, result.URL, result.StatusCode, result.Technique, result.Classification, result.Confidence)},
+		Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: result.URL},
+		}}},
+		PartialFingerprints: map[string]string{"bypass403/v1": resultFingerprint(result)},
+		WebRequest: sarifWebRequest{
+			Target: result.URL,
+			Method: result.Method,
+		},
+		WebResponse: sarifWebResponse{StatusCode: result.StatusCode},
+		Properties: sarifResultProperty{
+			Target:         result.Target,
+			Category:       result.Category,
+			Technique:      result.Technique,
+			Classification: result.Classification,
+			Confidence:     result.Confidence,
+		},
+	}
+
+	if req := result.Request; req != nil {
+		sarif.WebRequest.Protocol, sarif.WebRequest.Version = splitProto(req.Proto)
+		sarif.WebRequest.Target = req.Target
+		sarif.WebRequest.Headers = make(map[string]string)
+		for _, h := range req.Headers {
+			if v, ok := sarif.WebRequest.Headers[h.Name]; ok {
+				sarif.WebRequest.Headers[h.Name] = v + ", " + h.Value
+			} else {
+				sarif.WebRequest.Headers[h.Name] = h.Value
+			}
+		}
+		if len(req.Body) > 0 {
+			sarif.WebRequest.Body = &sarifContent{Text: string(req.Body)}
+		}
+		sarif.Properties.RawRequest = string(req.Bytes())
+	}
+
+	evidence := result.Response
+	sarif.WebResponse.Protocol, sarif.WebResponse.Version = splitProto(evidence.Proto)
+	if idx := strings.Index(evidence.Status, " "); idx != -1 {
+		sarif.WebResponse.ReasonPhrase = evidence.Status[idx+1:]
+	}
+	if len(evidence.Headers) > 0 {
+		sarif.WebResponse.Headers = make(map[string]string)
+		for name, values := range evidence.Headers {
+			sarif.WebResponse.Headers[name] = strings.Join(values, ", ")
+		}
+	}
+	if len(evidence.BodySample) > 0 {
+		sarif.WebResponse.Body = &sarifContent{Text: string(evidence.BodySample)}
+	}
+
+	return sarif
+}
+
+// ruleCategory returns the category a result is filed under
+func ruleCategory(category string) string {
+	if category == "" {
+		return "Uncategorized"
+	}
+	return category
+}
+
+// ruleID returns the stable SARIF rule ID of a technique category, such as
+// "bypass403/url-path", so findings keep their rule whatever the variant
+func ruleID(category string) string {
+	return "bypass403/" + strings.ToLower(strings.Join(ruleWords(ruleCategory(category)), "-"))
+}
+
+// ruleName turns a category into a PascalCase SARIF rule name
+func ruleName(category string) string {
+	var name strings.Builder
+	for _, word := range ruleWords(category) {
+		name.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return name.String()
+}
+
+// ruleWords splits a category into its alphanumeric words
+func ruleWords(category string) []string {
+	return strings.FieldsFunc(category, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	})
+}
+
+// resultFingerprint identifies a finding across runs, independently of the response
+func resultFingerprint(result bypass.Result) string {
+	target := result.URL
+	if result.Request != nil {
+		target = result.Request.Origin + "\n" + result.Request.Target
+	}
+	sum := sha256.Sum256([]byte(result.Technique + "\n" + result.Method + "\n" + target))
+	return hex.EncodeToString(sum[:16])
+}
+
+// splitProto splits "HTTP/1.1" into its protocol and version
+func splitProto(proto string) (string, string) {
+	if idx := strings.Index(proto, "/"); idx != -1 {
+		if _, err := strconv.ParseFloat(proto[idx+1:], 64); err == nil {
+			return proto[:idx], proto[idx+1:]
+		}
+	}
+	return proto, ""
+}
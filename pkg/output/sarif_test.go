@@ -0,0 +1,144 @@
+package output
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+// readSARIF parses a SARIF log written by GenerateSARIF, failing on the
+// fields a SARIF consumer requires
+func readSARIF(t *testing.T, filename string) sarifLog {
+	t.Helper()
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("SARIF log is not valid JSON: %s", err)
+	}
+	for _, field := range []string{"version", "$schema", "runs"} {
+		if _, ok := raw[field]; !ok {
+			t.Errorf("SARIF log has no %q", field)
+		}
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("got version %q with %d runs, want 2.1.0 with one run", log.Version, len(log.Runs))
+	}
+	return log
+}
+
+func TestGenerateSARIF(t *testing.T) {
+	results := testResults()
+	filename := tempFile(t, "results.sarif")
+	if err := GenerateSARIF(testScan(), results, filename); err != nil {
+		t.Fatal(err)
+	}
+	run := readSARIF(t, filename).Runs[0]
+
+	if run.Tool.Driver.Name != "bypass403" || len(run.Tool.Driver.Rules) != 1 {
+		t.Fatalf("driver = %+v, want bypass403 with one rule", run.Tool.Driver)
+	}
+	rule := run.Tool.Driver.Rules[0]
+	if rule.ID != "bypass403/path" || rule.Name != "Path" || rule.DefaultConfiguration.Level != "error" ||
+		rule.Properties.SecuritySeverity != "8.1" {
+		t.Errorf("rule = %+v, want bypass403/path at error, severity 8.1", rule)
+	}
+	if run.Invocations[0].StartTimeUTC != "2024-05-01T12:00:00Z" || run.Invocations[0].EndTimeUTC != "2024-05-01T12:00:03Z" {
+		t.Errorf("invocation = %+v, want the scan times", run.Invocations[0])
+	}
+
+	if len(run.Results) != 1 {
+		t.Fatalf("got %d results, want only the bypass", len(run.Results))
+	}
+	result := run.Results[0]
+	if result.RuleID != rule.ID || result.Level != "error" {
+		t.Errorf("result is filed under %s at %s, want %s at error", result.RuleID, result.Level, rule.ID)
+	}
+	if uri := result.Locations[0].PhysicalLocation.ArtifactLocation.URI; uri != results[0].URL {
+		t.Errorf("location = %q, want %q", uri, results[0].URL)
+	}
+	if result.PartialFingerprints["bypass403/v1"] != resultFingerprint(results[0]) {
+		t.Errorf("fingerprints = %v, want %s", result.PartialFingerprints, resultFingerprint(results[0]))
+	}
+
+	request := result.WebRequest
+	if request.Protocol != "HTTP" || request.Version != "1.1" || request.Target != "/admin%2f?debug=1" ||
+		request.Headers["Cookie"] != "session=abc; theme=dark" {
+		t.Errorf("webRequest = %+v, want the request sent", request)
+	}
+	if result.Properties.RawRequest != string(results[0].Request.Bytes()) {
+		t.Errorf("rawRequest = %q, want the exact bytes sent", result.Properties.RawRequest)
+	}
+	response := result.WebResponse
+	if response.StatusCode != 200 || response.ReasonPhrase != "OK" || response.Body == nil ||
+		response.Body.Text != string(results[0].Response.BodySample) {
+		t.Errorf("webResponse = %+v, want the 200 with its body", response)
+	}
+}
+
+func TestGenerateSARIFEmpty(t *testing.T) {
+	filename := tempFile(t, "results.sarif")
+	if err := GenerateSARIF(testScan(), testResults()[1:], filename); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log struct {
+		Runs []struct {
+			Results []json.RawMessage `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatal(err)
+	}
+	// An empty list, not null, tells code scanning that earlier alerts are fixed
+	if len(log.Runs) != 1 || log.Runs[0].Results == nil || len(log.Runs[0].Results) != 0 {
+		t.Errorf("runs = %s, want one run with no results", data)
+	}
+}
+
+func TestResultFingerprint(t *testing.T) {
+	results := testResults()
+	a := results[0]
+	b := results[0]
+	b.StatusCode = 302
+	b.Response.BodySample = []byte("changed")
+	if resultFingerprint(a) != resultFingerprint(b) {
+		t.Errorf("fingerprint depends on the response")
+	}
+
+	b.Technique = "Path: ;/"
+	if resultFingerprint(a) == resultFingerprint(b) {
+		t.Errorf("different techniques share a fingerprint")
+	}
+}
+
+func TestRuleID(t *testing.T) {
+	tests := []struct {
+		category, id, name string
+	}{
+		{"path", "bypass403/path", "Path"},
+		{"URL Path", "bypass403/url-path", "URLPath"},
+		{"ip-spoofing", "bypass403/ip-spoofing", "IpSpoofing"},
+		{"", "bypass403/uncategorized", "Uncategorized"},
+	}
+	for _, tt := range tests {
+		if id := ruleID(tt.category); id != tt.id {
+			t.Errorf("ruleID(%q) = %q, want %q", tt.category, id, tt.id)
+		}
+		if name := ruleName(ruleCategory(tt.category)); name != tt.name {
+			t.Errorf("ruleName(%q) = %q, want %q", tt.category, name, tt.name)
+		}
+	}
+}
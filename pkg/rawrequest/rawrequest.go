@@ -0,0 +1,198 @@
+package rawrequest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ibrahimsql/bypass403/pkg/http"
+)
+
+// dropped are the headers of a request file that are not carried over: the
+// Host and the connection handling come from the target, the body length is
+// recomputed, and responses must arrive uncompressed to be compared
+var dropped = []string{"Host", "Content-Length", "Transfer-Encoding", "Connection", "Accept-Encoding"}
+
+// Load reads a raw HTTP request, as saved from a proxy or written by hand, or
+// the first item of a Burp Suite "Save items" export
+func Load(path string) (*http.Request, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading request file: %s", err)
+	}
+
+	req, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing request file: %s", err)
+	}
+	return req, nil
+}
+
+// Parse parses a raw HTTP request or a Burp Suite export. The origin is taken
+// from the Burp item, an absolute-form request-target or the Host header, the
+// latter over https unless it names port 80.
+func Parse(data []byte) (*http.Request, error) {
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("<?xml")) || bytes.HasPrefix(trimmed, []byte("<items")) {
+		return parseBurp(trimmed)
+	}
+	return parseRaw(data, "")
+}
+
+// burpItems is the part of a Burp Suite export that is read
+type burpItems struct {
+	Items []struct {
+		Protocol string `xml:"protocol"`
+		Host     string `xml:"host"`
+		Port     string `xml:"port"`
+		Request  struct {
+			Base64 bool   `xml:"base64,attr"`
+			Data   string `xml:",chardata"`
+		} `xml:"request"`
+	} `xml:"item"`
+}
+
+// parseBurp parses the first item of a Burp Suite export
+func parseBurp(data []byte) (*http.Request, error) {
+	var export burpItems
+	if err := xml.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("invalid Burp Suite export: %s", err)
+	}
+	if len(export.Items) == 0 {
+		return nil, fmt.Errorf("the Burp Suite export has no items")
+	}
+
+	item := export.Items[0]
+	raw := []byte(item.Request.Data)
+	if item.Request.Base64 {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(item.Request.Data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 request in Burp Suite export: %s", err)
+		}
+		raw = decoded
+	}
+
+	origin := ""
+	if item.Protocol != "" && item.Host != "" {
+		origin = item.Protocol + "://" + item.Host
+		if item.Port != "" && !defaultPort(item.Protocol, item.Port) {
+			origin = item.Protocol + "://" + net.JoinHostPort(item.Host, item.Port)
+		}
+	}
+	return parseRaw(raw, origin)
+}
+
+// parseRaw parses the request line, headers and body of a raw request, with
+// either CRLF or bare LF line endings
+func parseRaw(data []byte, origin string) (*http.Request, error) {
+	head, body := data, []byte(nil)
+	for _, sep := range []string{"\r\n\r\n", "\n\n"} {
+		if idx := bytes.Index(data, []byte(sep)); idx != -1 {
+			head, body = data[:idx], data[idx+len(sep):]
+			break
+		}
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(head), "\r\n", "\n"), "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("the request is empty")
+	}
+
+	parts := strings.Fields(lines[0])
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid request line: %q", lines[0])
+	}
+	method, target := parts[0], parts[1]
+
+	var headers []http.Header
+	host := ""
+	for _, line := range lines[1:] {
+		if line == "" {
+			continue
+		}
+		idx := strings.Index(line, ":")
+		if idx <= 0 {
+			return nil, fmt.Errorf("invalid header line: %q", line)
+		}
+		name, value := line[:idx], strings.TrimSpace(line[idx+1:])
+		if strings.EqualFold(name, "Host") && host == "" {
+			host = value
+		}
+		headers = append(headers, http.Header{Name: name, Value: value})
+	}
+
+	// An absolute-form target names the origin itself
+	if strings.Contains(target, "://") && !strings.HasPrefix(target, "/") {
+		var err error
+		if origin, target, err = http.SplitURL(target); err != nil {
+			return nil, err
+		}
+	}
+	if origin == "" {
+		if host == "" {
+			return nil, fmt.Errorf("the request has no Host header")
+		}
+		origin = "https://" + host
+		if _, port, err := net.SplitHostPort(host); err == nil && port == "80" {
+			origin = "http://" + host
+		}
+	}
+
+	req, err := http.NewRequest(method, origin, target)
+	if err != nil {
+		return nil, err
+	}
+	req.Headers = nil
+	for _, h := range headers {
+		if !isDropped(h.Name) {
+			req.AddHeader(h.Name, h.Value)
+		}
+	}
+	req.Body = requestBody(body, headers)
+
+	return req, nil
+}
+
+// requestBody trims the body to its Content-Length, if it had one, or else
+// drops the trailing newline an editor adds to the file
+func requestBody(body []byte, headers []http.Header) []byte {
+	trimmed := false
+	for _, h := range headers {
+		if strings.EqualFold(h.Name, "Content-Length") {
+			if n, err := strconv.Atoi(h.Value); err == nil && n >= 0 && n <= len(body) {
+				body, trimmed = body[:n], true
+			}
+			break
+		}
+	}
+	if !trimmed {
+		body = bytes.TrimSuffix(bytes.TrimSuffix(body, []byte("\n")), []byte("\r"))
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	return body
+}
+
+// isDropped reports whether a header of the request file is left out
+func isDropped(name string) bool {
+	for _, d := range dropped {
+		if strings.EqualFold(d, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultPort reports whether port is the default port of the scheme
+func defaultPort(scheme, port string) bool {
+	return (scheme == "https" && port == "443") || (scheme == "http" && port == "80")
+}
@@ -0,0 +1,127 @@
+package rawrequest
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+
+	"github.com/ibrahimsql/bypass403/pkg/http"
+)
+
+func TestParse(t *testing.T) {
+	burp := func(protocol, port, raw string) string {
+		return `<?xml version="1.0"?>
+<items burpVersion="2023.1">
+  <item>
+    <url><![CDATA[https://example.com/admin]]></url>
+    <host ip="192.0.2.1">example.com</host>
+    <port>` + port + `</port>
+    <protocol>` + protocol + `</protocol>
+    <request base64="true"><![CDATA[` + base64.StdEncoding.EncodeToString([]byte(raw)) + `]]></request>
+  </item>
+</items>`
+	}
+
+	tests := []struct {
+		name    string
+		data    string
+		method  string
+		origin  string
+		target  string
+		headers []http.Header
+		body    string
+		wantErr bool
+	}{
+		{
+			name:    "CRLF request over https",
+			data:    "GET /admin?x=1 HTTP/1.1\r\nHost: example.com\r\nUser-Agent: test\r\nAccept-Encoding: gzip\r\nConnection: keep-alive\r\n\r\n",
+			method:  "GET",
+			origin:  "https://example.com",
+			target:  "/admin?x=1",
+			headers: []http.Header{{Name: "User-Agent", Value: "test"}},
+		},
+		{
+			name:    "LF line endings and a body",
+			data:    "POST /api HTTP/1.1\nHost: example.com\nContent-Type: application/json\n\n{\"a\":1}\n",
+			method:  "POST",
+			origin:  "https://example.com",
+			target:  "/api",
+			headers: []http.Header{{Name: "Content-Type", Value: "application/json"}},
+			body:    `{"a":1}`,
+		},
+		{
+			name:    "Content-Length trims the body",
+			data:    "POST /api HTTP/1.1\r\nHost: example.com\r\nContent-Length: 3\r\n\r\nabcdef",
+			method:  "POST",
+			origin:  "https://example.com",
+			target:  "/api",
+			headers: nil,
+			body:    "abc",
+		},
+		{
+			name:   "port 80 means http",
+			data:   "GET / HTTP/1.1\r\nHost: example.com:80\r\n\r\n",
+			method: "GET",
+			origin: "http://example.com:80",
+			target: "/",
+		},
+		{
+			name:   "absolute-form target",
+			data:   "GET http://example.com:8080/admin HTTP/1.1\r\nHost: other.example\r\n\r\n",
+			method: "GET",
+			origin: "http://example.com:8080",
+			target: "/admin",
+		},
+		{
+			name:    "repeated headers are kept in order",
+			data:    "GET / HTTP/1.1\r\nHost: example.com\r\nCookie: a=1\r\nX-Test: 1\r\nCookie: b=2\r\n\r\n",
+			method:  "GET",
+			origin:  "https://example.com",
+			target:  "/",
+			headers: []http.Header{{Name: "Cookie", Value: "a=1"}, {Name: "X-Test", Value: "1"}, {Name: "Cookie", Value: "b=2"}},
+		},
+		{
+			name:    "Burp export",
+			data:    burp("https", "8443", "PUT /admin HTTP/1.1\r\nHost: example.com:8443\r\nAuthorization: Bearer t\r\n\r\nbody"),
+			method:  "PUT",
+			origin:  "https://example.com:8443",
+			target:  "/admin",
+			headers: []http.Header{{Name: "Authorization", Value: "Bearer t"}},
+			body:    "body",
+		},
+		{
+			name:   "Burp export on the default port",
+			data:   burp("http", "80", "GET /x HTTP/1.1\r\nHost: example.com\r\n\r\n"),
+			method: "GET",
+			origin: "http://example.com",
+			target: "/x",
+		},
+		{name: "empty", data: "\r\n\r\n", wantErr: true},
+		{name: "no request-target", data: "GET\r\nHost: example.com\r\n\r\n", wantErr: true},
+		{name: "no Host", data: "GET / HTTP/1.1\r\nX-Test: 1\r\n\r\n", wantErr: true},
+		{name: "bad header line", data: "GET / HTTP/1.1\r\nHost example.com\r\n\r\n", wantErr: true},
+		{name: "Burp export without items", data: `<?xml version="1.0"?><items></items>`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		req, err := Parse([]byte(tt.data))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		if req.Method != tt.method || req.Origin != tt.origin || req.Target != tt.target {
+			t.Errorf("%s: got %s %s%s, want %s %s%s", tt.name,
+				req.Method, req.Origin, req.Target, tt.method, tt.origin, tt.target)
+		}
+		if !reflect.DeepEqual(req.Headers, tt.headers) {
+			t.Errorf("%s: headers = %v, want %v", tt.name, req.Headers, tt.headers)
+		}
+		if string(req.Body) != tt.body {
+			t.Errorf("%s: body = %q, want %q", tt.name, req.Body, tt.body)
+		}
+	}
+}
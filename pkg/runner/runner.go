@@ -3,12 +3,17 @@ package runner
 import (
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/ibrahimsql/bypass403/pkg/bypass"
 	"github.com/ibrahimsql/bypass403/pkg/config"
 	"github.com/ibrahimsql/bypass403/pkg/http"
+	"github.com/ibrahimsql/bypass403/pkg/matcher"
 	"github.com/ibrahimsql/bypass403/pkg/output"
+	"github.com/ibrahimsql/bypass403/pkg/snippet"
 	"github.com/ibrahimsql/bypass403/pkg/useragent"
 	"github.com/ibrahimsql/bypass403/pkg/utils"
 )
@@ -17,6 +22,9 @@ import (
 type Runner struct {
 	config *config.Config
 	client *http.Client
+	rules  *matcher.Rules
+	// formats are the reproduction snippet formats shown and written
+	formats []string
 }
 
 // New creates a new Runner instance
@@ -28,8 +36,50 @@ func New(cfg *config.Config) *Runner {
 
 // Run executes the bypass techniques
 func (r *Runner) Run() {
+	// Route requests through the upstream proxy, if any
+	proxy, err := r.config.ProxyConfig()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
 	// Initialize HTTP client
-	r.client = http.NewClient(r.config.Timeout, r.config.UserAgent)
+	r.client, err = r.newClient(proxy)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	// Until the baseline is known only 429 counts as throttling, since a
+	// target may well block with 503
+	retryPolicy := r.config.RetryPolicy()
+	blockPolicy := retryPolicy
+	blockPolicy.Statuses = []int{429}
+	r.client.SetRetryPolicy(blockPolicy)
+
+	// The -auth credentials, also sent by the techniques themselves for the
+	// fixed Basic and Bearer schemes
+	auth, err := r.config.AuthConfig()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	// Build the rules deciding what counts as a bypass
+	rules, err := r.config.Rules()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+	r.rules = rules
+
+	// Pick the reproduction snippets to emit
+	formats, err := r.config.SnippetFormats()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+	r.formats = formats
 
 	// Handle random user agent if enabled
 	if r.config.RandomUserAgent {
@@ -44,41 +94,145 @@ func (r *Runner) Run() {
 		}
 	}
 
-	// Verify the URL returns 403
-	if err := http.VerifyURL(r.config.URL, r.client); err != nil {
-		fmt.Printf("Warning: %s. Continue anyway? (y/n): ", err)
-		var answer string
-		fmt.Scanln(&answer)
-		if answer != "y" && answer != "Y" {
-			os.Exit(0)
-		}
+	// Load the targets from -u and the target list
+	targets, skipped, err := r.config.Targets()
+	for _, invalid := range skipped {
+		fmt.Printf("Warning: skipping %s\n", invalid)
+	}
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	// Load the request file every request is built from, if given
+	template, err := r.config.Template()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+	if template != nil {
+		fmt.Printf("Using %s %s from %s as the base request\n", template.Method, template.Target, r.config.RequestFile)
 	}
 
-	// Initialize bypass configuration
+	// Global headers and cookies, sent with every request
+	headers, err := r.config.RequestHeaders()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	// Initialize bypass configuration, completed per target with its URL and baseline
 	bypassConfig := bypass.Config{
-		URL:          r.config.URL,
 		UserAgent:    r.config.UserAgent,
 		WordlistPath: r.config.WordlistPath,
 		Verbose:      r.config.Verbose,
 		RandomUA:     r.config.RandomUserAgent,
+
+		OriginIPsPath: r.config.OriginIPsPath,
+		Headers:       headers,
+		Auth:          auth,
+		Template:      template,
+	}
+
+	// Check what each target answers and keep those the preflight policy scans
+	targets, preflights, failed := r.preflight(targets, bypassConfig)
+	if len(targets) == 0 {
+		fmt.Println("No targets left to scan")
+		if failed {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// Capture the blocked response every attempt is compared against
+	targets, baselines := r.captureBaselines(targets, bypassConfig)
+	if len(targets) == 0 {
+		fmt.Println("Error: no baseline could be captured")
+		os.Exit(1)
+	}
+
+	// A target blocking with 503 answers the techniques that way too, so for
+	// its origin that is the block rather than throttling. Every other
+	// target counts 503 as throttling again.
+	for _, baseline := range baselines {
+		r.client.SetBlockStatus(baseline.Request.Origin, baseline.StatusCode)
 	}
+	r.client.SetRetryPolicy(retryPolicy)
 
-	fmt.Printf("Starting 403 bypass attempts on %s\n", r.config.URL)
+	for _, target := range targets {
+		baseline := baselines[target]
+		fmt.Printf("Starting bypass attempts on %s\n", target)
+		fmt.Printf("Baseline: %d, %d bytes, %d words, %d lines",
+			baseline.StatusCode, baseline.ContentLength, baseline.Words, baseline.Lines)
+		if baseline.Title != "" {
+			fmt.Printf(", title %q", baseline.Title)
+		}
+		if baseline.Dynamic {
+			fmt.Print(" (dynamic)")
+		}
+		fmt.Printf("\nBlock signature: %s\n", baseline.Signature)
+	}
 	fmt.Println("============================================")
 
-	// Setup concurrency handling
-	var wg sync.WaitGroup
+	// Stream every attempt as JSON Lines if requested
+	var jsonl *output.JSONLWriter
+	if r.config.JSONLOutput != "" {
+		jsonl, err = output.NewJSONLWriter(r.config.JSONLOutput)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			os.Exit(1)
+		}
+		defer jsonl.Close()
+	}
+
+	// Setup concurrency handling: every request of every target goes through
+	// one shared pool, so -t is the budget for the whole scan
 	resultChan := make(chan bypass.Result)
-	semaphore := make(chan struct{}, r.config.Threads)
+	done := make(chan struct{})
+	sched := newScheduler(r.client, r.config.Threads, r.config.HostThreads, resultChan, r.config.Verbose)
 
 	// Process results in background
-	var successfulResults []bypass.Result
+	var successfulResults, allResults, hits []bypass.Result
+	throttled := 0
 	go func() {
+		defer close(done)
 		for result := range resultChan {
-			if result.StatusCode != 403 && result.StatusCode != 0 && result.StatusCode != 404 {
-				fmt.Printf("[+] BYPASS FOUND! %s (%d) - Technique: %s/%s\n",
-					result.URL, result.StatusCode, result.Technique, result.Method)
+			baselines[result.Target].Classify(&result)
+			result.Matched = r.rules.Match(result)
+
+			// Only bypasses keep their full body and raw response, for the exporters
+			if !result.Matched {
+				result.Response.Body = nil
+				result.Response.Raw = nil
+			}
+
+			// The reports leave the user's credentials out unless asked not
+			// to; only the replay proxy gets the requests as they were sent
+			sent := result
+			if !r.config.NoRedact {
+				result.Request = bypassConfig.Redact(result.Request)
+			}
+
+			if jsonl != nil {
+				if err := jsonl.Write(result); err != nil && r.config.Verbose {
+					fmt.Printf("Warning: %s\n", err)
+				}
+			}
+			if r.config.JSONOutput != "" || r.config.HTMLOutput != "" || r.config.HAROutput != "" {
+				allResults = append(allResults, result)
+			}
+
+			if result.Throttled {
+				throttled++
+				if r.config.Verbose {
+					fmt.Printf("[!] throttled: %s - Technique: %s/%s\n",
+						result.URL, result.Technique, result.Method)
+				}
+			} else if result.IsBypass() {
+				fmt.Printf("[+] BYPASS FOUND! %s (%d) - Technique: %s/%s [confidence %d%%]\n",
+					result.URL, result.StatusCode, result.Technique, result.Method, result.Confidence)
 				successfulResults = append(successfulResults, result)
+				hits = append(hits, sent)
 
 				// Save successful bypass to separate file
 				err := utils.SaveForbiddenBypass(result.URL)
@@ -86,64 +240,373 @@ func (r *Runner) Run() {
 					fmt.Printf("Warning: Could not save bypass to file: %s\n", err)
 				}
 			} else if r.config.Verbose {
-				fmt.Printf("[-] Failed: %s (%d) - Technique: %s/%s\n",
-					result.URL, result.StatusCode, result.Technique, result.Method)
+				fmt.Printf("[-] %s: %s (%d, %d bytes) - Technique: %s/%s\n",
+					result.Classification, result.URL, result.StatusCode, result.ContentLength,
+					result.Technique, result.Method)
 			}
 		}
 	}()
 
-	// Run selected techniques
-	techniques := bypass.GetTechniques()
-	for _, technique := range techniques {
-		if r.shouldRunTechnique(technique.Category) {
-			wg.Add(1)
-			semaphore <- struct{}{} // Acquire semaphore
+	// Queue the attempts of the selected techniques
+	scan := output.Scan{
+		Tool:      "bypass403",
+		Version:   utils.GetVersion(),
+		Targets:   targets,
+		Started:   time.Now(),
+		Baselines: r.reportBaselines(baselines, bypassConfig),
 
-			go func(t bypass.Technique) {
-				defer wg.Done()
-				defer func() { <-semaphore }() // Release semaphore
+		Preflights: preflights,
+	}
+	var techniques []bypass.Technique
+	for _, t := range bypass.GetTechniques() {
+		if r.shouldRunTechnique(t.Category) {
+			techniques = append(techniques, t)
+			scan.Categories = append(scan.Categories, t.Category)
+		}
+	}
 
-				if r.config.Verbose {
-					fmt.Printf("Trying %s techniques...\n", t.Name)
-				}
+	for _, target := range targets {
+		targetConfig := bypassConfig
+		targetConfig.URL = target
+		targetConfig.Baseline = baselines[target]
+		sched.add(target, r.attempts(target, targetConfig, techniques))
+	}
 
-				// Use a new random user agent for each technique if enabled
-				if r.config.RandomUserAgent {
-					if r.config.UserAgentType != "" {
-						bypassConfig.UserAgent = useragent.GetRandomByCategory(r.config.UserAgentType)
-					} else {
-						bypassConfig.UserAgent = useragent.GetRandom()
-					}
-				}
+	// Send every attempt, including follow-ups
+	sched.run()
+	close(resultChan)
+	<-done
+	scan.Finished = time.Now()
 
-				// Use the standard http.Client from our custom client
-				results, err := t.Test(r.config.URL, r.client.Client, bypassConfig)
-				if err != nil && r.config.Verbose {
-					fmt.Printf("Error with %s technique: %s\n", t.Name, err)
-				}
+	// Show summary
+	r.showSummary(successfulResults, targets)
+	if throttled > 0 {
+		fmt.Printf("\nWarning: %d attempts were still throttled after %d retries and are inconclusive.\n",
+			throttled, r.config.Retries)
+		fmt.Println("Consider a slower timing profile, e.g. -profile polite")
+	}
+
+	// Write the JSON report if requested
+	if r.config.JSONOutput != "" {
+		if err := output.GenerateJSON(scan, allResults, r.config.JSONOutput); err != nil {
+			fmt.Printf("Error generating JSON output: %s\n", err)
+		} else {
+			fmt.Printf("JSON report saved to %s\n", r.config.JSONOutput)
+		}
+	}
+	if jsonl != nil {
+		fmt.Printf("JSON Lines output saved to %s\n", r.config.JSONLOutput)
+	}
+
+	// Write the SARIF log if requested, even without findings so CI clears old alerts
+	if r.config.SARIFOutput != "" {
+		if err := output.GenerateSARIF(scan, successfulResults, r.config.SARIFOutput); err != nil {
+			fmt.Printf("Error generating SARIF output: %s\n", err)
+		} else {
+			fmt.Printf("SARIF report saved to %s\n", r.config.SARIFOutput)
+		}
+	}
+
+	// Write the HTML report if requested
+	if r.config.HTMLOutput != "" {
+		if err := output.GenerateHTML(scan, allResults, r.formats, r.config.HTMLOutput); err != nil {
+			fmt.Printf("Error generating HTML report: %s\n", err)
+		} else {
+			fmt.Printf("HTML report saved to %s\n", r.config.HTMLOutput)
+		}
+	}
+
+	// Write the Markdown report if requested
+	if r.config.ReportOutput != "" {
+		if err := output.GenerateMarkdown(scan, successfulResults, r.formats, r.config.ReportOutput); err != nil {
+			fmt.Printf("Error generating Markdown report: %s\n", err)
+		} else {
+			fmt.Printf("Markdown report saved to %s\n", r.config.ReportOutput)
+		}
+	}
+
+	// Write nuclei templates for regression runs if requested
+	if r.config.NucleiOutput != "" {
+		if n, err := output.GenerateNuclei(scan, successfulResults, r.config.NucleiOutput); err != nil {
+			fmt.Printf("Error generating nuclei templates: %s\n", err)
+		} else {
+			fmt.Printf("%d nuclei templates saved to %s\n", n, r.config.NucleiOutput)
+		}
+	}
+
+	// Write the HAR archive if requested
+	if r.config.HAROutput != "" {
+		if err := output.GenerateHAR(scan, allResults, r.config.HAROutput, r.config.HARBypassesOnly); err != nil {
+			fmt.Printf("Error generating HAR archive: %s\n", err)
+		} else {
+			fmt.Printf("HAR archive saved to %s\n", r.config.HAROutput)
+		}
+	}
 
-				for _, result := range results {
-					resultChan <- result
+	// Export the bypasses for Burp Suite if requested, even without any so a stale
+	// export from an earlier scan is never left behind
+	if r.config.BurpOutput != "" {
+		if err := output.GenerateBurpItems(successfulResults, r.config.BurpOutput); err != nil {
+			fmt.Printf("Error generating Burp Suite items: %s\n", err)
+		} else {
+			fmt.Printf("Burp Suite items saved to %s\n", r.config.BurpOutput)
+		}
+	}
+
+	// Send the hits through the replay proxy for manual follow-up
+	if r.config.ReplayProxy != "" && len(hits) > 0 {
+		r.replayHits(hits)
+	}
+}
+
+// attempts returns the generator of the attempts of the techniques on a
+// target, one technique at a time, for the scheduler to call as it needs them.
+// It returns nil once every technique has run.
+func (r *Runner) attempts(target string, config bypass.Config, techniques []bypass.Technique) func() []bypass.Attempt {
+	next := 0
+	return func() []bypass.Attempt {
+		for next < len(techniques) {
+			t := techniques[next]
+			next++
+
+			if r.config.Verbose {
+				fmt.Printf("Trying %s techniques on %s...\n", t.Name, target)
+			}
+
+			// Use a new random user agent for each technique if enabled
+			if r.config.RandomUserAgent {
+				if r.config.UserAgentType != "" {
+					config.UserAgent = useragent.GetRandomByCategory(r.config.UserAgentType)
+				} else {
+					config.UserAgent = useragent.GetRandom()
 				}
-			}(technique)
+			}
+
+			attempts, err := t.Test(target, config)
+			if err != nil && r.config.Verbose {
+				fmt.Printf("Error with %s technique on %s: %s\n", t.Name, target, err)
+			}
+
+			for i := range attempts {
+				attempts[i].Category = t.Category
+				attempts[i].Target = target
+			}
+			if len(attempts) > 0 {
+				return attempts
+			}
+		}
+		return nil
+	}
+}
+
+// newClient creates an HTTP client with the scan's timing, throttling,
+// address, TLS, HTTP/2 and authentication settings, sending its requests
+// through proxy
+func (r *Runner) newClient(proxy http.ProxyConfig) (*http.Client, error) {
+	client := http.NewClient(r.config.Timeout, r.config.UserAgent)
+
+	// Apply the request timing to every request the techniques make
+	rateLimit, err := r.config.RateLimit()
+	if err != nil {
+		return nil, err
+	}
+	client.SetRateLimit(rateLimit)
+
+	// Back off when the target throttles, and tell the user about it
+	client.SetRetryPolicy(r.config.RetryPolicy())
+	client.OnEvent = func(message string) {
+		fmt.Printf("[!] %s\n", message)
+	}
+
+	client.SetProxy(proxy)
+
+	// Connect to other addresses than the target's own, curl --resolve style
+	resolve, err := r.config.ResolveMap()
+	if err != nil {
+		return nil, err
+	}
+	client.SetResolve(resolve)
+	client.SetServerName(r.config.SNI)
+
+	// Speak HTTP/2 to the target if asked to
+	http2Mode, err := http.ParseHTTP2Mode(r.config.HTTP2)
+	if err != nil {
+		return nil, err
+	}
+	client.SetHTTP2(http2Mode)
+
+	// Verify certificates and present a client certificate if asked to
+	if err := client.SetTLS(r.config.TLSOptions()); err != nil {
+		return nil, err
+	}
+
+	// Answer Digest and NTLM challenges with the -auth credentials
+	auth, err := r.config.AuthConfig()
+	if err != nil {
+		return nil, err
+	}
+	client.SetAuth(auth)
+
+	return client, nil
+}
+
+// reportBaselines returns the baselines as the reports show them, with the
+// user's credentials redacted from their requests unless -no-redact is set
+func (r *Runner) reportBaselines(baselines map[string]*bypass.Baseline, config bypass.Config) map[string]*bypass.Baseline {
+	if r.config.NoRedact {
+		return baselines
+	}
+
+	redacted := make(map[string]*bypass.Baseline, len(baselines))
+	for target, baseline := range baselines {
+		b := *baseline
+		b.Request = config.Redact(b.Request)
+		redacted[target] = &b
+	}
+	return redacted
+}
+
+// preflight checks every target, a few at a time within the -t budget, and
+// reports what it found. Targets that don't answer 401 or 403 are skipped or
+// kept according to the preflight policy, or, with -ask on a terminal, as the
+// user decides. It returns the targets left to scan, in order, their preflights,
+// and whether any target couldn't be reached.
+func (r *Runner) preflight(targets []string, config bypass.Config) ([]string, map[string]*bypass.Preflight, bool) {
+	preflights := make(map[string]*bypass.Preflight)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, r.config.Threads)
+
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			targetConfig := config
+			targetConfig.URL = target
+			p := bypass.RunPreflight(target, r.client, targetConfig)
+
+			mu.Lock()
+			preflights[target] = p
+			mu.Unlock()
+		}(target)
+	}
+	wg.Wait()
+
+	var scanned []string
+	failed := false
+	ask := r.config.Ask && interactive()
+	for _, target := range targets {
+		p := preflights[target]
+		fmt.Printf("[preflight] %s: %s\n", target, p.Summary())
+		if r.config.Verbose {
+			for _, line := range p.Details() {
+				fmt.Printf("            %s\n", line)
+			}
 		}
+
+		ok, reason := p.Scan(r.config.Preflight)
+		if p.Error != "" {
+			failed = true
+		} else if !ok && ask {
+			ok = confirm(fmt.Sprintf("%s: %s. Scan it anyway?", target, reason))
+		}
+		if !ok {
+			fmt.Printf("Warning: skipping %s: %s\n", target, reason)
+			delete(preflights, target)
+			continue
+		}
+		scanned = append(scanned, target)
 	}
+	return scanned, preflights, failed
+}
+
+// captureBaselines captures the baseline of every target, a few at a time
+// within the -t budget. Targets whose baseline fails are skipped with a
+// warning. It returns the targets left to scan, in order.
+func (r *Runner) captureBaselines(targets []string, config bypass.Config) ([]string, map[string]*bypass.Baseline) {
+	baselines := make(map[string]*bypass.Baseline)
+	errs := make(map[string]error)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, r.config.Threads)
+
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			targetConfig := config
+			targetConfig.URL = target
+			baseline, err := bypass.CaptureBaseline(target, r.client, targetConfig)
 
-	// Wait for all tests to complete
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs[target] = err
+				return
+			}
+			baselines[target] = baseline
+		}(target)
+	}
 	wg.Wait()
-	close(resultChan)
 
-	// Show summary
-	r.showSummary(successfulResults)
+	var scanned []string
+	for _, target := range targets {
+		if err := errs[target]; err != nil {
+			fmt.Printf("Warning: skipping %s: %s\n", target, err)
+			continue
+		}
+		scanned = append(scanned, target)
+	}
+	return scanned, baselines
+}
 
-	// Generate Burp Suite project if requested
-	if r.config.BurpOutput != "" && len(successfulResults) > 0 {
-		if err := output.GenerateBurpSuiteProject(successfulResults, r.config.BurpOutput); err != nil {
-			fmt.Printf("Error generating Burp Suite project: %s\n", err)
-		} else {
-			fmt.Printf("Burp Suite project saved to %s\n", r.config.BurpOutput)
+// interactive reports whether standard input is a terminal, so a prompt can be answered
+func interactive() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// confirm asks a yes/no question on the terminal
+func confirm(question string) bool {
+	fmt.Printf("%s (y/n): ", question)
+	var answer string
+	fmt.Scanln(&answer)
+	return answer == "y" || answer == "Y"
+}
+
+// replayHits sends the confirmed bypasses once more through the replay proxy,
+// so they land in e.g. Burp's proxy history without the rest of the scan
+func (r *Runner) replayHits(results []bypass.Result) {
+	proxy, err := http.ParseProxy(r.config.ReplayProxy)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		return
+	}
+
+	client, err := r.newClient(http.ProxyConfig{HTTP: proxy, HTTPS: proxy})
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		return
+	}
+
+	replayed := 0
+	for _, result := range results {
+		if result.Request == nil {
+			continue
+		}
+		if _, err := client.Send(result.Request); err != nil {
+			fmt.Printf("[!] Replaying %s through %s failed: %s\n", result.URL, proxy.Host, err)
+			continue
 		}
+		replayed++
 	}
+	fmt.Printf("Replayed %d of %d bypasses through %s\n", replayed, len(results), proxy.Host)
 }
 
 // shouldRunTechnique determines if a technique should be run based on the configuration
@@ -159,35 +622,50 @@ func (r *Runner) shouldRunTechnique(techniqueCategory string) bool {
 	return utils.ContainsCategory(techniqueCategory, r.config.Category)
 }
 
-// showSummary displays a summary of the results
-func (r *Runner) showSummary(results []bypass.Result) {
+// showSummary displays a summary of the results, grouped by target
+func (r *Runner) showSummary(results []bypass.Result, targets []string) {
 	fmt.Println("\n============= RESULTS =============")
 	if len(results) > 0 {
+		// Most convincing bypasses first, within each target
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].Confidence > results[j].Confidence
+		})
+		groups := bypass.GroupByTarget(targets, results)
+
+		// List them target by target, and number them in that order
 		fmt.Printf("Found %d potential bypasses:\n", len(results))
-		for i, result := range results {
-			fmt.Printf("%d. %s (%d) - Technique: %s/%s\n",
-				i+1, result.URL, result.StatusCode, result.Technique, result.Method)
+		results = nil
+		for _, group := range groups {
+			if len(groups) > 1 {
+				fmt.Printf("\n%s: %d\n", group.Target, len(group.Results))
+			}
+			for _, result := range group.Results {
+				results = append(results, result)
+				fmt.Printf("%d. %s (%d) - Technique: %s/%s [confidence %d%%]\n",
+					len(results), result.URL, result.StatusCode, result.Technique, result.Method, result.Confidence)
+			}
 		}
 
 		// Save results to file if requested
 		if r.config.OutputFile != "" {
-			utils.SaveResultsToFile(results, r.config.OutputFile, r.config.URL)
+			utils.SaveResultsToFile(results, r.config.OutputFile, targets, r.formats)
 		}
 
-		fmt.Println("\nSuccessful bypasses have been saved to forbidden_bypass.txt")
+		fmt.Println("\nBypassed URLs have been appended to forbidden_bypass.txt")
+		fmt.Println("Use -o, -json, -jsonl, -html, -report, -sarif, -har, -burp or -nuclei to keep the full evidence")
 		fmt.Println("\nTips:")
 		fmt.Println("* Try combined techniques for better results")
 		fmt.Println("* Check each bypass manually to confirm access")
 		fmt.Println("* Different status codes may indicate different levels of access")
 		fmt.Println("* Consider using a custom wordlist with `-w` option")
 
-		// Show example curl command for the first successful bypass
+		// Show how to reproduce the first successful bypass
 		if len(results) > 0 {
-			fmt.Println("\nExample curl command for first successful bypass:")
-			fmt.Println(utils.GenerateCurlCommand(results[0]))
-
-			fmt.Println("\nExample Python request for first successful bypass:")
-			fmt.Println(utils.GeneratePythonRequest(results[0]))
+			req := snippet.ForResult(results[0])
+			for _, format := range r.formats {
+				fmt.Printf("\nExample %s for first successful bypass:\n", snippet.Label(format))
+				fmt.Println(strings.TrimRight(snippet.Generate(format, req), "\r\n"))
+			}
 		}
 	} else {
 		fmt.Println("No bypasses found for the given URL.")
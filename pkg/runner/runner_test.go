@@ -0,0 +1,133 @@
+package runner
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ibrahimsql/bypass403/pkg/config"
+)
+
+// secrets are the credentials the redaction test scans with
+var secrets = []string{"s3cret-key", "s3cret-session", base64.StdEncoding.EncodeToString([]byte("admin:s3cret-pass"))}
+
+// scanWithSecrets runs a Headers scan with -H, -b and -auth basic against a
+// target that any X-Original-URL lets through, writing every report into a
+// new directory, and returns the contents of each report by name
+func scanWithSecrets(t *testing.T, noRedact bool) map[string]string {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.Header.Get("X-Original-URL") != "" {
+			w.Write([]byte("<title>Admin</title>Welcome to the admin panel"))
+			return
+		}
+		w.WriteHeader(nethttp.StatusForbidden)
+		w.Write([]byte("<title>Error</title>Access denied"))
+	}))
+	defer server.Close()
+
+	// forbidden_bypass.txt is written to the working directory
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	cfg := config.NewDefaultConfig()
+	cfg.URL = server.URL + "/admin"
+	cfg.Category = "Headers"
+	cfg.Headers = []string{"X-Api-Key: s3cret-key"}
+	cfg.Cookie = "session=s3cret-session"
+	cfg.Auth = "basic"
+	cfg.AuthCredentials = "admin:s3cret-pass"
+	cfg.NoRedact = noRedact
+	cfg.Snippets = "all"
+	cfg.OutputFile = filepath.Join(dir, "results.txt")
+	cfg.JSONOutput = filepath.Join(dir, "results.json")
+	cfg.JSONLOutput = filepath.Join(dir, "attempts.jsonl")
+	cfg.SARIFOutput = filepath.Join(dir, "results.sarif")
+	cfg.HTMLOutput = filepath.Join(dir, "report.html")
+	cfg.HAROutput = filepath.Join(dir, "scan.har")
+	cfg.ReportOutput = filepath.Join(dir, "report.md")
+	cfg.NucleiOutput = filepath.Join(dir, "nuclei")
+	cfg.BurpOutput = filepath.Join(dir, "items.xml")
+
+	New(cfg).Run()
+
+	reports := make(map[string]string)
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name, _ := filepath.Rel(dir, path)
+		reports[filepath.ToSlash(name)] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Burp items carry the requests base64 encoded
+	var items struct {
+		Requests []string `xml:"item>request"`
+	}
+	if err := xml.Unmarshal([]byte(reports["items.xml"]), &items); err != nil {
+		t.Fatalf("Burp items: %s", err)
+	}
+	if len(items.Requests) == 0 {
+		t.Fatalf("scan found no bypass to report")
+	}
+	for _, request := range items.Requests {
+		raw, err := base64.StdEncoding.DecodeString(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reports["items.xml"] += "\n" + string(raw)
+	}
+
+	return reports
+}
+
+func TestReportsRedactCredentials(t *testing.T) {
+	reports := scanWithSecrets(t, false)
+
+	for _, name := range []string{"results.txt", "results.json", "attempts.jsonl", "results.sarif", "report.html", "scan.har", "report.md", "items.xml"} {
+		if _, ok := reports[name]; !ok {
+			t.Errorf("no %s was written", name)
+		}
+	}
+	for name, report := range reports {
+		for _, secret := range secrets {
+			if strings.Contains(report, secret) {
+				t.Errorf("%s contains the credential %q", name, secret)
+			}
+		}
+	}
+	if !strings.Contains(reports["results.json"], "[REDACTED]") {
+		t.Errorf("results.json doesn't show where credentials were redacted")
+	}
+}
+
+func TestReportsNoRedact(t *testing.T) {
+	reports := scanWithSecrets(t, true)
+
+	for _, name := range []string{"results.json", "scan.har", "items.xml"} {
+		for _, secret := range secrets {
+			if !strings.Contains(reports[name], secret) {
+				t.Errorf("%s doesn't contain %q with -no-redact", name, secret)
+			}
+		}
+	}
+}
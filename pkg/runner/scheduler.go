@@ -0,0 +1,215 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ibrahimsql/bypass403/pkg/bypass"
+	"github.com/ibrahimsql/bypass403/pkg/http"
+)
+
+// scheduler sends the attempts of every technique across a shared pool of
+// workers, so -t bounds the number of requests in flight rather than the
+// number of techniques, and no single host gets more than perHost of them.
+// The attempts of each target are generated one technique at a time as the
+// workers need them, and the targets take turns, so a long list of targets
+// never sits in memory at once and a slow host doesn't hold up the others.
+type scheduler struct {
+	client  *http.Client
+	results chan<- bypass.Result
+	verbose bool
+	workers int
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	// targets are the queues of the targets, taken in turn from next
+	targets []*targetQueue
+	byURL   map[string]*targetQueue
+	next    int
+	// active counts the requests in flight to each host, at most perHost,
+	// keyed by hostKey
+	active  map[string]int
+	perHost int
+	// running counts the attempts being sent or generated
+	running int
+}
+
+// targetQueue holds the attempts of one target that are ready to be sent and
+// generates more of them once they run out
+type targetQueue struct {
+	// origin is the hostKey of the target
+	origin   string
+	attempts []bypass.Attempt
+	// generate returns the attempts of the target's next technique, or nil
+	// once every technique has run
+	generate   func() []bypass.Attempt
+	generating bool
+}
+
+// newScheduler creates a pool of workers that send attempts and deliver their results
+func newScheduler(client *http.Client, workers, perHost int, results chan<- bypass.Result, verbose bool) *scheduler {
+	if perHost < 1 || perHost > workers {
+		perHost = workers
+	}
+
+	s := &scheduler{
+		client:  client,
+		results: results,
+		verbose: verbose,
+		workers: workers,
+		byURL:   make(map[string]*targetQueue),
+		active:  make(map[string]int),
+		perHost: perHost,
+	}
+	s.cond = sync.NewCond(&s.mu)
+
+	return s
+}
+
+// add queues a target whose attempts generate returns, one batch at a time
+func (s *scheduler) add(target string, generate func() []bypass.Attempt) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q := &targetQueue{origin: hostKey(target), generate: generate}
+	s.targets = append(s.targets, q)
+	s.byURL[target] = q
+}
+
+// run starts the workers and blocks until every attempt of every target and
+// their follow-ups have been sent
+func (s *scheduler) run() {
+	var wg sync.WaitGroup
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.work()
+		}()
+	}
+	wg.Wait()
+}
+
+// work sends attempts until there are none left to send or generate
+func (s *scheduler) work() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		q := s.pick()
+		if q != nil && len(q.attempts) > 0 {
+			attempt := q.attempts[0]
+			q.attempts[0] = bypass.Attempt{}
+			q.attempts = q.attempts[1:]
+
+			origin := hostKey(attempt.Request.Origin)
+			s.active[origin]++
+			s.running++
+			s.mu.Unlock()
+
+			followUps := s.send(attempt)
+
+			s.mu.Lock()
+			s.active[origin]--
+			s.running--
+			if q := s.byURL[attempt.Target]; q != nil {
+				q.attempts = append(q.attempts, followUps...)
+			}
+			s.cond.Broadcast()
+			continue
+		}
+
+		if q != nil {
+			q.generating = true
+			s.running++
+			s.mu.Unlock()
+
+			attempts := q.generate()
+
+			s.mu.Lock()
+			q.generating = false
+			s.running--
+			if attempts == nil {
+				q.generate = nil
+			}
+			q.attempts = append(q.attempts, attempts...)
+			s.cond.Broadcast()
+			continue
+		}
+
+		if s.running == 0 {
+			// Nothing queued, nothing left to generate and nothing in flight
+			// that could bring follow-ups
+			s.cond.Broadcast()
+			return
+		}
+		s.cond.Wait()
+	}
+}
+
+// pick goes round the targets, from the one whose turn it is, for one that
+// can move on: one with an attempt whose host has a free slot, or one whose
+// queue has run dry with techniques left to generate attempts for. The slot
+// is checked first, so a worker never holds an attempt while it waits for
+// its host, and attempts are only generated when they can be sent. Taking an
+// attempt passes the turn to the next target; generating keeps it, so the
+// target sends its first new attempt before the others go again.
+func (s *scheduler) pick() *targetQueue {
+	for i := range s.targets {
+		idx := (s.next + i) % len(s.targets)
+		q := s.targets[idx]
+
+		if len(q.attempts) > 0 {
+			if s.active[hostKey(q.attempts[0].Request.Origin)] < s.perHost {
+				s.next = (idx + 1) % len(s.targets)
+				return q
+			}
+			continue
+		}
+		if q.generate != nil && !q.generating && s.active[q.origin] < s.perHost {
+			s.next = idx
+			return q
+		}
+	}
+	return nil
+}
+
+// hostKey returns the key of the host slots of a URL or origin. Targets and
+// their requests are both keyed by their origin as requests carry it, host
+// names being case-insensitive, so "HTTPS://Example.com/x" shares its slots
+// with the requests made for it.
+func hostKey(rawURL string) string {
+	origin, err := http.Origin(rawURL)
+	if err != nil {
+		origin = rawURL
+	}
+	return strings.ToLower(origin)
+}
+
+// send sends a single attempt, delivers its result and returns the follow-ups
+// it asks for
+func (s *scheduler) send(attempt bypass.Attempt) []bypass.Attempt {
+	result, err := bypass.Execute(s.client, attempt)
+	if err != nil {
+		if s.verbose {
+			fmt.Printf("[!] Error: %s - Technique: %s/%s: %s\n",
+				attempt.Request.URL(), attempt.Technique, attempt.Request.Method, err)
+		}
+		return nil
+	}
+
+	s.results <- result
+
+	if attempt.FollowUp == nil {
+		return nil
+	}
+	followUps := attempt.FollowUp(result)
+	for i := range followUps {
+		if followUps[i].Category == "" {
+			followUps[i].Category = attempt.Category
+		}
+		followUps[i].Target = attempt.Target
+	}
+	return followUps
+}
@@ -0,0 +1,171 @@
+package snippet
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ibrahimsql/bypass403/pkg/http"
+)
+
+// python builds a Python requests script. The URL is set on the prepared
+// request after preparation, because preparing it would resolve dot segments
+// and re-quote the path.
+func python(req *http.Request) string {
+	var out strings.Builder
+
+	url := req.URL()
+	if !originForm(req) {
+		out.WriteString("# requests can't send the request-target " + strconv.Quote(req.Target) + " as-is\n")
+		url = req.Origin + "/" + req.Target
+	}
+	out.WriteString(http2Note("requests", "#", true, req))
+	if names := repeated(req); len(names) > 0 {
+		out.WriteString("# requests sends each header once; repeated in the original: " + strings.Join(names, ", ") + "\n")
+	}
+
+	out.WriteString("import requests\nimport urllib3\n\n")
+	out.WriteString("urllib3.disable_warnings()\n\n")
+
+	out.WriteString("headers = {\n")
+	for _, h := range headers(req) {
+		out.WriteString(fmt.Sprintf("    %s: %s,\n", pyString(h.Name), pyString(h.Value)))
+	}
+	out.WriteString("}\n\n")
+
+	out.WriteString(fmt.Sprintf("request = requests.Request(%s, %s, headers=headers", pyString(req.Method), pyString(url)))
+	if len(req.Body) > 0 {
+		out.WriteString(", data=" + pyBytes(req.Body))
+	}
+	out.WriteString(").prepare()\n")
+	out.WriteString(fmt.Sprintf("request.url = %s\n\n", pyString(url)))
+
+	out.WriteString("response = requests.Session().send(request, allow_redirects=False, verify=False)\n")
+	out.WriteString("print(response.status_code)\n")
+	out.WriteString("print(response.text)\n")
+
+	return out.String()
+}
+
+// golang builds a Go program using net/http. The request-target is set as the
+// opaque URL so net/http writes it without escaping or cleaning it.
+func golang(req *http.Request) string {
+	var out strings.Builder
+
+	// net/http only speaks HTTP/2 negotiated over TLS
+	h2 := req.Proto == http.ProtoHTTP2 && strings.HasPrefix(req.Origin, "https://")
+	out.WriteString(http2Note("net/http", "//", !h2, req))
+	out.WriteString("package main\n\n")
+	out.WriteString("import (\n")
+	if len(req.Body) > 0 {
+		out.WriteString("\t\"bytes\"\n")
+	}
+	out.WriteString("\t\"crypto/tls\"\n\t\"fmt\"\n\t\"io\"\n\t\"net/http\"\n\t\"net/url\"\n)\n\n")
+
+	out.WriteString("func main() {\n")
+	body := "nil"
+	if len(req.Body) > 0 {
+		body = "bytes.NewReader([]byte(" + strconv.Quote(string(req.Body)) + "))"
+	}
+	out.WriteString(fmt.Sprintf("\treq, err := http.NewRequest(%s, %s, %s)\n", strconv.Quote(req.Method), strconv.Quote(req.Origin+"/"), body))
+	out.WriteString("\tif err != nil {\n\t\tpanic(err)\n\t}\n")
+
+	scheme, hostPort, _ := strings.Cut(req.Origin, "://")
+	opaque := req.Target
+	if strings.HasPrefix(opaque, "//") {
+		// net/http would read a leading "//" as an authority, so the target is
+		// sent in absolute form instead
+		out.WriteString("\t// net/http can't send a request-target starting with // as-is, so it is sent in absolute form\n")
+		opaque = "//" + hostPort + opaque
+	}
+	out.WriteString(fmt.Sprintf("\treq.URL = &url.URL{Scheme: %s, Host: %s, Opaque: %s}\n",
+		strconv.Quote(scheme), strconv.Quote(hostPort), strconv.Quote(opaque)))
+	if req.Proto == "HTTP/1.0" {
+		out.WriteString("\treq.Proto, req.ProtoMajor, req.ProtoMinor = \"HTTP/1.0\", 1, 0\n")
+	}
+
+	for _, h := range headers(req) {
+		if strings.EqualFold(h.Name, "Host") {
+			out.WriteString(fmt.Sprintf("\treq.Host = %s\n", strconv.Quote(h.Value)))
+			continue
+		}
+		// Assigning to the map keeps the header name exactly as sent
+		out.WriteString(fmt.Sprintf("\treq.Header[%s] = append(req.Header[%s], %s)\n",
+			strconv.Quote(h.Name), strconv.Quote(h.Name), strconv.Quote(h.Value)))
+	}
+	if !has(req, "User-Agent") {
+		// An empty User-Agent stops net/http from adding its own
+		out.WriteString("\treq.Header[\"User-Agent\"] = []string{\"\"}\n")
+	}
+
+	out.WriteString("\n\tclient := &http.Client{\n")
+	out.WriteString("\t\tTransport: &http.Transport{\n")
+	out.WriteString("\t\t\tTLSClientConfig:    &tls.Config{InsecureSkipVerify: true},\n")
+	out.WriteString("\t\t\tDisableCompression: true,\n")
+	if h2 {
+		out.WriteString("\t\t\tForceAttemptHTTP2:  true,\n")
+	}
+	out.WriteString("\t\t},\n")
+	out.WriteString("\t\tCheckRedirect: func(*http.Request, []*http.Request) error {\n")
+	out.WriteString("\t\t\treturn http.ErrUseLastResponse\n")
+	out.WriteString("\t\t},\n")
+	out.WriteString("\t}\n\n")
+
+	out.WriteString("\tresp, err := client.Do(req)\n")
+	out.WriteString("\tif err != nil {\n\t\tpanic(err)\n\t}\n")
+	out.WriteString("\tdefer resp.Body.Close()\n\n")
+	out.WriteString("\tbody, _ := io.ReadAll(resp.Body)\n")
+	out.WriteString("\tfmt.Println(resp.Status)\n")
+	out.WriteString("\tfmt.Println(string(body))\n")
+	out.WriteString("}\n")
+
+	return out.String()
+}
+
+// pyString writes s as a Python string literal
+func pyString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			b.WriteString(fmt.Sprintf(`\x%02x`, r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// pyBytes writes data as a Python bytes literal, so the body is sent byte-for-byte
+func pyBytes(data []byte) string {
+	var b strings.Builder
+	b.WriteString(`b"`)
+	for _, c := range data {
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c < 0x20 || c >= 0x7f:
+			b.WriteString(fmt.Sprintf(`\x%02x`, c))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,153 @@
+package snippet
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/ibrahimsql/bypass403/pkg/http"
+)
+
+// curl builds a curl command. --path-as-is keeps dot segments, and unusual
+// request-targets are passed with --request-target.
+func curl(req *http.Request) string {
+	cmd := http2Note("curl", "#", false, req) + "curl -k -s -i --path-as-is"
+	switch req.Proto {
+	case "HTTP/1.0":
+		cmd += " --http1.0"
+	case http.ProtoHTTP2:
+		if strings.HasPrefix(req.Origin, "https://") {
+			cmd += " --http2"
+		} else {
+			cmd += " --http2-prior-knowledge"
+		}
+	default:
+		cmd += " --http1.1"
+	}
+
+	if req.Method == "HEAD" {
+		// -X HEAD would make curl wait for a body that never comes
+		cmd += " --head"
+	} else {
+		cmd += " -X " + shellQuote(req.Method)
+	}
+
+	url := req.URL()
+	if !originForm(req) {
+		cmd += " --request-target " + shellQuote(req.Target)
+		url = req.Origin + "/"
+	}
+
+	if req.Address != "" {
+		// Connect where the request went, keeping the Host header and SNI
+		name, port := originHostPort(req)
+		if addrHost, addrPort, err := net.SplitHostPort(req.Address); err == nil {
+			if strings.Contains(addrHost, ":") {
+				addrHost = "[" + addrHost + "]"
+			}
+			cmd += " --connect-to " + shellQuote(name+":"+port+":"+addrHost+":"+addrPort)
+		}
+	}
+
+	for _, h := range headers(req) {
+		if h.Value == "" {
+			// "Name:" would remove the header, "Name;" sends it empty
+			cmd += " -H " + shellQuote(h.Name+";")
+		} else {
+			cmd += " -H " + shellQuote(h.Name+": "+h.Value)
+		}
+	}
+	// Drop the headers curl adds by default but the request didn't carry
+	defaults := []string{"User-Agent", "Accept"}
+	if len(req.Body) > 0 {
+		defaults = append(defaults, "Content-Type")
+	}
+	for _, name := range defaults {
+		if !has(req, name) {
+			cmd += " -H " + shellQuote(name+":")
+		}
+	}
+
+	if len(req.Body) > 0 {
+		cmd += " --data-binary " + shellQuote(string(req.Body))
+	}
+
+	return cmd + " " + shellQuote(url)
+}
+
+// httpie builds an HTTPie command. HTTPie can only send origin-form targets,
+// so anything else is sent as a path with a note.
+func httpie(req *http.Request) string {
+	var out strings.Builder
+
+	url := req.URL()
+	if !originForm(req) {
+		out.WriteString("# HTTPie can't send the request-target " + strconv.Quote(req.Target) + " as-is\n")
+		url = req.Origin + "/" + req.Target
+	}
+	out.WriteString(http2Note("HTTPie", "#", true, req))
+	if names := repeated(req); len(names) > 0 {
+		out.WriteString("# HTTPie sends each header once; repeated in the original: " + strings.Join(names, ", ") + "\n")
+	}
+
+	out.WriteString("http --path-as-is --verify=no --print=hb")
+	if len(req.Body) > 0 {
+		out.WriteString(" --raw " + shellQuote(string(req.Body)))
+	}
+	out.WriteString(" " + shellQuote(req.Method) + " " + shellQuote(url))
+
+	for _, h := range headers(req) {
+		if h.Value == "" {
+			out.WriteString(" " + shellQuote(h.Name+";"))
+		} else {
+			out.WriteString(" " + shellQuote(h.Name+":"+h.Value))
+		}
+	}
+	for _, name := range []string{"User-Agent", "Accept", "Accept-Encoding"} {
+		if !has(req, name) {
+			out.WriteString(" " + shellQuote(name+":"))
+		}
+	}
+
+	return out.String()
+}
+
+// ffuf builds an ffuf command that replays the request with the last path
+// segment as the FUZZ keyword. The wordlist holds the original segment, so the
+// command reproduces the bypass as-is and can be pointed at a real wordlist to
+// look for more content behind it. Targets without a segment fuzz the method.
+func ffuf(req *http.Request) string {
+	path, query := req.Target, ""
+	if idx := strings.Index(path, "?"); idx != -1 {
+		path, query = path[:idx], path[idx:]
+	}
+
+	method, target, word := req.Method, req.Target, req.Method
+	if idx := strings.LastIndex(path, "/"); idx < len(path)-1 {
+		target = path[:idx+1] + "FUZZ" + query
+		word = path[idx+1:]
+	} else {
+		method = "FUZZ"
+	}
+
+	var out strings.Builder
+	url := req.Origin + target
+	if !originForm(req) {
+		out.WriteString("# ffuf can't send the request-target " + strconv.Quote(req.Target) + " as-is\n")
+		url = req.Origin + "/" + target
+	}
+
+	out.WriteString(http2Note("ffuf", "#", true, req))
+	out.WriteString("ffuf -raw -k -mc all")
+	out.WriteString(" -w <(printf '%s\\n' " + shellQuote(word) + ")")
+	out.WriteString(" -X " + shellQuote(method))
+	for _, h := range headers(req) {
+		out.WriteString(" -H " + shellQuote(h.Name+": "+h.Value))
+	}
+	if len(req.Body) > 0 {
+		out.WriteString(" -d " + shellQuote(string(req.Body)))
+	}
+	out.WriteString(" -u " + shellQuote(url))
+
+	return out.String()
+}
@@ -0,0 +1,236 @@
+package snippet
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ibrahimsql/bypass403/pkg/bypass"
+	"github.com/ibrahimsql/bypass403/pkg/http"
+)
+
+// Snippet formats
+const (
+	Curl   = "curl"
+	Python = "python"
+	Raw    = "raw"
+	Go     = "go"
+	Ffuf   = "ffuf"
+	HTTPie = "httpie"
+)
+
+// Formats lists every supported format in display order
+var Formats = []string{Curl, Python, Raw, Go, Ffuf, HTTPie}
+
+// DefaultFormats are emitted when the user doesn't choose any
+var DefaultFormats = []string{Curl, Python}
+
+// labels are the display names of the formats
+var labels = map[string]string{
+	Curl:   "curl",
+	Python: "Python",
+	Raw:    "Raw HTTP",
+	Go:     "Go",
+	Ffuf:   "ffuf",
+	HTTPie: "HTTPie",
+}
+
+// languages are the Markdown code block languages of the formats
+var languages = map[string]string{
+	Curl:   "bash",
+	Python: "python",
+	Raw:    "http",
+	Go:     "go",
+	Ffuf:   "bash",
+	HTTPie: "bash",
+}
+
+// ParseFormats parses a comma-separated list of formats. "all" selects every
+// format and an empty list the defaults.
+func ParseFormats(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return DefaultFormats, nil
+	}
+
+	var formats []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "all" {
+			return Formats, nil
+		}
+		if _, ok := labels[name]; !ok {
+			return nil, fmt.Errorf("unknown snippet format %q, expected one of %s or all", name, strings.Join(Formats, ", "))
+		}
+		formats = append(formats, name)
+	}
+	return formats, nil
+}
+
+// Label returns the display name of a format
+func Label(format string) string {
+	return labels[format]
+}
+
+// Language returns the code block language of a format
+func Language(format string) string {
+	return languages[format]
+}
+
+// Generate returns the snippet that replays req in the given format. Where a
+// client can't send the request exactly as it was sent, the snippet says so in
+// a comment and sends the closest request the client can.
+func Generate(format string, req *http.Request) string {
+	switch format {
+	case Curl:
+		return curl(req)
+	case Python:
+		return python(req)
+	case Raw:
+		return string(req.Bytes())
+	case Go:
+		return golang(req)
+	case Ffuf:
+		return ffuf(req)
+	case HTTPie:
+		return httpie(req)
+	}
+	return ""
+}
+
+// ForResult returns the request of a result, rebuilding a plain request from
+// its URL and method for results recorded without one
+func ForResult(result bypass.Result) *http.Request {
+	if result.Request != nil {
+		return result.Request
+	}
+
+	origin, target, err := http.SplitURL(result.URL)
+	if err != nil {
+		return &http.Request{Method: result.Method, Target: result.URL, Proto: "HTTP/1.1"}
+	}
+	req, err := http.NewRequest(result.Method, origin, target)
+	if err != nil {
+		return &http.Request{Method: result.Method, Origin: origin, Target: target, Proto: "HTTP/1.1"}
+	}
+	return req
+}
+
+// host returns the host[:port] part of the request origin
+func host(req *http.Request) string {
+	if idx := strings.Index(req.Origin, "://"); idx != -1 {
+		return req.Origin[idx+3:]
+	}
+	return req.Origin
+}
+
+// originHostPort returns the host name and port of the request origin
+func originHostPort(req *http.Request) (string, string) {
+	u, err := url.Parse(req.Origin)
+	if err != nil {
+		return host(req), ""
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return u.Hostname(), port
+}
+
+// originForm reports whether the request-target is a path that clients can
+// append to the origin
+func originForm(req *http.Request) bool {
+	return strings.HasPrefix(req.Target, "/")
+}
+
+// headers returns the headers a client needs to be told about explicitly,
+// leaving out the ones every client derives from the URL and body on its own,
+// and HTTP/2 pseudo-headers, which no client takes as headers
+func headers(req *http.Request) []http.Header {
+	var list []http.Header
+	for _, h := range req.Headers {
+		if strings.HasPrefix(h.Name, ":") {
+			continue
+		}
+		switch strings.ToLower(h.Name) {
+		case "content-length", "connection":
+			continue
+		case "host":
+			if h.Value == host(req) {
+				continue
+			}
+		}
+		list = append(list, h)
+	}
+	return list
+}
+
+// http2Note explains how an HTTP/2 request differs from what the client sends:
+// smuggled over an h2c upgrade, downgraded to HTTP/1.1 by clients without
+// HTTP/2 support, and a header block
+// the client builds itself when the original was sent verbatim. The note is a
+// comment in the given style, empty for other requests.
+func http2Note(client, comment string, downgraded bool, req *http.Request) string {
+	if req.Proto != http.ProtoHTTP2 {
+		return ""
+	}
+
+	var out strings.Builder
+	if req.Upgrade != nil {
+		out.WriteString(comment + " " + client + " sends this directly; the original was smuggled over an h2c upgrade of " +
+			strconv.Quote(req.Upgrade.Method+" "+req.Upgrade.Target) + "\n")
+	}
+	if downgraded {
+		out.WriteString(comment + " " + client + " sends HTTP/1.1; the original request was HTTP/2\n")
+	}
+	if !hasPseudo(req) {
+		return out.String()
+	}
+	out.WriteString(comment + " " + client + " can't send this HTTP/2 header block as-is; the original was:\n")
+	for _, h := range req.HeaderFields() {
+		out.WriteString(comment + "   " + strconv.Quote(h.Name) + ": " + strconv.Quote(h.Value) + "\n")
+	}
+	return out.String()
+}
+
+// hasPseudo reports whether the request carries its own HTTP/2 pseudo-headers
+func hasPseudo(req *http.Request) bool {
+	for _, h := range req.Headers {
+		if strings.HasPrefix(h.Name, ":") {
+			return true
+		}
+	}
+	return false
+}
+
+// has reports whether the request carries a header, case-insensitively
+func has(req *http.Request, name string) bool {
+	for _, h := range req.Headers {
+		if strings.EqualFold(h.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// repeated returns the names of headers sent more than once
+func repeated(req *http.Request) []string {
+	count := make(map[string]int)
+	var names []string
+	for _, h := range req.Headers {
+		key := strings.ToLower(h.Name)
+		count[key]++
+		if count[key] == 2 {
+			names = append(names, h.Name)
+		}
+	}
+	return names
+}
+
+// shellQuote quotes a string for safe use as a single POSIX shell argument
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,193 @@
+package snippet
+
+import (
+	"encoding/hex"
+	"go/parser"
+	"go/token"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ibrahimsql/bypass403/pkg/bypass"
+	"github.com/ibrahimsql/bypass403/pkg/http"
+)
+
+// request builds a request to https://example.com with the given headers
+func request(method, target string, headers ...http.Header) *http.Request {
+	req, _ := http.NewRequest(method, "https://example.com", target)
+	req.Headers = append(req.Headers, headers...)
+	return req
+}
+
+// testRequests are requests every format must reproduce, from a plain GET to
+// targets and bodies no client sends on its own
+func testRequests() map[string]*http.Request {
+	post := request("POST", "/api/admin", http.Header{Name: "Content-Type", Value: "application/json"})
+	post.Body = []byte("{\"user\":\"it's \\\"me\\\"\"}\r\n\x00\xff")
+
+	h2 := request("GET", "/admin", http.Header{Name: ":path", Value: "/admin"})
+	h2.Proto = http.ProtoHTTP2
+
+	return map[string]*http.Request{
+		"plain":    request("GET", "/admin/..;/", http.Header{Name: "X-Original-URL", Value: "/admin"}),
+		"absolute": request("GET", "http://localhost/admin"),
+		"double":   request("GET", "//admin?x=1", http.Header{Name: "X-Empty", Value: ""}),
+		"head":     request("HEAD", "/admin", http.Header{Name: "Host", Value: "localhost"}),
+		"body":     post,
+		"http2":    h2,
+	}
+}
+
+func TestParseFormats(t *testing.T) {
+	tests := []struct {
+		list    string
+		formats []string
+		err     bool
+	}{
+		{"", DefaultFormats, false},
+		{" ", DefaultFormats, false},
+		{"curl", []string{Curl}, false},
+		{"Raw, go ,ffuf", []string{Raw, Go, Ffuf}, false},
+		{"curl,all", Formats, false},
+		{"curl,wget", nil, true},
+	}
+	for _, tt := range tests {
+		formats, err := ParseFormats(tt.list)
+		if (err != nil) != tt.err || !reflect.DeepEqual(formats, tt.formats) {
+			t.Errorf("ParseFormats(%q) = %v, %v, want %v (error %v)", tt.list, formats, err, tt.formats, tt.err)
+		}
+	}
+	for _, format := range Formats {
+		if Label(format) == "" || Language(format) == "" {
+			t.Errorf("format %q has no label or language", format)
+		}
+	}
+}
+
+func TestCurl(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"plain", `curl -k -s -i --path-as-is --http1.1 -X 'GET' -H 'X-Original-URL: /admin' -H 'User-Agent:' -H 'Accept:' 'https://example.com/admin/..;/'`},
+		{"absolute", `curl -k -s -i --path-as-is --http1.1 -X 'GET' --request-target 'http://localhost/admin' -H 'User-Agent:' -H 'Accept:' 'https://example.com/'`},
+		{"double", `curl -k -s -i --path-as-is --http1.1 -X 'GET' -H 'X-Empty;' -H 'User-Agent:' -H 'Accept:' 'https://example.com//admin?x=1'`},
+		{"head", `curl -k -s -i --path-as-is --http1.1 --head -H 'Host: localhost' -H 'User-Agent:' -H 'Accept:' 'https://example.com/admin'`},
+		{"body", `curl -k -s -i --path-as-is --http1.1 -X 'POST' -H 'Content-Type: application/json' -H 'User-Agent:' -H 'Accept:' --data-binary '{"user":"it'\''s \"me\""}` + "\r\n\x00\xff" + `' 'https://example.com/api/admin'`},
+	}
+	requests := testRequests()
+	for _, tt := range tests {
+		if got := Generate(Curl, requests[tt.name]); got != tt.want {
+			t.Errorf("curl for %s:\n got %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+
+	if got := Generate(Curl, requests["http2"]); !strings.Contains(got, " --http2 ") || !strings.HasPrefix(got, "# curl can't send this HTTP/2 header block as-is") {
+		t.Errorf("curl for http2 = %s, want --http2 with a note on the header block", got)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no POSIX shell")
+	}
+	for _, s := range []string{"plain", "it's", "'", "''", "$HOME `id` \\ \"x\"", "line\nbreak"} {
+		out, err := exec.Command(sh, "-c", "printf %s "+shellQuote(s)).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != s {
+			t.Errorf("shellQuote(%q) reads back as %q", s, out)
+		}
+	}
+}
+
+func TestRaw(t *testing.T) {
+	for name, req := range testRequests() {
+		if got := Generate(Raw, req); got != string(req.Bytes()) {
+			t.Errorf("raw for %s = %q, want the bytes sent %q", name, got, req.Bytes())
+		}
+	}
+}
+
+func TestGoSnippetParses(t *testing.T) {
+	for name, req := range testRequests() {
+		code := Generate(Go, req)
+		if _, err := parser.ParseFile(token.NewFileSet(), name+".go", code, parser.AllErrors); err != nil {
+			t.Errorf("Go snippet for %s doesn't parse: %s\n%s", name, err, code)
+		}
+	}
+
+	code := Generate(Go, testRequests()["double"])
+	if !strings.Contains(code, `Opaque: "//example.com//admin?x=1"`) {
+		t.Errorf("Go snippet for a // target doesn't send it in absolute form:\n%s", code)
+	}
+}
+
+func TestPythonSnippetParses(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("no python3")
+	}
+	for name, req := range testRequests() {
+		code := Generate(Python, req)
+		cmd := exec.Command(python, "-c", "import ast, sys; ast.parse(sys.stdin.read())")
+		cmd.Stdin = strings.NewReader(code)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("Python snippet for %s doesn't parse: %s\n%s\n%s", name, err, out, code)
+		}
+	}
+
+	// The body literal evaluates to the exact bytes sent
+	body := testRequests()["body"].Body
+	cmd := exec.Command(python, "-c", "import ast, sys; sys.stdout.write(ast.literal_eval(sys.stdin.read()).hex())")
+	cmd.Stdin = strings.NewReader(pyBytes(body))
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := hex.EncodeToString(body); string(out) != want {
+		t.Errorf("pyBytes evaluates to %s, want %s", out, want)
+	}
+}
+
+func TestFfuf(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"plain", `ffuf -raw -k -mc all -w <(printf '%s\n' 'GET') -X 'FUZZ' -H 'X-Original-URL: /admin' -u 'https://example.com/admin/..;/'`},
+		{"head", `ffuf -raw -k -mc all -w <(printf '%s\n' 'admin') -X 'HEAD' -H 'Host: localhost' -u 'https://example.com/FUZZ'`},
+		{"double", `ffuf -raw -k -mc all -w <(printf '%s\n' 'admin') -X 'GET' -H 'X-Empty: ' -u 'https://example.com//FUZZ?x=1'`},
+	}
+	requests := testRequests()
+	for _, tt := range tests {
+		if got := Generate(Ffuf, requests[tt.name]); got != tt.want {
+			t.Errorf("ffuf for %s:\n got %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPieNotes(t *testing.T) {
+	got := Generate(HTTPie, testRequests()["absolute"])
+	if !strings.HasPrefix(got, `# HTTPie can't send the request-target "http://localhost/admin" as-is`+"\n") {
+		t.Errorf("HTTPie for an absolute-form target has no note:\n%s", got)
+	}
+
+	req := request("GET", "/admin", http.Header{Name: "X-Forwarded-For", Value: "127.0.0.1"}, http.Header{Name: "X-Forwarded-For", Value: "10.0.0.1"})
+	if got := Generate(HTTPie, req); !strings.Contains(got, "# HTTPie sends each header once; repeated in the original: X-Forwarded-For\n") {
+		t.Errorf("HTTPie for a repeated header has no note:\n%s", got)
+	}
+}
+
+func TestForResult(t *testing.T) {
+	recorded := request("GET", "/admin%2f")
+	if got := ForResult(bypass.Result{URL: "https://example.com/other", Method: "GET", Request: recorded}); got != recorded {
+		t.Errorf("ForResult = %+v, want the recorded request", got)
+	}
+
+	got := ForResult(bypass.Result{URL: "https://example.com/admin;/?a=b", Method: "PUT"})
+	if got.Method != "PUT" || got.Origin != "https://example.com" || got.Target != "/admin;/?a=b" || got.Header("Host") != "example.com" {
+		t.Errorf("ForResult = %+v, want a PUT to /admin;/?a=b rebuilt from the URL", got)
+	}
+}
@@ -0,0 +1,189 @@
+package targets
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Input formats of a target list
+const (
+	FormatAuto  = "auto"
+	FormatPlain = "plain"
+	FormatHTTPX = "httpx"
+	FormatFFUF  = "ffuf"
+)
+
+// Formats are the supported input formats, auto detecting the others
+var Formats = []string{FormatAuto, FormatPlain, FormatHTTPX, FormatFFUF}
+
+// blocked are the status codes kept from httpx and ffuf output
+var blocked = map[int]bool{401: true, 403: true}
+
+// Load reads the targets from a file, or from standard input if path is "-"
+func Load(path, format string) ([]string, []error, error) {
+	if path == "-" {
+		return Read(os.Stdin, format)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening target list: %s", err)
+	}
+	defer file.Close()
+
+	return Read(file, format)
+}
+
+// Read parses a target list: one URL per line, httpx JSON Lines output or an
+// ffuf JSON report. Only the 401 and 403 responses of httpx and ffuf are kept.
+// Duplicates are dropped, keeping the first occurrence. Entries that are not
+// valid URLs are left out too, with an error for each in skipped, so one bad
+// line doesn't sink a long list; only a list without any valid entry fails.
+func Read(r io.Reader, format string) (targets []string, skipped []error, err error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading target list: %s", err)
+	}
+
+	if format == "" || format == FormatAuto {
+		format = Detect(data)
+	}
+
+	var urls []string
+	switch format {
+	case FormatPlain:
+		urls, err = parsePlain(data)
+	case FormatHTTPX:
+		urls, err = parseHTTPX(data)
+	case FormatFFUF:
+		urls, err = parseFFUF(data)
+	default:
+		return nil, nil, fmt.Errorf("unknown target list format %q (valid: %s)", format, strings.Join(Formats, ", "))
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, u := range urls {
+		if err := Validate(u); err != nil {
+			skipped = append(skipped, err)
+			continue
+		}
+		if !seen[u] {
+			seen[u] = true
+			targets = append(targets, u)
+		}
+	}
+	if len(targets) == 0 && len(skipped) > 0 {
+		return nil, skipped, fmt.Errorf("no valid targets in the list: %s", skipped[0])
+	}
+	return targets, skipped, nil
+}
+
+// Detect guesses the format of a target list: a JSON object with a results
+// array is an ffuf report, JSON lines are httpx output, anything else a plain list
+func Detect(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+	if !bytes.HasPrefix(trimmed, []byte("{")) {
+		return FormatPlain
+	}
+
+	var report struct {
+		Results json.RawMessage `json:"results"`
+	}
+	if json.Unmarshal(trimmed, &report) == nil && report.Results != nil {
+		return FormatFFUF
+	}
+	return FormatHTTPX
+}
+
+// Validate checks that a target is an absolute http or https URL
+func Validate(target string) error {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid target URL %q: an absolute http:// or https:// URL is required", target)
+	}
+	return nil
+}
+
+// parsePlain reads one URL per line, skipping blank lines and # comments
+func parsePlain(data []byte) ([]string, error) {
+	var urls []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading target list: %s", err)
+	}
+	return urls, nil
+}
+
+// parseHTTPX reads httpx -json output, one JSON object per line
+func parseHTTPX(data []byte) ([]string, error) {
+	var urls []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	// httpx lines carry response headers and bodies, so allow long ones
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		var entry struct {
+			URL        string `json:"url"`
+			StatusCode int    `json:"status_code"`
+			// Older httpx releases used a dashed key
+			StatusCodeDashed int `json:"status-code"`
+		}
+		if err := json.Unmarshal(text, &entry); err != nil {
+			return nil, fmt.Errorf("error parsing httpx output, line %d: %s", line, err)
+		}
+		status := entry.StatusCode
+		if status == 0 {
+			status = entry.StatusCodeDashed
+		}
+		if entry.URL != "" && blocked[status] {
+			urls = append(urls, entry.URL)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading httpx output: %s", err)
+	}
+	return urls, nil
+}
+
+// parseFFUF reads an ffuf -of json report
+func parseFFUF(data []byte) ([]string, error) {
+	var report struct {
+		Results []struct {
+			URL    string `json:"url"`
+			Status int    `json:"status"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("error parsing ffuf output: %s", err)
+	}
+
+	var urls []string
+	for _, result := range report.Results {
+		if result.URL != "" && blocked[result.Status] {
+			urls = append(urls, result.URL)
+		}
+	}
+	return urls, nil
+}
@@ -0,0 +1,130 @@
+package targets
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		data    string
+		want    []string
+		skipped int
+		wantErr bool
+	}{
+		{
+			name:   "plain list with comments and duplicates",
+			format: FormatAuto,
+			data:   "# targets\nhttps://a.example/admin\n\n  http://b.example/x  \nhttps://a.example/admin\n",
+			want:   []string{"https://a.example/admin", "http://b.example/x"},
+		},
+		{
+			name:    "invalid entries are skipped",
+			format:  FormatPlain,
+			data:    "https://a.example/admin\nnot a url\nftp://b.example/\n/relative\n",
+			want:    []string{"https://a.example/admin"},
+			skipped: 3,
+		},
+		{
+			name:    "nothing valid",
+			format:  FormatPlain,
+			data:    "not a url\n",
+			wantErr: true,
+		},
+		{
+			name:   "empty list",
+			format: FormatAuto,
+			data:   "",
+			want:   nil,
+		},
+		{
+			name:   "httpx keeps 401 and 403",
+			format: FormatAuto,
+			data: `{"url":"https://a.example/admin","status_code":403}
+{"url":"https://a.example/","status_code":200}
+{"url":"https://a.example/login","status-code":401}
+{"url":"https://a.example/gone","status_code":404}
+`,
+			want: []string{"https://a.example/admin", "https://a.example/login"},
+		},
+		{
+			name:    "httpx with a broken line",
+			format:  FormatHTTPX,
+			data:    "{\"url\":\"https://a.example/\",\"status_code\":403}\n{broken\n",
+			wantErr: true,
+		},
+		{
+			name:   "ffuf report",
+			format: FormatAuto,
+			data: `{"commandline":"ffuf","results":[
+				{"url":"https://a.example/admin","status":403},
+				{"url":"https://a.example/index","status":200},
+				{"url":"https://a.example/private","status":401}
+			]}`,
+			want: []string{"https://a.example/admin", "https://a.example/private"},
+		},
+		{
+			name:    "unknown format",
+			format:  "csv",
+			data:    "https://a.example/",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		got, skipped, err := Read(strings.NewReader(tt.data), tt.format)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: targets = %v, want %v", tt.name, got, tt.want)
+		}
+		if len(skipped) != tt.skipped {
+			t.Errorf("%s: skipped %d entries (%v), want %d", tt.name, len(skipped), skipped, tt.skipped)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"https://a.example/\n", FormatPlain},
+		{"", FormatPlain},
+		{`{"url":"https://a.example/","status_code":403}`, FormatHTTPX},
+		{`  {"results":[]}`, FormatFFUF},
+	}
+
+	for _, tt := range tests {
+		if got := Detect([]byte(tt.data)); got != tt.want {
+			t.Errorf("Detect(%q) = %s, want %s", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		target string
+		valid  bool
+	}{
+		{"https://example.com/admin", true},
+		{"http://127.0.0.1:8080/", true},
+		{"example.com/admin", false},
+		{"ftp://example.com/", false},
+		{"https:///admin", false},
+	}
+
+	for _, tt := range tests {
+		if err := Validate(tt.target); (err == nil) != tt.valid {
+			t.Errorf("Validate(%q) = %v, want valid %v", tt.target, err, tt.valid)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/ibrahimsql/bypass403/pkg/bypass"
+	"github.com/ibrahimsql/bypass403/pkg/snippet"
 )
 
 // SaveForbiddenBypass saves a successful bypass URL to a file
@@ -25,22 +26,46 @@ func SaveForbiddenBypass(url string) error {
 	return nil
 }
 
-// SaveResultsToFile saves bypass results to a file
-func SaveResultsToFile(results []bypass.Result, filename string, targetURL string) error {
+// SaveResultsToFile saves bypass results to a file, grouped by target, with
+// reproduction snippets in the given formats for each bypass
+func SaveResultsToFile(results []bypass.Result, filename string, targets []string, formats []string) error {
 	file, err := os.Create(filename)
 	if err != nil {
 		return fmt.Errorf("error creating output file: %s", err)
 	}
 	defer file.Close()
 
+	// Number the results in the order they are listed, target by target
+	groups := bypass.GroupByTarget(targets, results)
+	results = nil
+	for _, group := range groups {
+		results = append(results, group.Results...)
+	}
+
 	writer := bufio.NewWriter(file)
 	writer.WriteString("=== 403 Bypass Results ===\n")
-	writer.WriteString(fmt.Sprintf("Target URL: %s\n", targetURL))
-	writer.WriteString(fmt.Sprintf("Date: %s\n\n", time.Now().Format(time.RFC1123)))
-
-	for i, r := range results {
-		writer.WriteString(fmt.Sprintf("%d. %s (%d) - Technique: %s/%s\n",
-			i+1, r.URL, r.StatusCode, r.Technique, r.Method))
+	if len(targets) == 1 {
+		writer.WriteString(fmt.Sprintf("Target URL: %s\n", targets[0]))
+	} else {
+		writer.WriteString(fmt.Sprintf("Targets: %d\n", len(targets)))
+	}
+	writer.WriteString(fmt.Sprintf("Date: %s\n", time.Now().Format(time.RFC1123)))
+
+	n := 0
+	for _, group := range groups {
+		if len(groups) > 1 {
+			writer.WriteString(fmt.Sprintf("\n--- %s ---\n", group.Target))
+			if len(group.Results) == 0 {
+				writer.WriteString("No bypasses found\n")
+			}
+		} else {
+			writer.WriteString("\n")
+		}
+		for _, r := range group.Results {
+			n++
+			writer.WriteString(fmt.Sprintf("%d. %s (%d) - Technique: %s/%s\n",
+				n, r.URL, r.StatusCode, r.Technique, r.Method))
+		}
 	}
 
 	writer.WriteString("\n=== Tips ===\n")
@@ -49,12 +74,16 @@ func SaveResultsToFile(results []bypass.Result, filename string, targetURL strin
 	writer.WriteString("3. Combine multiple techniques for better results\n")
 	writer.WriteString("4. Try advanced mutations and custom wordlists for better coverage\n")
 
-	// Add curl examples
+	// Add reproduction snippets
 	writer.WriteString("\n=== Examples for successful bypasses ===\n")
-	for _, r := range results {
-		if r.StatusCode != 403 && r.StatusCode != 404 {
-			writer.WriteString(fmt.Sprintf("CURL: %s\n", GenerateCurlCommand(r)))
-			writer.WriteString(fmt.Sprintf("Python: %s\n\n", GeneratePythonRequest(r)))
+	for i, r := range results {
+		if !r.IsBypass() {
+			continue
+		}
+		req := snippet.ForResult(r)
+		writer.WriteString(fmt.Sprintf("\n--- %d. %s ---\n", i+1, r.Technique))
+		for _, format := range formats {
+			writer.WriteString(fmt.Sprintf("%s:\n%s\n\n", snippet.Label(format), strings.TrimRight(snippet.Generate(format, req), "\r\n")))
 		}
 	}
 
@@ -70,60 +99,3 @@ func ContainsCategory(techniqueCategory, userCategory string) bool {
 		strings.ToLower(userCategory),
 	)
 }
-
-// GenerateCurlCommand generates a curl command for a successful bypass
-func GenerateCurlCommand(result bypass.Result) string {
-	// Basic curl command
-	curlCmd := fmt.Sprintf("curl -X %s '%s'", result.Method, result.URL)
-
-	// Add additional headers based on technique
-	if strings.Contains(result.Technique, "Header") {
-		headerName := strings.TrimPrefix(strings.Split(result.Technique, ":")[1], " ")
-		if headerName == "X-Original-URL" {
-			curlCmd += " -H 'X-Original-URL: /'"
-		} else if headerName == "X-Rewrite-URL" {
-			curlCmd += " -H 'X-Rewrite-URL: /'"
-		} else if strings.Contains(headerName, "Forwarded-For") {
-			curlCmd += " -H '" + headerName + ": 127.0.0.1'"
-		} else if headerName == "User-Agent" {
-			curlCmd += " -H 'User-Agent: Googlebot/2.1 (+http://www.google.com/bot.html)'"
-		}
-	}
-
-	// Add -k for insecure SSL
-	curlCmd += " -k"
-
-	return curlCmd
-}
-
-// GeneratePythonRequest generates a Python request code for a successful bypass
-func GeneratePythonRequest(result bypass.Result) string {
-	// Basic Python code
-	pythonCode := "import requests\n\n"
-
-	// Add headers if needed
-	if strings.Contains(result.Technique, "Header") {
-		headerName := strings.TrimPrefix(strings.Split(result.Technique, ":")[1], " ")
-		pythonCode += "headers = {\n"
-		if headerName == "X-Original-URL" {
-			pythonCode += "    'X-Original-URL': '/',\n"
-		} else if headerName == "X-Rewrite-URL" {
-			pythonCode += "    'X-Rewrite-URL': '/',\n"
-		} else if strings.Contains(headerName, "Forwarded-For") {
-			pythonCode += "    '" + headerName + "': '127.0.0.1',\n"
-		} else if headerName == "User-Agent" {
-			pythonCode += "    'User-Agent': 'Googlebot/2.1 (+http://www.google.com/bot.html)',\n"
-		}
-		pythonCode += "}\n\n"
-		pythonCode += fmt.Sprintf("response = requests.%s('%s', headers=headers, verify=False)\n",
-			strings.ToLower(result.Method), result.URL)
-	} else {
-		pythonCode += fmt.Sprintf("response = requests.%s('%s', verify=False)\n",
-			strings.ToLower(result.Method), result.URL)
-	}
-
-	pythonCode += "print(response.status_code)\n"
-	pythonCode += "print(response.text)\n"
-
-	return pythonCode
-}
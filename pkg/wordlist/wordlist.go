@@ -4,9 +4,12 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"strings"
 )
 
-// Load loads a wordlist from a file
+// Load loads a wordlist from a file, one entry per line. Surrounding
+// whitespace, including the CR of CRLF files, is trimmed, since a space in a
+// payload would break the request line it is sent in.
 func Load(path string) ([]string, error) {
 	var payloads []string
 
@@ -23,7 +26,7 @@ func Load(path string) ([]string, error) {
 
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
-		line := scanner.Text()
+		line := strings.TrimSpace(scanner.Text())
 		if line != "" {
 			payloads = append(payloads, line)
 		}
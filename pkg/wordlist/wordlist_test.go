@@ -0,0 +1,59 @@
+package wordlist
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{"one per line", "/a\n/b\n", []string{"/a", "/b"}},
+		{"blank lines", "\n/a\n\n   \n/b", []string{"/a", "/b"}},
+		{"trailing spaces", "/%0A%0C%0D../ \n/b\t\n", []string{"/%0A%0C%0D../", "/b"}},
+		{"CRLF", "/a\r\n/b\r\n", []string{"/a", "/b"}},
+		{"empty", "", nil},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "wordlist.txt")
+		if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := Load(path)
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Load = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("Load of a missing file succeeded")
+	}
+}
+
+func TestBundledPayloads(t *testing.T) {
+	data, err := os.ReadFile("../../payloads/bypasses.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		if strings.ContainsAny(line, " \t\r") {
+			t.Errorf("payloads/bypasses.txt:%d: %q would break the request line", i+1, line)
+		}
+	}
+	for _, payload := range GetDefaultPayloads() {
+		if strings.ContainsAny(payload, " \t\r\n") {
+			t.Errorf("default payload %q would break the request line", payload)
+		}
+	}
+}